@@ -0,0 +1,347 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// How long a deleted account can still be restored by logging in.
+	accountDeletionGracePeriod = 30 * 24 * time.Hour
+	accountDeletionInterval    = time.Hour
+)
+
+func (apiCfg *apiConfig) ExportUserData(w http.ResponseWriter, r *http.Request, user database.User) {
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "zip" {
+		respondWithError(w, 400, "Format must be json or zip")
+		return
+	}
+	export, err := apiCfg.exportUserData(r.Context(), user)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in exporting user data: %s", err))
+		return
+	}
+	filename := fmt.Sprintf("%s-export-%s", user.Username, export.ExportedAt.Format("2006-01-02"))
+	if format != "zip" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		respondWithJson(w, 200, export)
+		return
+	}
+	// One file per section, so the archive is easy to browse by hand.
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"activities.json", export.Activities},
+		{"activity_logs.json", export.ActivityLogs},
+		{"goals.json", export.Goals},
+		{"streak.json", export.Streak},
+		{"team_memberships.json", export.TeamMemberships},
+		{"suggestions.json", export.Suggestions},
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".zip"))
+	w.WriteHeader(200)
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		fileWriter, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: export.ExportedAt})
+		if err != nil {
+			log.Printf("Error in writing export of user %s: %s", user.ID, err)
+			return
+		}
+		encoder := json.NewEncoder(fileWriter)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(file.data)
+		if err != nil {
+			log.Printf("Error in writing export of user %s: %s", user.ID, err)
+			return
+		}
+	}
+	err = archive.Close()
+	if err != nil {
+		log.Printf("Error in writing export of user %s: %s", user.ID, err)
+	}
+}
+
+func (apiCfg *apiConfig) exportUserData(ctx context.Context, user database.User) (AccountExport, error) {
+	activities, err := apiCfg.DB.GetUserActivitiesForExport(ctx, user.ID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+	activityLogs, err := apiCfg.DB.GetUserActivityLogsForExport(ctx, user.ID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+	goals, err := apiCfg.DB.GetUserGoals(ctx, user.ID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+	var streak *ExportedStreak
+	streakData, err := apiCfg.DB.GetStreakData(ctx, user.ID)
+	if err == nil {
+		streak = &ExportedStreak{CurrentStreak: streakData.CurrentStreak, LongestStreak: streakData.LongestStreak}
+		if streakData.LastLoggedDate.Valid {
+			streak.LastLoggedDate = &streakData.LastLoggedDate.Time
+		}
+	} else if err != sql.ErrNoRows {
+		return AccountExport{}, err
+	}
+	memberships, err := apiCfg.DB.GetUserTeamMemberships(ctx, user.ID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+	suggestions, err := apiCfg.DB.GetUserSuggestFeatures(ctx, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		return AccountExport{}, err
+	}
+	return AccountExport{
+		ExportedAt:      time.Now().UTC(),
+		Profile:         databaseUserToExportedProfile(user),
+		Activities:      databaseUserActivitiesToExportedActivities(activities),
+		ActivityLogs:    databaseActivityLogsToExportedActivityLogs(activityLogs),
+		Goals:           databaseUserGoalsToExportedGoals(goals),
+		Streak:          streak,
+		TeamMemberships: databaseTeamMembershipsToExportedTeamMemberships(memberships),
+		Suggestions:     databaseSuggestFeaturesToSuggestFeatures(suggestions),
+	}, nil
+}
+
+// DeleteUser schedules the account for deletion after the grace period and
+// signs it out everywhere. Every team the user owns has to be transferred to
+// another member or deleted first.
+func (apiCfg *apiConfig) DeleteUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	type teamDecision struct {
+		TeamID       string `json:"team_id"`
+		Action       string `json:"action"`
+		MembershipID string `json:"membership_id"`
+	}
+	type parameters struct {
+		Password     string         `json:"password"`
+		Code         string         `json:"code"`
+		RecoveryCode string         `json:"recovery_code"`
+		Teams        []teamDecision `json:"teams"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
+		return
+	}
+	if user.DeletionScheduledAt.Valid {
+		respondWithError(w, 409, "Account deletion is already scheduled")
+		return
+	}
+	if user.PasswordHash.Valid {
+		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(params.Password))
+		if err != nil {
+			respondWithError(w, 401, "Invalid password")
+			return
+		}
+	}
+	totp, mfaEnabled, err := apiCfg.getConfirmedTotp(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting two-factor authentication: %s", err))
+		return
+	}
+	if mfaEnabled {
+		valid, err := apiCfg.verifySecondFactor(r.Context(), totp, params.Code, params.RecoveryCode)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error verifying code: %s", err))
+			return
+		}
+		if !valid {
+			respondWithError(w, 401, "Invalid code")
+			return
+		}
+	}
+	ownedTeams, err := apiCfg.DB.GetTeamsCreatedByUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting owned teams: %s", err))
+		return
+	}
+	decisions := make(map[uuid.UUID]teamDecision)
+	for _, decision := range params.Teams {
+		teamID, err := uuid.Parse(decision.TeamID)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+			return
+		}
+		if decision.Action != "transfer" && decision.Action != "delete" {
+			respondWithError(w, 400, "Team action must be transfer or delete")
+			return
+		}
+		decisions[teamID] = decision
+	}
+	undecided := []string{}
+	for _, team := range ownedTeams {
+		if _, ok := decisions[team.ID]; !ok {
+			undecided = append(undecided, team.Name)
+		}
+	}
+	if len(undecided) > 0 {
+		respondWithError(w, 409, fmt.Sprintf("Transfer or delete the teams you own first: %s", strings.Join(undecided, ", ")))
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	for _, team := range ownedTeams {
+		decision := decisions[team.ID]
+		if decision.Action == "delete" {
+			err = qtx.DeleteTeam(r.Context(), team.ID)
+			if err != nil {
+				respondWithError(w, 500, fmt.Sprintf("Error in deleting team: %s", err))
+				return
+			}
+			continue
+		}
+		membershipID, err := uuid.Parse(decision.MembershipID)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Error in parsing team membership uuid: %s", err))
+			return
+		}
+		newOwnerMembership, err := qtx.GetTeamMembership(r.Context(), database.GetTeamMembershipParams{
+			ID:     membershipID,
+			TeamID: team.ID,
+		})
+		if err == sql.ErrNoRows || err == nil && newOwnerMembership.UserID == user.ID {
+			respondWithError(w, 400, fmt.Sprintf("Choose another member of %s as the new owner", team.Name))
+			return
+		} else if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error in getting team membership: %s", err))
+			return
+		}
+		currentOwnerMembership, err := qtx.GetTeamMembershipByUser(r.Context(), database.GetTeamMembershipByUserParams{
+			TeamID: team.ID,
+			UserID: user.ID,
+		})
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error in getting team membership: %s", err))
+			return
+		}
+		err = transferTeamOwnership(r.Context(), qtx, currentOwnerMembership, newOwnerMembership)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error in transferring team ownership: %s", err))
+			return
+		}
+	}
+	deletionDate := time.Now().Add(accountDeletionGracePeriod)
+	err = qtx.ScheduleUserDeletion(r.Context(), database.ScheduleUserDeletionParams{
+		ID:                  user.ID,
+		DeletionScheduledAt: sql.NullTime{Time: deletionDate, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in scheduling account deletion: %s", err))
+		return
+	}
+	err = qtx.RevokeUserSessions(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in revoking sessions: %s", err))
+		return
+	}
+	err = qtx.SetUserTokensValidAfter(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in revoking sessions: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, fmt.Sprintf("Your account will be deleted on %s , log in again before then to keep it", deletionDate.Format("2006-01-02")))
+}
+
+// startAccountDeletionWorker deletes the accounts whose grace period is over.
+func (apiCfg *apiConfig) startAccountDeletionWorker() {
+	ticker := time.NewTicker(accountDeletionInterval)
+	defer ticker.Stop()
+	for {
+		apiCfg.deleteDueAccounts()
+		<-ticker.C
+	}
+}
+
+func (apiCfg *apiConfig) deleteDueAccounts() {
+	ctx := context.Background()
+	userIDs, err := apiCfg.DB.GetUsersDueForDeletion(ctx, sql.NullTime{Time: time.Now(), Valid: true})
+	if err != nil {
+		log.Printf("Error getting accounts due for deletion: %s", err)
+		return
+	}
+	for _, userID := range userIDs {
+		err = apiCfg.purgeUser(ctx, userID)
+		if err != nil {
+			log.Printf("Error deleting account %s: %s", userID, err)
+		}
+	}
+}
+
+// purgeUser deletes the user and everything that cascades from it. Teams the
+// user came to own during the grace period go to their longest standing
+// member, or are deleted when nobody else is left. Feature suggestions are
+// kept without the username.
+func (apiCfg *apiConfig) purgeUser(ctx context.Context, userID uuid.UUID) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	ownedTeams, err := qtx.GetTeamsCreatedByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, team := range ownedTeams {
+		newOwnerMembership, err := qtx.GetOldestOtherTeamMembership(ctx, database.GetOldestOtherTeamMembershipParams{
+			TeamID: team.ID,
+			UserID: userID,
+		})
+		if err == sql.ErrNoRows {
+			err = qtx.DeleteTeam(ctx, team.ID)
+			if err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		currentOwnerMembership, err := qtx.GetTeamMembershipByUser(ctx, database.GetTeamMembershipByUserParams{
+			TeamID: team.ID,
+			UserID: userID,
+		})
+		if err != nil {
+			return err
+		}
+		err = transferTeamOwnership(ctx, qtx, currentOwnerMembership, newOwnerMembership)
+		if err != nil {
+			return err
+		}
+	}
+	err = qtx.AnonymizeUserSuggestFeatures(ctx, uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		return err
+	}
+	err = qtx.DeleteUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxActivityTags      = 10
+	maxActivityTagLength = 30
+	maxActivityIconLen   = 50
+)
+
+// activityCategories are the categories an activity can be filed under, an
+// empty category means uncategorised.
+var activityCategories = []string{"Health", "Learning", "Work", "Leisure", "Social", "Chores", "Other"}
+
+var colourPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// activityDetails are the optional descriptive fields of an activity. It is
+// embedded in the request bodies that create or edit activities, a field left
+// out is not changed on edit and empty on create.
+type activityDetails struct {
+	Category *string   `json:"category"`
+	Tags     *[]string `json:"tags"`
+	Icon     *string   `json:"icon"`
+	Colour   *string   `json:"colour"`
+}
+
+func (d *activityDetails) normalize() error {
+	if d.Category != nil {
+		category, err := normalizeActivityCategory(*d.Category)
+		if err != nil {
+			return err
+		}
+		d.Category = &category
+	}
+	if d.Tags != nil {
+		tags, err := normalizeActivityTags(*d.Tags)
+		if err != nil {
+			return err
+		}
+		d.Tags = &tags
+	}
+	if d.Icon != nil {
+		icon := strings.TrimSpace(*d.Icon)
+		err := validateActivityIcon(icon)
+		if err != nil {
+			return err
+		}
+		d.Icon = &icon
+	}
+	if d.Colour != nil {
+		colour := strings.ToLower(strings.TrimSpace(*d.Colour))
+		err := validateActivityColour(colour)
+		if err != nil {
+			return err
+		}
+		d.Colour = &colour
+	}
+	return nil
+}
+
+func (d activityDetails) category() string {
+	if d.Category == nil {
+		return ""
+	}
+	return *d.Category
+}
+
+func (d activityDetails) tags() []string {
+	if d.Tags == nil {
+		return []string{}
+	}
+	return *d.Tags
+}
+
+func (d activityDetails) icon() string {
+	if d.Icon == nil {
+		return ""
+	}
+	return *d.Icon
+}
+
+func (d activityDetails) colour() string {
+	if d.Colour == nil {
+		return ""
+	}
+	return *d.Colour
+}
+
+func (d activityDetails) setActivityDetailsParams(id uuid.UUID) database.SetActivityDetailsParams {
+	params := database.SetActivityDetailsParams{
+		Category: sql.NullString{String: d.category(), Valid: d.Category != nil},
+		Icon:     sql.NullString{String: d.icon(), Valid: d.Icon != nil},
+		Colour:   sql.NullString{String: d.colour(), Valid: d.Colour != nil},
+		ID:       id,
+	}
+	if d.Tags != nil {
+		params.Tags = *d.Tags
+	}
+	return params
+}
+
+// normalizeActivityCategory matches the category case-insensitively against
+// activityCategories and returns its canonical spelling.
+func normalizeActivityCategory(category string) (string, error) {
+	category = strings.TrimSpace(category)
+	if category == "" {
+		return "", nil
+	}
+	for _, activityCategory := range activityCategories {
+		if strings.EqualFold(category, activityCategory) {
+			return activityCategory, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown category: %s , use one of %s", category, strings.Join(activityCategories, ", "))
+}
+
+// normalizeActivityTags trims and lowercases the tags and drops duplicates, so
+// filtering by a tag does not depend on how it was typed.
+func normalizeActivityTags(tags []string) ([]string, error) {
+	normalizedTags := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || slices.Contains(normalizedTags, tag) {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxActivityTagLength {
+			return nil, fmt.Errorf("Tags must be at most %d characters long", maxActivityTagLength)
+		}
+		normalizedTags = append(normalizedTags, tag)
+	}
+	if len(normalizedTags) > maxActivityTags {
+		return nil, fmt.Errorf("An activity can have at most %d tags", maxActivityTags)
+	}
+	return normalizedTags, nil
+}
+
+func validateActivityIcon(icon string) error {
+	if utf8.RuneCountInString(icon) > maxActivityIconLen {
+		return fmt.Errorf("Icon must be at most %d characters long", maxActivityIconLen)
+	}
+	return nil
+}
+
+func validateActivityColour(colour string) error {
+	if colour != "" && !colourPattern.MatchString(colour) {
+		return errors.New("Colour must be a hex colour like #4caf50")
+	}
+	return nil
+}
@@ -7,13 +7,26 @@ import (
 	"github.com/google/uuid"
 	"github.com/mrdkvcs/go-base-backend/internal/database"
 	"log"
-	"math"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// GetActivites lists the user's active activities. They can be filtered with the
+// category and tag query parameters, archived=true lists the archived ones
+// instead and archived=all both.
 func (apiCfg *apiConfig) GetActivites(w http.ResponseWriter, r *http.Request, user database.User) {
-	activities, err := apiCfg.DB.GetActivities(r.Context(), user.ID)
+	category, err := normalizeActivityCategory(r.URL.Query().Get("category"))
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	activities, err := apiCfg.DB.GetActivities(r.Context(), database.GetActivitiesParams{
+		UserID:   user.ID,
+		Category: category,
+		Tag:      strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag"))),
+		Archived: r.URL.Query().Get("archived"),
+	})
 	if err != nil {
 		respondWithJson(w, 400, fmt.Sprintf("Error getting activities: %v", err))
 	}
@@ -22,8 +35,10 @@ func (apiCfg *apiConfig) GetActivites(w http.ResponseWriter, r *http.Request, us
 
 func (apiCfg *apiConfig) SetActivity(w http.ResponseWriter, r *http.Request, user database.User) {
 	type parameters struct {
-		Name   string `json:"name"`
-		Points int32  `json:"points"`
+		Name    string          `json:"name"`
+		Points  int32           `json:"points"`
+		Scoring ActivityScoring `json:"scoring"`
+		activityDetails
 	}
 	params := parameters{}
 	decoder := json.NewDecoder(r.Body)
@@ -32,12 +47,29 @@ func (apiCfg *apiConfig) SetActivity(w http.ResponseWriter, r *http.Request, use
 		respondWithError(w, 400, fmt.Sprintf("Error decoding parameters: %v", err))
 		return
 	}
+	err = params.Scoring.normalize()
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	err = params.activityDetails.normalize()
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
 	activity, err := apiCfg.DB.SetActivity(r.Context(), database.SetActivityParams{
-		ID:           uuid.New(),
-		UserID:       user.ID,
-		Name:         params.Name,
-		Points:       params.Points,
-		ActivityType: "custom",
+		ID:                      uuid.New(),
+		UserID:                  user.ID,
+		Name:                    params.Name,
+		Points:                  params.Points,
+		ActivityType:            "custom",
+		ScoringModel:            params.Scoring.Model,
+		ScoringDailyCap:         params.Scoring.dailyCap(),
+		ScoringThresholdMinutes: params.Scoring.thresholdMinutes(),
+		Category:                params.category(),
+		Tags:                    params.tags(),
+		Icon:                    params.icon(),
+		Colour:                  params.colour(),
 	})
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error setting activity: %v", err))
@@ -46,25 +78,36 @@ func (apiCfg *apiConfig) SetActivity(w http.ResponseWriter, r *http.Request, use
 	respondWithJson(w, 200, databaseActivityToActivity(SetActivityRowWrapper{activity}))
 }
 
-func (apiCfg *apiConfig) DeleteActivity(w http.ResponseWriter, r *http.Request) {
+// DeleteActivity removes the activity from the user's activities. The activity
+// is only marked as deleted so its logs keep their name and points.
+func (apiCfg *apiConfig) DeleteActivity(w http.ResponseWriter, r *http.Request, user database.User) {
 	activity_id := r.PathValue("id")
 	parsedActivityUUID, err := uuid.Parse(activity_id)
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error in parsing activity uuid: %s", err))
 		return
 	}
-	err = apiCfg.DB.DeleteActivity(r.Context(), parsedActivityUUID)
+	deleted, err := apiCfg.DB.DeleteActivity(r.Context(), database.DeleteActivityParams{
+		ID:     parsedActivityUUID,
+		UserID: user.ID,
+	})
 	if err != nil {
-		respondWithError(w, 400, fmt.Sprintf("Error deleting activity: %v", err))
+		respondWithError(w, 500, fmt.Sprintf("Error deleting activity: %v", err))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "Activity not found")
 		return
 	}
 	respondWithJson(w, 200, "Activity deleted successfully")
 }
-func (apiCfg *apiConfig) EditActivity(w http.ResponseWriter, r *http.Request) {
+func (apiCfg *apiConfig) EditActivity(w http.ResponseWriter, r *http.Request, user database.User) {
 
 	type parameters struct {
-		ActivityName   string `json:"activity_name"`
-		ActivityPoints int32  `json:"activity_points"`
+		ActivityName   string           `json:"activity_name"`
+		ActivityPoints int32            `json:"activity_points"`
+		Scoring        *ActivityScoring `json:"scoring"`
+		activityDetails
 	}
 
 	activityId := r.PathValue("id")
@@ -82,17 +125,115 @@ func (apiCfg *apiConfig) EditActivity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = apiCfg.DB.EditActivity(r.Context(), database.EditActivityParams{
+	if params.Scoring != nil {
+		err = params.Scoring.normalize()
+		if err != nil {
+			respondWithError(w, 400, err.Error())
+			return
+		}
+	}
+	err = params.activityDetails.normalize()
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+
+	edited, err := qtx.EditActivity(r.Context(), database.EditActivityParams{
 		Name:   params.ActivityName,
 		Points: params.ActivityPoints,
 		ID:     parsedActivityUUID,
+		UserID: user.ID,
 	})
 
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error editing activity: %v", err))
 		return
 	}
+	if edited == 0 {
+		respondWithError(w, 404, "Activity not found")
+		return
+	}
+
+	if params.Scoring != nil {
+		err = qtx.SetActivityScoring(r.Context(), database.SetActivityScoringParams{
+			ID:                      parsedActivityUUID,
+			ScoringModel:            params.Scoring.Model,
+			ScoringDailyCap:         params.Scoring.dailyCap(),
+			ScoringThresholdMinutes: params.Scoring.thresholdMinutes(),
+		})
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Error editing activity scoring: %v", err))
+			return
+		}
+	}
+
+	err = qtx.SetActivityDetails(r.Context(), params.setActivityDetailsParams(parsedActivityUUID))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error editing activity details: %v", err))
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Activity edited successfully")
+}
+
+func (apiCfg *apiConfig) GetActivityCategories(w http.ResponseWriter, r *http.Request, user database.User) {
+	respondWithJson(w, 200, activityCategories)
+}
+
+// ArchiveActivity hides the activity from the activity list and from matching
+// logs , its past logs are kept.
+func (apiCfg *apiConfig) ArchiveActivity(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.setActivityArchived(w, r, user, true)
+}
 
+func (apiCfg *apiConfig) UnarchiveActivity(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.setActivityArchived(w, r, user, false)
+}
+
+func (apiCfg *apiConfig) setActivityArchived(w http.ResponseWriter, r *http.Request, user database.User, archived bool) {
+	parsedActivityUUID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing activity uuid: %s", err))
+		return
+	}
+	_, err = apiCfg.DB.GetUserActivity(r.Context(), database.GetUserActivityParams{
+		ID:     parsedActivityUUID,
+		UserID: user.ID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Activity not found")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting activity: %s", err))
+		return
+	}
+	err = apiCfg.DB.SetActivityArchivedAt(r.Context(), database.SetActivityArchivedAtParams{
+		ArchivedAt: sql.NullTime{Time: time.Now().UTC(), Valid: archived},
+		ID:         parsedActivityUUID,
+		UserID:     user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in archiving activity: %s", err))
+		return
+	}
+	if archived {
+		respondWithJson(w, 200, "Activity archived successfully")
+		return
+	}
+	respondWithJson(w, 200, "Activity restored successfully")
 }
 
 func (apiCfg *apiConfig) CheckActivityLogExists(w http.ResponseWriter, r *http.Request, user database.User) {
@@ -116,11 +257,13 @@ func (apiCfg *apiConfig) CheckActivityLogExists(w http.ResponseWriter, r *http.R
 func (apiCfg *apiConfig) SetNewActivity(w http.ResponseWriter, r *http.Request, user database.User) {
 
 	type parameters struct {
-		ActivityName        string `json:"activity_name"`
-		ActivityPoints      int32  `json:"activity_points"`
-		ActivityDuration    int32  `json:"activity_duration"`
-		ActivityDescription string `json:"activity_description"`
-		OneTime             string `json:"one_time"`
+		ActivityName        string          `json:"activity_name"`
+		ActivityPoints      int32           `json:"activity_points"`
+		ActivityDuration    int32           `json:"activity_duration"`
+		ActivityDescription string          `json:"activity_description"`
+		OneTime             string          `json:"one_time"`
+		Scoring             ActivityScoring `json:"scoring"`
+		activityDetails
 	}
 	isStreakRecord := false
 	params := parameters{}
@@ -131,15 +274,14 @@ func (apiCfg *apiConfig) SetNewActivity(w http.ResponseWriter, r *http.Request,
 		return
 	}
 	if params.OneTime == "true" {
-		pointsPerMinutes := float64(params.ActivityPoints) / float64(60)
-		points := float64(params.ActivityDuration) * float64(pointsPerMinutes)
-		roundedPoints := math.Round(points)
+		// A one time log has no activity to keep a scoring model on.
+		points := calculatePoints(params.ActivityPoints, ActivityScoring{Model: ScoringPerHour}, params.ActivityDuration, 0, 0)
 		err = apiCfg.DB.SetActivityLog(r.Context(), database.SetActivityLogParams{
 			ID:                  uuid.New(),
 			UserID:              user.ID,
 			ActivityID:          uuid.NullUUID{Valid: false},
 			Duration:            params.ActivityDuration,
-			Points:              int32(roundedPoints),
+			Points:              points,
 			LoggedAt:            time.Now(),
 			ActivityDescription: params.ActivityDescription,
 		})
@@ -147,6 +289,7 @@ func (apiCfg *apiConfig) SetNewActivity(w http.ResponseWriter, r *http.Request,
 			respondWithError(w, 400, fmt.Sprintf("Error setting activity log %s", err))
 			return
 		}
+		apiCfg.emitUserWebhookEvent(r.Context(), user.ID, WebhookActivityLogged, ActivityLoggedWebhook{ActivityName: params.ActivityName, Duration: params.ActivityDuration, Points: points, Description: params.ActivityDescription})
 
 		dailyPoints, err := apiCfg.DB.GetDailyPoints(r.Context(), user.ID)
 		if err != nil {
@@ -156,7 +299,7 @@ func (apiCfg *apiConfig) SetNewActivity(w http.ResponseWriter, r *http.Request,
 
 		if dailyPoints.GoalPoints > 0 {
 			isGoalCompleted := dailyPoints.TotalPoints > dailyPoints.GoalPoints
-			dailyPoints.TotalPoints = dailyPoints.TotalPoints + params.ActivityPoints
+			dailyPoints.TotalPoints = dailyPoints.TotalPoints + points
 			if dailyPoints.TotalPoints > dailyPoints.GoalPoints && !isGoalCompleted {
 				stopChan <- struct{}{}
 				err := apiCfg.DB.SetGoalCompleted(r.Context(), user.ID)
@@ -164,6 +307,8 @@ func (apiCfg *apiConfig) SetNewActivity(w http.ResponseWriter, r *http.Request,
 					respondWithError(w, 400, fmt.Sprintf("Error setting goal completed: %v", err))
 					return
 				}
+				apiCfg.notify(r.Context(), user.ID, EventGoalCompleted, GoalCompletedEvent{GoalPoints: dailyPoints.GoalPoints, TotalPoints: dailyPoints.TotalPoints})
+				apiCfg.emitUserWebhookEvent(r.Context(), user.ID, WebhookGoalCompleted, GoalCompletedEvent{GoalPoints: dailyPoints.GoalPoints, TotalPoints: dailyPoints.TotalPoints})
 				return
 			}
 			if dailyPoints.GoalPoints > dailyPoints.TotalPoints && isGoalCompleted {
@@ -186,6 +331,11 @@ func (apiCfg *apiConfig) SetNewActivity(w http.ResponseWriter, r *http.Request,
 				respondWithError(w, 400, fmt.Sprintf("Error getting streak info: %v", err))
 				return
 			}
+			err = apiCfg.breakStreakAfterGap(r.Context(), user.ID, &streakInfo)
+			if err != nil {
+				respondWithError(w, 400, fmt.Sprintf("Error updating streak info: %v", err))
+				return
+			}
 			yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
 			if streakInfo.LastLoggedDate.Valid && streakInfo.LastLoggedDate.Time.Format("2006-01-02") == yesterday {
 				streakInfo.CurrentStreak += 1
@@ -212,26 +362,45 @@ func (apiCfg *apiConfig) SetNewActivity(w http.ResponseWriter, r *http.Request,
 		respondWithJson(w, 200, ActivityLogResponse{})
 		return
 	}
+	err = params.Scoring.normalize()
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	err = params.activityDetails.normalize()
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
 	activity, err := apiCfg.DB.SetActivity(r.Context(), database.SetActivityParams{
-		ID:           uuid.New(),
-		UserID:       user.ID,
-		Name:         params.ActivityName,
-		Points:       params.ActivityPoints,
-		ActivityType: "custom",
+		ID:                      uuid.New(),
+		UserID:                  user.ID,
+		Name:                    params.ActivityName,
+		Points:                  params.ActivityPoints,
+		ActivityType:            "custom",
+		ScoringModel:            params.Scoring.Model,
+		ScoringDailyCap:         params.Scoring.dailyCap(),
+		ScoringThresholdMinutes: params.Scoring.thresholdMinutes(),
+		Category:                params.category(),
+		Tags:                    params.tags(),
+		Icon:                    params.icon(),
+		Colour:                  params.colour(),
 	})
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error setting activity %s", err))
 		return
 	}
-	pointsPerMinutes := float64(params.ActivityPoints) / float64(60)
-	points := float64(params.ActivityDuration) * float64(pointsPerMinutes)
-	roundedPoints := math.Round(points)
+	points, err := apiCfg.scoreActivityLog(r.Context(), user.ID, databaseActivityToActivity(SetActivityRowWrapper{activity}), params.ActivityDuration)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error calculating points: %v", err))
+		return
+	}
 	err = apiCfg.DB.SetActivityLog(r.Context(), database.SetActivityLogParams{
 		ID:                  uuid.New(),
 		UserID:              user.ID,
 		ActivityID:          uuid.NullUUID{UUID: activity.ID, Valid: true},
 		Duration:            params.ActivityDuration,
-		Points:              int32(roundedPoints),
+		Points:              points,
 		LoggedAt:            time.Now(),
 		ActivityDescription: params.ActivityDescription,
 	})
@@ -239,6 +408,7 @@ func (apiCfg *apiConfig) SetNewActivity(w http.ResponseWriter, r *http.Request,
 		respondWithError(w, 400, fmt.Sprintf("Error setting activity log %s", err))
 		return
 	}
+	apiCfg.emitUserWebhookEvent(r.Context(), user.ID, WebhookActivityLogged, ActivityLoggedWebhook{ActivityID: activity.ID, ActivityName: activity.Name, Duration: params.ActivityDuration, Points: points, Description: params.ActivityDescription})
 	dailyPoints, err := apiCfg.DB.GetDailyPoints(r.Context(), user.ID)
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error getting daily points: %v", err))
@@ -246,7 +416,7 @@ func (apiCfg *apiConfig) SetNewActivity(w http.ResponseWriter, r *http.Request,
 	}
 	if dailyPoints.GoalPoints > 0 {
 		isGoalCompleted := dailyPoints.TotalPoints > dailyPoints.GoalPoints
-		dailyPoints.TotalPoints = dailyPoints.TotalPoints + params.ActivityPoints
+		dailyPoints.TotalPoints = dailyPoints.TotalPoints + points
 		if dailyPoints.TotalPoints > dailyPoints.GoalPoints && !isGoalCompleted {
 			stopChan <- struct{}{}
 			err := apiCfg.DB.SetGoalCompleted(r.Context(), user.ID)
@@ -254,6 +424,8 @@ func (apiCfg *apiConfig) SetNewActivity(w http.ResponseWriter, r *http.Request,
 				respondWithError(w, 400, fmt.Sprintf("Error setting goal completed: %v", err))
 				return
 			}
+			apiCfg.notify(r.Context(), user.ID, EventGoalCompleted, GoalCompletedEvent{GoalPoints: dailyPoints.GoalPoints, TotalPoints: dailyPoints.TotalPoints})
+			apiCfg.emitUserWebhookEvent(r.Context(), user.ID, WebhookGoalCompleted, GoalCompletedEvent{GoalPoints: dailyPoints.GoalPoints, TotalPoints: dailyPoints.TotalPoints})
 			return
 		}
 		if dailyPoints.GoalPoints > dailyPoints.TotalPoints && isGoalCompleted {
@@ -276,6 +448,11 @@ func (apiCfg *apiConfig) SetNewActivity(w http.ResponseWriter, r *http.Request,
 			respondWithError(w, 400, fmt.Sprintf("Error getting streak info: %v", err))
 			return
 		}
+		err = apiCfg.breakStreakAfterGap(r.Context(), user.ID, &streakInfo)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Error updating streak info: %v", err))
+			return
+		}
 		yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
 		if streakInfo.LastLoggedDate.Valid && streakInfo.LastLoggedDate.Time.Format("2006-01-02") == yesterday {
 			streakInfo.CurrentStreak += 1
@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"math"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -131,13 +130,16 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
+	if !apiCfg.consumeLlmQuota(w, r, user) {
+		return
+	}
 	activitiesMap, err := extractFromInput(params.ActivityInput, api_key)
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error in processing your input : %s", err))
 		return
 	}
 
-	userActivities, err := apiCfg.DB.GetActivities(r.Context(), user.ID)
+	userActivities, err := apiCfg.DB.GetActivities(r.Context(), database.GetActivitiesParams{UserID: user.ID})
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error getting activities: %v", err))
 		return
@@ -164,9 +166,11 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 			}
 		}
 		if matchCounter == 1 {
-			pointsPerMinutes := float64(matchedActivities[0].Points) / float64(60)
-			points := float64(duration) * float64(pointsPerMinutes)
-			roundedPoints := math.Round(points)
+			points, err := apiCfg.scoreActivityLog(r.Context(), user.ID, matchedActivities[0], int32(duration))
+			if err != nil {
+				respondWithError(w, 500, fmt.Sprintf("Error calculating points: %v", err))
+				return
+			}
 
 			dailyMinutes, err := apiCfg.DB.GetDailyMinutes(r.Context(), user.ID)
 			if err != nil {
@@ -184,7 +188,7 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 				UserID:              user.ID,
 				ActivityID:          uuid.NullUUID{UUID: matchedActivities[0].ActivityID, Valid: true},
 				Duration:            int32(duration),
-				Points:              int32(roundedPoints),
+				Points:              points,
 				ActivityDescription: params.ActivityInput,
 				LoggedAt:            time.Now(),
 			})
@@ -192,6 +196,7 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 				respondWithError(w, 400, fmt.Sprintf("Error setting activity log: %v", err))
 				return
 			}
+			apiCfg.emitUserWebhookEvent(r.Context(), user.ID, WebhookActivityLogged, ActivityLoggedWebhook{ActivityID: matchedActivities[0].ActivityID, ActivityName: matchedActivities[0].Name, Duration: int32(duration), Points: points, Description: params.ActivityInput})
 
 			dailyPoints, err := apiCfg.DB.GetDailyPoints(r.Context(), user.ID)
 			if err != nil {
@@ -201,7 +206,7 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 
 			if dailyPoints.GoalPoints > 0 {
 				isGoalCompleted := dailyPoints.TotalPoints > dailyPoints.GoalPoints
-				dailyPoints.TotalPoints = dailyPoints.TotalPoints + int32(roundedPoints)
+				dailyPoints.TotalPoints = dailyPoints.TotalPoints + points
 				if dailyPoints.TotalPoints > dailyPoints.GoalPoints && !isGoalCompleted {
 					stopChan <- struct{}{}
 					err := apiCfg.DB.SetGoalCompleted(r.Context(), user.ID)
@@ -209,6 +214,8 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 						respondWithError(w, 400, fmt.Sprintf("Error setting goal completed: %v", err))
 						return
 					}
+					apiCfg.notify(r.Context(), user.ID, EventGoalCompleted, GoalCompletedEvent{GoalPoints: dailyPoints.GoalPoints, TotalPoints: dailyPoints.TotalPoints})
+					apiCfg.emitUserWebhookEvent(r.Context(), user.ID, WebhookGoalCompleted, GoalCompletedEvent{GoalPoints: dailyPoints.GoalPoints, TotalPoints: dailyPoints.TotalPoints})
 				}
 				if dailyPoints.GoalPoints > dailyPoints.TotalPoints && isGoalCompleted {
 					err := apiCfg.DB.SetGoalUnCompleted(r.Context(), user.ID)
@@ -231,6 +238,11 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 					respondWithError(w, 400, fmt.Sprintf("Error getting streak info: %v", err))
 					return
 				}
+				err = apiCfg.breakStreakAfterGap(r.Context(), user.ID, &streakInfo)
+				if err != nil {
+					respondWithError(w, 400, fmt.Sprintf("Error updating streak info: %v", err))
+					return
+				}
 				yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
 				if streakInfo.LastLoggedDate.Valid && streakInfo.LastLoggedDate.Time.Format("2006-01-02") == yesterday {
 					streakInfo.CurrentStreak += 1
@@ -251,6 +263,9 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 					respondWithError(w, 400, fmt.Sprintf("Error updating streak info: %v", err))
 					return
 				}
+				if isStreakRecord {
+					apiCfg.notify(r.Context(), user.ID, EventStreakRecord, StreakRecordEvent{CurrentStreak: streakInfo.CurrentStreak, LongestStreak: streakInfo.LongestStreak})
+				}
 				respondWithJson(w, 200, ActivityLogResponse{MatchedActivities: matchedActivities, StreakCount: streakInfo.CurrentStreak, IsStreakRecord: isStreakRecord})
 				return
 			}
@@ -280,9 +295,11 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 			}
 		}
 		if matchCounter == 1 {
-			pointsPerMinutes := float64(matchedActivities[0].Points) / float64(60)
-			points := float64(v) * float64(pointsPerMinutes)
-			roundedPoints := math.Round(points)
+			points, err := apiCfg.scoreActivityLog(r.Context(), user.ID, matchedActivities[0], int32(v))
+			if err != nil {
+				respondWithError(w, 500, fmt.Sprintf("Error calculating points: %v", err))
+				return
+			}
 
 			dailyMinutes, err := apiCfg.DB.GetDailyMinutes(r.Context(), user.ID)
 			if err != nil {
@@ -300,7 +317,7 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 				UserID:              user.ID,
 				ActivityID:          uuid.NullUUID{UUID: matchedActivities[0].ActivityID, Valid: true},
 				Duration:            int32(v),
-				Points:              int32(roundedPoints),
+				Points:              points,
 				ActivityDescription: params.ActivityInput,
 				LoggedAt:            time.Now(),
 			})
@@ -308,6 +325,7 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 				respondWithError(w, 400, fmt.Sprintf("Error setting activity log: %v", err))
 				return
 			}
+			apiCfg.emitUserWebhookEvent(r.Context(), user.ID, WebhookActivityLogged, ActivityLoggedWebhook{ActivityID: matchedActivities[0].ActivityID, ActivityName: matchedActivities[0].Name, Duration: int32(v), Points: points, Description: params.ActivityInput})
 
 			dailyPoints, err := apiCfg.DB.GetDailyPoints(r.Context(), user.ID)
 			if err != nil {
@@ -317,7 +335,7 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 
 			if dailyPoints.GoalPoints > 0 {
 				isGoalCompleted := dailyPoints.TotalPoints > dailyPoints.GoalPoints
-				dailyPoints.TotalPoints = dailyPoints.TotalPoints + int32(roundedPoints)
+				dailyPoints.TotalPoints = dailyPoints.TotalPoints + points
 				if dailyPoints.TotalPoints > dailyPoints.GoalPoints && !isGoalCompleted {
 					stopChan <- struct{}{}
 					err := apiCfg.DB.SetGoalCompleted(r.Context(), user.ID)
@@ -325,6 +343,8 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 						respondWithError(w, 400, fmt.Sprintf("Error setting goal completed: %v", err))
 						return
 					}
+					apiCfg.notify(r.Context(), user.ID, EventGoalCompleted, GoalCompletedEvent{GoalPoints: dailyPoints.GoalPoints, TotalPoints: dailyPoints.TotalPoints})
+					apiCfg.emitUserWebhookEvent(r.Context(), user.ID, WebhookGoalCompleted, GoalCompletedEvent{GoalPoints: dailyPoints.GoalPoints, TotalPoints: dailyPoints.TotalPoints})
 				}
 				if dailyPoints.GoalPoints > dailyPoints.TotalPoints && isGoalCompleted {
 					err := apiCfg.DB.SetGoalUnCompleted(r.Context(), user.ID)
@@ -346,6 +366,11 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 					respondWithError(w, 400, fmt.Sprintf("Error getting streak info: %v", err))
 					return
 				}
+				err = apiCfg.breakStreakAfterGap(r.Context(), user.ID, &streakInfo)
+				if err != nil {
+					respondWithError(w, 400, fmt.Sprintf("Error updating streak info: %v", err))
+					return
+				}
 				yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
 				if streakInfo.LastLoggedDate.Valid && streakInfo.LastLoggedDate.Time.Format("2006-01-02") == yesterday {
 					streakInfo.CurrentStreak += 1
@@ -366,6 +391,9 @@ func (apiCfg *apiConfig) SetActivityLog(w http.ResponseWriter, r *http.Request,
 					respondWithError(w, 400, fmt.Sprintf("Error updating streak info: %v", err))
 					return
 				}
+				if isStreakRecord {
+					apiCfg.notify(r.Context(), user.ID, EventStreakRecord, StreakRecordEvent{CurrentStreak: streakInfo.CurrentStreak, LongestStreak: streakInfo.LongestStreak})
+				}
 				multipleMatchedActivities.IsStreakRecord = isStreakRecord
 				multipleMatchedActivities.StreakCount = streakInfo.CurrentStreak
 			}
@@ -386,8 +414,6 @@ func (apiCfg *apiConfig) SetSpecificActivityLog(w http.ResponseWriter, r *http.R
 
 	type parameters struct {
 		ActivityID          string `json:"activity_id"`
-		ActivityName        string `json:"activity_name"`
-		ActivityPoints      int32  `json:"activity_points"`
 		ActivityDuration    int32  `json:"activity_duration"`
 		ActivityDescription string `json:"activity_description"`
 	}
@@ -408,16 +434,31 @@ func (apiCfg *apiConfig) SetSpecificActivityLog(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	pointsInMinutes := float64(params.ActivityPoints) / float64(60)
-	points := float64(params.ActivityDuration) * float64(pointsInMinutes)
-	roundedPoints := math.Round(points)
+	dbActivity, err := apiCfg.DB.GetUserActivity(r.Context(), database.GetUserActivityParams{
+		ID:     activityUUID,
+		UserID: user.ID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Activity not found")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting activity: %v", err))
+		return
+	}
+	activity := databaseUserActivityToActivity(dbActivity)
+
+	points, err := apiCfg.scoreActivityLog(r.Context(), user.ID, activity, params.ActivityDuration)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error calculating points: %v", err))
+		return
+	}
 
 	err = apiCfg.DB.SetActivityLog(r.Context(), database.SetActivityLogParams{
 		ID:                  uuid.New(),
 		UserID:              user.ID,
 		ActivityID:          uuid.NullUUID{UUID: activityUUID, Valid: true},
 		Duration:            params.ActivityDuration,
-		Points:              int32(roundedPoints),
+		Points:              points,
 		ActivityDescription: params.ActivityDescription,
 		LoggedAt:            time.Now(),
 	})
@@ -426,6 +467,7 @@ func (apiCfg *apiConfig) SetSpecificActivityLog(w http.ResponseWriter, r *http.R
 		respondWithError(w, 400, fmt.Sprintf("Error setting activity log: %v", err))
 		return
 	}
+	apiCfg.emitUserWebhookEvent(r.Context(), user.ID, WebhookActivityLogged, ActivityLoggedWebhook{ActivityID: activityUUID, ActivityName: activity.Name, Duration: params.ActivityDuration, Points: points, Description: params.ActivityDescription})
 
 	dailyPoints, err := apiCfg.DB.GetDailyPoints(r.Context(), user.ID)
 	if err != nil {
@@ -435,7 +477,7 @@ func (apiCfg *apiConfig) SetSpecificActivityLog(w http.ResponseWriter, r *http.R
 
 	if dailyPoints.GoalPoints > 0 {
 		isGoalCompleted := dailyPoints.TotalPoints > dailyPoints.GoalPoints
-		dailyPoints.TotalPoints = dailyPoints.TotalPoints + int32(roundedPoints)
+		dailyPoints.TotalPoints = dailyPoints.TotalPoints + points
 		if dailyPoints.TotalPoints > dailyPoints.GoalPoints && !isGoalCompleted {
 			stopChan <- struct{}{}
 			err := apiCfg.DB.SetGoalCompleted(r.Context(), user.ID)
@@ -443,6 +485,8 @@ func (apiCfg *apiConfig) SetSpecificActivityLog(w http.ResponseWriter, r *http.R
 				respondWithError(w, 400, fmt.Sprintf("Error setting goal completed: %v", err))
 				return
 			}
+			apiCfg.notify(r.Context(), user.ID, EventGoalCompleted, GoalCompletedEvent{GoalPoints: dailyPoints.GoalPoints, TotalPoints: dailyPoints.TotalPoints})
+			apiCfg.emitUserWebhookEvent(r.Context(), user.ID, WebhookGoalCompleted, GoalCompletedEvent{GoalPoints: dailyPoints.GoalPoints, TotalPoints: dailyPoints.TotalPoints})
 			return
 		}
 		if dailyPoints.GoalPoints > dailyPoints.TotalPoints && isGoalCompleted {
@@ -465,6 +509,11 @@ func (apiCfg *apiConfig) SetSpecificActivityLog(w http.ResponseWriter, r *http.R
 			respondWithError(w, 400, fmt.Sprintf("Error getting streak info: %v", err))
 			return
 		}
+		err = apiCfg.breakStreakAfterGap(r.Context(), user.ID, &streakInfo)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Error updating streak info: %v", err))
+			return
+		}
 		yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
 		if streakInfo.LastLoggedDate.Valid && streakInfo.LastLoggedDate.Time.Format("2006-01-02") == yesterday {
 			streakInfo.CurrentStreak += 1
@@ -485,6 +534,9 @@ func (apiCfg *apiConfig) SetSpecificActivityLog(w http.ResponseWriter, r *http.R
 			respondWithError(w, 400, fmt.Sprintf("Error updating streak info: %v", err))
 			return
 		}
+		if isStreakRecord {
+			apiCfg.notify(r.Context(), user.ID, EventStreakRecord, StreakRecordEvent{CurrentStreak: streakInfo.CurrentStreak, LongestStreak: streakInfo.LongestStreak})
+		}
 		respondWithJson(w, 200, ActivityLogResponse{StreakCount: streakInfo.CurrentStreak, IsStreakRecord: isStreakRecord})
 		return
 	}
@@ -542,19 +594,10 @@ func (apiCfg *apiConfig) GetDailyStats(w http.ResponseWriter, r *http.Request, u
 		respondWithError(w, 400, fmt.Sprintf("Error getting streak info: %v", err))
 		return
 	}
-	if streakInfo.LastLoggedDate.Time.Format("2006-01-02") < yesterday.Format("2006-01-02") && streakInfo.CurrentStreak != 0 {
-		streakInfo.CurrentStreak = 0
-		err = apiCfg.DB.UpdateStreakData(r.Context(), database.UpdateStreakDataParams{
-			UserID:         user.ID,
-			CurrentStreak:  streakInfo.CurrentStreak,
-			LongestStreak:  streakInfo.LongestStreak,
-			LastLoggedDate: sql.NullTime{Valid: true, Time: time.Now()},
-		})
-		if err != nil {
-			respondWithError(w, 400, fmt.Sprintf("Error updating streak info: %v", err))
-			return
-		}
-		message = "😔 Oops, your streak ended yesterday. But every day is a new opportunity! Start fresh today and build it back up! 🌟💪"
+	err = apiCfg.breakStreakAfterGap(r.Context(), user.ID, &streakInfo)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error updating streak info: %v", err))
+		return
 	}
 
 	if streakInfo.LastLoggedDate.Time.Format("2006-01-02") < yesterday.Format("2006-01-02") {
@@ -581,3 +624,26 @@ func (apiCfg *apiConfig) GetDailyStats(w http.ResponseWriter, r *http.Request, u
 	}
 	respondWithJson(w, 200, DatabaseDailyStatsToDailyStats(dbDailyStats))
 }
+
+// breakStreakAfterGap ends the streak when a day was skipped since the last
+// log and sends streak.broken. Only a streak that is still running is reset,
+// so whichever request notices the gap first is the one to send the event.
+func (apiCfg *apiConfig) breakStreakAfterGap(ctx context.Context, userID uuid.UUID, streakInfo *database.GetStreakDataRow) error {
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	if streakInfo.CurrentStreak == 0 || !streakInfo.LastLoggedDate.Valid || streakInfo.LastLoggedDate.Time.Format("2006-01-02") >= yesterday {
+		return nil
+	}
+	brokenStreak := streakInfo.CurrentStreak
+	streakInfo.CurrentStreak = 0
+	broken, err := apiCfg.DB.BreakStreak(ctx, database.BreakStreakParams{
+		UserID:         userID,
+		LastLoggedDate: streakInfo.LastLoggedDate,
+	})
+	if err != nil {
+		return err
+	}
+	if broken == 1 {
+		apiCfg.emitUserWebhookEvent(ctx, userID, WebhookStreakBroken, StreakBrokenWebhook{PreviousStreak: brokenStreak, LongestStreak: streakInfo.LongestStreak})
+	}
+	return nil
+}
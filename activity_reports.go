@@ -13,6 +13,8 @@ type DatabaseProductivityStats struct {
 	BestProductivityDay        database.GetBestProductivityDayRow
 	ProductivityDays           []database.GetProductivityDaysRow
 	ProductiveUnProductiveTime database.GetProductiveUnProductiveTimeRow
+	Categories                 []database.GetCategoryBreakdownRow
+	Tags                       []database.GetTagBreakdownRow
 }
 
 func (apiCfg *apiConfig) GetProductivityStats(w http.ResponseWriter, r *http.Request, user database.User) {
@@ -68,11 +70,35 @@ func (apiCfg *apiConfig) GetProductivityStats(w http.ResponseWriter, r *http.Req
 		LoggedAt_2: params.EndTime,
 		UserID:     user.ID,
 	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting productive time: %v", err))
+		return
+	}
+	categories, err := apiCfg.DB.GetCategoryBreakdown(r.Context(), database.GetCategoryBreakdownParams{
+		UserID:     user.ID,
+		LoggedAt:   params.StartTime,
+		LoggedAt_2: params.EndTime,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting category breakdown: %v", err))
+		return
+	}
+	tags, err := apiCfg.DB.GetTagBreakdown(r.Context(), database.GetTagBreakdownParams{
+		UserID:     user.ID,
+		LoggedAt:   params.StartTime,
+		LoggedAt_2: params.EndTime,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting tag breakdown: %v", err))
+		return
+	}
 	databaseProductivityStats := DatabaseProductivityStats{
 		ProductivityPoints:         productivityPoints,
 		BestProductivityDay:        bestProductivityDay,
 		ProductivityDays:           productivityDays,
 		ProductiveUnProductiveTime: productiveUnproductiveTime,
+		Categories:                 categories,
+		Tags:                       tags,
 	}
 	respondWithJson(w, 200, databaseProductivityStatsToProductivityStats(databaseProductivityStats))
 }
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// The curated pack new accounts get when they ask for default activities.
+	starterActivityTemplate = "starter"
+	maxActivityTemplateName = 100
+	maxTemplateActivities   = 50
+)
+
+// GetActivityTemplates lists the curated packs and the published templates of
+// every user along with the user's own ones, mine=true lists only the latter.
+func (apiCfg *apiConfig) GetActivityTemplates(w http.ResponseWriter, r *http.Request, user database.User) {
+	dbTemplates, err := apiCfg.DB.GetActivityTemplates(r.Context(), database.GetActivityTemplatesParams{
+		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		Mine:   r.URL.Query().Get("mine") == "true",
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting activity templates: %s", err))
+		return
+	}
+	templateIDs := []uuid.UUID{}
+	for _, dbTemplate := range dbTemplates {
+		templateIDs = append(templateIDs, dbTemplate.ID)
+	}
+	dbItems, err := apiCfg.DB.GetActivityTemplateItems(r.Context(), templateIDs)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting activity template items: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseActivityTemplatesToActivityTemplates(dbTemplates, dbItems))
+}
+
+func (apiCfg *apiConfig) GetActivityTemplate(w http.ResponseWriter, r *http.Request, user database.User) {
+	dbTemplate, dbItems, ok := apiCfg.getVisibleActivityTemplate(w, r, user)
+	if !ok {
+		return
+	}
+	respondWithJson(w, 200, databaseActivityTemplatesToActivityTemplates([]database.GetActivityTemplatesRow{dbTemplate}, dbItems)[0])
+}
+
+// CreateActivityTemplate saves a copy of some of the user's activities as a
+// template. It stays private until it is published.
+func (apiCfg *apiConfig) CreateActivityTemplate(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		ActivityIDs []string `json:"activity_ids"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
+	name := strings.TrimSpace(params.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxActivityTemplateName {
+		respondWithError(w, 400, fmt.Sprintf("Template name must be between 1 and %d characters long", maxActivityTemplateName))
+		return
+	}
+	if len(params.ActivityIDs) == 0 || len(params.ActivityIDs) > maxTemplateActivities {
+		respondWithError(w, 400, fmt.Sprintf("A template must have between 1 and %d activities", maxTemplateActivities))
+		return
+	}
+	activities := []database.UserActivity{}
+	names := map[string]bool{}
+	for _, activityID := range params.ActivityIDs {
+		parsedActivityUUID, err := uuid.Parse(activityID)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Error in parsing activity uuid: %s", err))
+			return
+		}
+		activity, err := apiCfg.DB.GetUserActivity(r.Context(), database.GetUserActivityParams{
+			ID:     parsedActivityUUID,
+			UserID: user.ID,
+		})
+		if err == sql.ErrNoRows {
+			respondWithError(w, 404, fmt.Sprintf("Activity not found: %s", activityID))
+			return
+		} else if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error getting activity: %s", err))
+			return
+		}
+		if names[activityNameKey(activity.Name)] {
+			continue
+		}
+		names[activityNameKey(activity.Name)] = true
+		activities = append(activities, activity)
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	template, err := qtx.CreateActivityTemplate(r.Context(), database.CreateActivityTemplateParams{
+		ID:          uuid.New(),
+		Name:        name,
+		Description: strings.TrimSpace(params.Description),
+		CreatedBy:   uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error creating activity template: %s", err))
+		return
+	}
+	dbItems := []database.ActivityTemplateItem{}
+	for _, activity := range activities {
+		item := database.CreateActivityTemplateItemParams{
+			ID:                      uuid.New(),
+			TemplateID:              template.ID,
+			Name:                    activity.Name,
+			Points:                  activity.Points,
+			ScoringModel:            activity.ScoringModel,
+			ScoringDailyCap:         activity.ScoringDailyCap,
+			ScoringThresholdMinutes: activity.ScoringThresholdMinutes,
+			Category:                activity.Category,
+			Tags:                    activity.Tags,
+			Icon:                    activity.Icon,
+			Colour:                  activity.Colour,
+		}
+		if item.Tags == nil {
+			item.Tags = []string{}
+		}
+		err = qtx.CreateActivityTemplateItem(r.Context(), item)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error creating activity template item: %s", err))
+			return
+		}
+		dbItems = append(dbItems, database.ActivityTemplateItem(item))
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	dbTemplate := database.GetActivityTemplatesRow{
+		ID:              template.ID,
+		Name:            template.Name,
+		Description:     template.Description,
+		CreatedBy:       template.CreatedBy,
+		CreatorUsername: sql.NullString{String: user.Username, Valid: true},
+		CreatedAt:       template.CreatedAt,
+		UpdatedAt:       template.UpdatedAt,
+	}
+	respondWithJson(w, 200, databaseActivityTemplatesToActivityTemplates([]database.GetActivityTemplatesRow{dbTemplate}, dbItems)[0])
+}
+
+func (apiCfg *apiConfig) DeleteActivityTemplate(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedTemplateUUID, err := uuid.Parse(r.PathValue("templateid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing template uuid: %s", err))
+		return
+	}
+	deleted, err := apiCfg.DB.DeleteActivityTemplate(r.Context(), database.DeleteActivityTemplateParams{
+		ID:        parsedTemplateUUID,
+		CreatedBy: uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error deleting activity template: %s", err))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "Activity template not found")
+		return
+	}
+	respondWithJson(w, 200, "Activity template deleted successfully")
+}
+
+// PublishActivityTemplate lets every user see and import the template.
+func (apiCfg *apiConfig) PublishActivityTemplate(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.setActivityTemplatePublished(w, r, user, true)
+}
+
+func (apiCfg *apiConfig) UnpublishActivityTemplate(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.setActivityTemplatePublished(w, r, user, false)
+}
+
+func (apiCfg *apiConfig) setActivityTemplatePublished(w http.ResponseWriter, r *http.Request, user database.User, published bool) {
+	parsedTemplateUUID, err := uuid.Parse(r.PathValue("templateid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing template uuid: %s", err))
+		return
+	}
+	updated, err := apiCfg.DB.SetActivityTemplatePublishedAt(r.Context(), database.SetActivityTemplatePublishedAtParams{
+		PublishedAt: sql.NullTime{Time: time.Now().UTC(), Valid: published},
+		ID:          parsedTemplateUUID,
+		CreatedBy:   uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in publishing activity template: %s", err))
+		return
+	}
+	if updated == 0 {
+		respondWithError(w, 404, "Activity template not found")
+		return
+	}
+	if published {
+		respondWithJson(w, 200, "Activity template published successfully")
+		return
+	}
+	respondWithJson(w, 200, "Activity template unpublished successfully")
+}
+
+// ImportActivityTemplate adds the template's activities to the user's own.
+func (apiCfg *apiConfig) ImportActivityTemplate(w http.ResponseWriter, r *http.Request, user database.User) {
+	_, dbItems, ok := apiCfg.getVisibleActivityTemplate(w, r, user)
+	if !ok {
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	result, err := importActivityTemplate(r.Context(), qtx, user.ID, dbItems)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error importing activity template: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, result)
+}
+
+// ImportActivityTemplateToTeam adds the template's activities to a team's
+// activities, available to the given roles.
+func (apiCfg *apiConfig) ImportActivityTemplateToTeam(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		ActivityRoleIDs []string `json:"activity_role_ids"`
+	}
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing uuid: %s", err))
+		return
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	roleIDs, ok := apiCfg.parseTeamRoleIDs(w, r, parsedTeamUUID, params.ActivityRoleIDs)
+	if !ok {
+		return
+	}
+	_, dbItems, ok := apiCfg.getVisibleActivityTemplate(w, r, user)
+	if !ok {
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	teamActivities, err := qtx.GetTeamActivities(r.Context(), database.GetTeamActivitiesParams{
+		TeamID:          parsedTeamUUID,
+		IncludeArchived: true,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team activities: %s", err))
+		return
+	}
+	names := map[string]bool{}
+	for _, teamActivity := range teamActivities {
+		names[activityNameKey(teamActivity.ActivityName)] = true
+	}
+	result := ActivityTemplateImport{Imported: []string{}, Skipped: []string{}}
+	for _, item := range dbItems {
+		if names[activityNameKey(item.Name)] {
+			result.Skipped = append(result.Skipped, item.Name)
+			continue
+		}
+		names[activityNameKey(item.Name)] = true
+		teamActivityID := uuid.New()
+		err = qtx.SetTeamActivity(r.Context(), database.SetTeamActivityParams{
+			ID:           teamActivityID,
+			TeamID:       parsedTeamUUID,
+			ActivityName: item.Name,
+			Points:       item.Points,
+			CreatedAt:    time.Now().UTC(),
+			UpdatedAt:    time.Now().UTC(),
+		})
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error in creating team activity: %s", err))
+			return
+		}
+		for _, roleID := range roleIDs {
+			err = qtx.SetTeamActivityRole(r.Context(), database.SetTeamActivityRoleParams{
+				TeamActivityID: teamActivityID,
+				RoleID:         roleID,
+			})
+			if err != nil {
+				respondWithError(w, 500, fmt.Sprintf("Error in setting team activity roles: %s", err))
+				return
+			}
+		}
+		result.Imported = append(result.Imported, item.Name)
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, result)
+}
+
+// getVisibleActivityTemplate resolves the {templateid} path value to a curated,
+// published or own template along with its activities.
+func (apiCfg *apiConfig) getVisibleActivityTemplate(w http.ResponseWriter, r *http.Request, user database.User) (database.GetActivityTemplatesRow, []database.ActivityTemplateItem, bool) {
+	parsedTemplateUUID, err := uuid.Parse(r.PathValue("templateid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing template uuid: %s", err))
+		return database.GetActivityTemplatesRow{}, nil, false
+	}
+	dbTemplate, err := apiCfg.DB.GetActivityTemplate(r.Context(), database.GetActivityTemplateParams{
+		ID:     parsedTemplateUUID,
+		UserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Activity template not found")
+		return database.GetActivityTemplatesRow{}, nil, false
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting activity template: %s", err))
+		return database.GetActivityTemplatesRow{}, nil, false
+	}
+	dbItems, err := apiCfg.DB.GetActivityTemplateItems(r.Context(), []uuid.UUID{dbTemplate.ID})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting activity template items: %s", err))
+		return database.GetActivityTemplatesRow{}, nil, false
+	}
+	return database.GetActivityTemplatesRow(dbTemplate), dbItems, true
+}
+
+// importActivityTemplate creates an activity for every template item, skipping
+// the ones the user already has an activity with the same name for. Archived
+// activities count, deleted ones do not.
+func importActivityTemplate(ctx context.Context, q *database.Queries, userID uuid.UUID, dbItems []database.ActivityTemplateItem) (ActivityTemplateImport, error) {
+	activities, err := q.GetActivities(ctx, database.GetActivitiesParams{
+		UserID:   userID,
+		Archived: "all",
+	})
+	if err != nil {
+		return ActivityTemplateImport{}, err
+	}
+	names := map[string]bool{}
+	for _, activity := range activities {
+		names[activityNameKey(activity.Name)] = true
+	}
+	result := ActivityTemplateImport{Imported: []string{}, Skipped: []string{}}
+	for _, item := range dbItems {
+		if names[activityNameKey(item.Name)] {
+			result.Skipped = append(result.Skipped, item.Name)
+			continue
+		}
+		names[activityNameKey(item.Name)] = true
+		tags := item.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		_, err = q.SetActivity(ctx, database.SetActivityParams{
+			ID:                      uuid.New(),
+			UserID:                  userID,
+			Name:                    item.Name,
+			Points:                  item.Points,
+			ActivityType:            "default",
+			ScoringModel:            item.ScoringModel,
+			ScoringDailyCap:         item.ScoringDailyCap,
+			ScoringThresholdMinutes: item.ScoringThresholdMinutes,
+			Category:                item.Category,
+			Tags:                    tags,
+			Icon:                    item.Icon,
+			Colour:                  item.Colour,
+		})
+		if err != nil {
+			return ActivityTemplateImport{}, err
+		}
+		result.Imported = append(result.Imported, item.Name)
+	}
+	return result, nil
+}
+
+// activityNameKey is what two activity names are compared by when looking for
+// duplicates.
+func activityNameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
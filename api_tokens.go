@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	ScopeReadLogs  = "read:logs"
+	ScopeWriteLogs = "write:logs"
+	ScopeReadStats = "read:stats"
+	ScopeTeams     = "teams"
+)
+
+// Personal API tokens start with this prefix, which is how the auth
+// middleware tells them apart from JWTs.
+const (
+	apiTokenPrefix      = "pat_"
+	apiTokenShownLength = 12
+	maxApiTokensPerUser = 20
+)
+
+var apiTokenScopes = []string{ScopeReadLogs, ScopeWriteLogs, ScopeReadStats, ScopeTeams}
+
+// authenticateApiToken resolves the owner of a personal API token and records
+// that the token was used.
+func (apiCfg *apiConfig) authenticateApiToken(ctx context.Context, tokenString string) (database.User, database.ApiToken, error) {
+	apiToken, err := apiCfg.DB.GetApiTokenByHash(ctx, hashToken(tokenString))
+	if err == sql.ErrNoRows {
+		return database.User{}, database.ApiToken{}, &authError{"invalid API token"}
+	} else if err != nil {
+		return database.User{}, database.ApiToken{}, err
+	}
+	if apiToken.ExpiresAt.Valid && apiToken.ExpiresAt.Time.Before(time.Now()) {
+		return database.User{}, database.ApiToken{}, &authError{"API token expired"}
+	}
+	user, err := apiCfg.DB.GetUserById(ctx, apiToken.UserID)
+	if err != nil {
+		return database.User{}, database.ApiToken{}, err
+	}
+	if user.DeletionScheduledAt.Valid {
+		return database.User{}, database.ApiToken{}, &authError{"account scheduled for deletion"}
+	}
+	err = apiCfg.DB.TouchApiToken(ctx, apiToken.ID)
+	if err != nil {
+		log.Printf("Error updating last use of API token %s: %s", apiToken.ID, err)
+	}
+	return user, apiToken, nil
+}
+
+func (apiCfg *apiConfig) CreateApiToken(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int32    `json:"expires_in_days"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
+	if params.Name == "" {
+		respondWithError(w, 400, "Token name is required")
+		return
+	}
+	if len(params.Scopes) == 0 {
+		respondWithError(w, 400, fmt.Sprintf("At least one scope is required , available scopes: %v", apiTokenScopes))
+		return
+	}
+	for _, scope := range params.Scopes {
+		if !slices.Contains(apiTokenScopes, scope) {
+			respondWithError(w, 400, fmt.Sprintf("Unknown scope %s , available scopes: %v", scope, apiTokenScopes))
+			return
+		}
+	}
+	scopes := slices.Clone(params.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+	if params.ExpiresInDays < 0 {
+		respondWithError(w, 400, "expires_in_days cannot be negative")
+		return
+	}
+	expiresAt := sql.NullTime{}
+	if params.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().AddDate(0, 0, int(params.ExpiresInDays)), Valid: true}
+	}
+	existingTokens, err := apiCfg.DB.GetUserApiTokens(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting API tokens: %s", err))
+		return
+	}
+	if len(existingTokens) >= maxApiTokensPerUser {
+		respondWithError(w, 409, fmt.Sprintf("You cannot have more than %d API tokens", maxApiTokensPerUser))
+		return
+	}
+	secret, err := generateResetToken()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error generating API token: %s", err))
+		return
+	}
+	token := apiTokenPrefix + secret
+	apiToken, err := apiCfg.DB.CreateApiToken(r.Context(), database.CreateApiTokenParams{
+		ID:          uuid.New(),
+		UserID:      user.ID,
+		Name:        params.Name,
+		TokenHash:   hashToken(token),
+		TokenPrefix: token[:apiTokenShownLength],
+		Scopes:      scopes,
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error creating API token: %s", err))
+		return
+	}
+	// The token itself is only returned once, afterwards only its prefix is known.
+	respondWithJson(w, 200, struct {
+		ApiToken
+		Token string `json:"token"`
+	}{ApiToken: databaseApiTokenToApiToken(apiToken), Token: token})
+}
+
+func (apiCfg *apiConfig) GetUserApiTokens(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiTokens, err := apiCfg.DB.GetUserApiTokens(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting API tokens: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseApiTokensToApiTokens(apiTokens))
+}
+
+func (apiCfg *apiConfig) RevokeApiToken(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedTokenUUID, err := uuid.Parse(r.PathValue("tokenid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing token uuid: %s", err))
+		return
+	}
+	deleted, err := apiCfg.DB.DeleteUserApiToken(r.Context(), database.DeleteUserApiTokenParams{
+		ID:     parsedTokenUUID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error revoking API token: %s", err))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, 404, "API token not found")
+		return
+	}
+	respondWithJson(w, 200, "API token revoked successfully")
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"log"
+	"time"
+)
+
+const (
+	wsNotifyChannel = "ws_events"
+	// NOTIFY payloads must be shorter than this many bytes.
+	wsNotifyPayloadLimit = 8000
+)
+
+// Backplane carries websocket events between backend instances so that an
+// event published on one replica reaches the user's sockets on every replica.
+type Backplane interface {
+	// Publish hands the event to the backplane without blocking the caller.
+	Publish(userID uuid.UUID, message []byte)
+	// Subscribe registers the function every published event is delivered to.
+	Subscribe(deliver func(userID uuid.UUID, message []byte))
+}
+
+type backplaneMessage struct {
+	UserID uuid.UUID       `json:"user_id"`
+	Event  json.RawMessage `json:"event,omitempty"`
+	// NotificationID is sent instead of an event too large for NOTIFY, the
+	// receivers load the notification it was created from.
+	NotificationID uuid.UUID `json:"notification_id"`
+}
+
+// memoryBackplane delivers events straight back into the local hub. It is
+// enough when a single instance is running.
+type memoryBackplane struct {
+	deliver func(userID uuid.UUID, message []byte)
+}
+
+func newMemoryBackplane() *memoryBackplane {
+	return &memoryBackplane{}
+}
+
+func (b *memoryBackplane) Publish(userID uuid.UUID, message []byte) {
+	if b.deliver != nil {
+		b.deliver(userID, message)
+	}
+}
+
+func (b *memoryBackplane) Subscribe(deliver func(userID uuid.UUID, message []byte)) {
+	b.deliver = deliver
+}
+
+// postgresBackplane fans events out with NOTIFY on a shared channel that every
+// instance LISTENs on, including the one that published the event.
+type postgresBackplane struct {
+	db        *sql.DB
+	listener  *pq.Listener
+	outbox    chan []byte
+	loadEvent func(ctx context.Context, notificationID uuid.UUID) ([]byte, error)
+}
+
+func newPostgresBackplane(dbUrl string, db *sql.DB, loadEvent func(ctx context.Context, notificationID uuid.UUID) ([]byte, error)) (*postgresBackplane, error) {
+	listener := pq.NewListener(dbUrl, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Websocket backplane listener error: %s", err)
+		}
+	})
+	err := listener.Listen(wsNotifyChannel)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	b := &postgresBackplane{db: db, listener: listener, outbox: make(chan []byte, 256), loadEvent: loadEvent}
+	go b.notifyLoop()
+	return b, nil
+}
+
+func (b *postgresBackplane) Publish(userID uuid.UUID, message []byte) {
+	payload, err := encodeBackplaneMessage(userID, message)
+	if err != nil {
+		log.Printf("Error encoding backplane message for user %s: %s", userID, err)
+		return
+	}
+	select {
+	case b.outbox <- payload:
+	default:
+		log.Printf("Websocket backplane outbox is full, dropping event for user %s", userID)
+	}
+}
+
+func (b *postgresBackplane) Subscribe(deliver func(userID uuid.UUID, message []byte)) {
+	go func() {
+		for notification := range b.listener.Notify {
+			// A nil notification means the connection was re-established and
+			// events sent in the meantime were missed.
+			if notification == nil {
+				log.Println("Websocket backplane listener reconnected")
+				continue
+			}
+			message := backplaneMessage{}
+			err := json.Unmarshal([]byte(notification.Extra), &message)
+			if err != nil {
+				log.Printf("Error decoding backplane message: %s", err)
+				continue
+			}
+			event := []byte(message.Event)
+			if message.NotificationID != uuid.Nil {
+				event, err = b.loadEvent(context.Background(), message.NotificationID)
+				if err != nil {
+					log.Printf("Error loading notification %s for backplane: %s", message.NotificationID, err)
+					continue
+				}
+			}
+			deliver(message.UserID, event)
+		}
+	}()
+}
+
+// encodeBackplaneMessage wraps the event for NOTIFY. An event too large to
+// fit is replaced by the id of its notification.
+func encodeBackplaneMessage(userID uuid.UUID, message []byte) ([]byte, error) {
+	payload, err := json.Marshal(backplaneMessage{UserID: userID, Event: message})
+	if err != nil || len(payload) < wsNotifyPayloadLimit {
+		return payload, err
+	}
+	event := struct {
+		ID uuid.UUID `json:"id"`
+	}{}
+	err = json.Unmarshal(message, &event)
+	if err != nil {
+		return nil, err
+	}
+	if event.ID == uuid.Nil {
+		return nil, errors.New("event is too large and has no notification id")
+	}
+	return json.Marshal(backplaneMessage{UserID: userID, NotificationID: event.ID})
+}
+
+func (b *postgresBackplane) notifyLoop() {
+	for payload := range b.outbox {
+		_, err := b.db.Exec("SELECT pg_notify($1, $2)", wsNotifyChannel, string(payload))
+		if err != nil {
+			log.Printf("Error publishing to websocket backplane: %s", err)
+		}
+	}
+}
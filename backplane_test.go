@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"strings"
+	"testing"
+)
+
+func TestEncodeBackplaneMessage(t *testing.T) {
+	userID := uuid.New()
+	small, _ := json.Marshal(Event{ID: uuid.New(), Type: EventGoalCompleted})
+	large, _ := json.Marshal(Event{ID: uuid.New(), Type: EventInviteReceived, Data: strings.Repeat("x", wsNotifyPayloadLimit)})
+
+	payload, err := encodeBackplaneMessage(userID, small)
+	if err != nil {
+		t.Fatalf("encoding small event: %s", err)
+	}
+	message := backplaneMessage{}
+	json.Unmarshal(payload, &message)
+	if message.UserID != userID || string(message.Event) != string(small) || message.NotificationID != uuid.Nil {
+		t.Errorf("small event encoded as %s", payload)
+	}
+
+	payload, err = encodeBackplaneMessage(userID, large)
+	if err != nil {
+		t.Fatalf("encoding large event: %s", err)
+	}
+	if len(payload) >= wsNotifyPayloadLimit {
+		t.Errorf("large event encoded to %d bytes", len(payload))
+	}
+	event := Event{}
+	json.Unmarshal(large, &event)
+	message = backplaneMessage{}
+	json.Unmarshal(payload, &message)
+	if message.UserID != userID || message.Event != nil || message.NotificationID != event.ID {
+		t.Errorf("large event encoded as %s, want only notification %s", payload, event.ID)
+	}
+
+	withoutID, _ := json.Marshal(Event{Type: EventInviteReceived, Data: strings.Repeat("x", wsNotifyPayloadLimit)})
+	if _, err := encodeBackplaneMessage(userID, withoutID); err == nil {
+		t.Error("large event without a notification id was encoded")
+	}
+}
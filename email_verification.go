@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	emailVerificationTTL = 24 * time.Hour
+	// Minimum time between two verification emails for the same user.
+	emailVerificationCooldown   = time.Minute
+	emailVerificationDailyLimit = 5
+)
+
+// sendEmailVerification issues a new verification token for the user and
+// mails the link. Only the hash of the token is stored.
+func (apiCfg *apiConfig) sendEmailVerification(ctx context.Context, user database.User) error {
+	token, err := apiCfg.createEmailVerification(ctx, user, sql.NullString{})
+	if err != nil {
+		return err
+	}
+	return sendVerificationEmail(user.Email, token)
+}
+
+// sendEmailChangeVerification mails the link confirming an email change to
+// the new address. The account keeps its current email until then.
+func (apiCfg *apiConfig) sendEmailChangeVerification(ctx context.Context, user database.User, newEmail string) error {
+	token, err := apiCfg.createEmailVerification(ctx, user, sql.NullString{String: newEmail, Valid: true})
+	if err != nil {
+		return err
+	}
+	return sendVerificationEmail(newEmail, token)
+}
+
+func (apiCfg *apiConfig) createEmailVerification(ctx context.Context, user database.User, newEmail sql.NullString) (string, error) {
+	token, err := generateResetToken()
+	if err != nil {
+		return "", err
+	}
+	err = apiCfg.DB.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(emailVerificationTTL),
+		NewEmail:  newEmail,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (apiCfg *apiConfig) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error decoding request body: %s", err))
+		return
+	}
+	verification, err := apiCfg.DB.GetEmailVerification(r.Context(), hashToken(params.Token))
+	if err == sql.ErrNoRows {
+		respondWithError(w, 400, "Invalid verification token")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting email verification: %s", err))
+		return
+	}
+	if verification.ExpiresAt.Before(time.Now()) {
+		respondWithError(w, 400, "Verification token expired , please request a new one")
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	if verification.NewEmail.Valid {
+		err = qtx.SetUserEmail(r.Context(), database.SetUserEmailParams{
+			ID:    verification.UserID,
+			Email: verification.NewEmail.String,
+		})
+		if isUniqueViolation(err) {
+			respondWithError(w, 409, "This email is already used by another account")
+			return
+		}
+	} else {
+		err = qtx.SetUserEmailVerified(r.Context(), verification.UserID)
+	}
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error verifying email: %s", err))
+		return
+	}
+	err = qtx.DeleteUserEmailVerifications(r.Context(), verification.UserID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error deleting email verifications: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Email verified successfully")
+}
+
+func (apiCfg *apiConfig) ResendEmailVerification(w http.ResponseWriter, r *http.Request, user database.User) {
+	if user.EmailVerified {
+		respondWithError(w, 409, "Email is already verified")
+		return
+	}
+	if !apiCfg.checkEmailVerificationLimits(w, r, user) {
+		return
+	}
+	err := apiCfg.sendEmailVerification(r.Context(), user)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error sending verification email: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Verification email sent successfully")
+}
+
+// requireVerifiedEmail answers with a 403 and returns false when the user has
+// not verified their email address yet.
+func requireVerifiedEmail(w http.ResponseWriter, user database.User) bool {
+	if !user.EmailVerified {
+		respondWithError(w, 403, "Please verify your email address first")
+		return false
+	}
+	return true
+}
+
+// checkEmailVerificationLimits answers with a 429 and returns false when the
+// user asked for a verification email too recently or too often today.
+func (apiCfg *apiConfig) checkEmailVerificationLimits(w http.ResponseWriter, r *http.Request, user database.User) bool {
+	recentCount, err := apiCfg.DB.CountEmailVerificationsSince(r.Context(), database.CountEmailVerificationsSinceParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().Add(-emailVerificationCooldown),
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error counting email verifications: %s", err))
+		return false
+	}
+	if recentCount > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(emailVerificationCooldown.Seconds())))
+		respondWithError(w, 429, "Please wait before requesting another verification email")
+		return false
+	}
+	dailyCount, err := apiCfg.DB.CountEmailVerificationsSince(r.Context(), database.CountEmailVerificationsSinceParams{
+		UserID:    user.ID,
+		CreatedAt: time.Now().Add(-24 * time.Hour),
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error counting email verifications: %s", err))
+		return false
+	}
+	if dailyCount >= emailVerificationDailyLimit {
+		respondWithError(w, 429, "Too many verification emails requested today")
+		return false
+	}
+	return true
+}
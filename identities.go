@@ -0,0 +1,291 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"time"
+)
+
+// IdentityPassword is the login method of accounts with a password, every
+// other identity belongs to one of the oidcProviders.
+const IdentityPassword = "password"
+
+const (
+	// How long a provider sign-in can wait for the password of the account it
+	// is being linked to.
+	identityLinkTTL      = 10 * time.Minute
+	identityLinkAudience = "identity-link"
+)
+
+// identityLinkClaims remembers a provider identity whose email matched an
+// existing account until the owner confirms the link with their password.
+type identityLinkClaims struct {
+	Provider        string `json:"provider"`
+	IdentitySubject string `json:"identity_sub"`
+	Email           string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// respondWithIdentityLinkRequired answers a provider sign-in whose email
+// belongs to an account without that identity. Only verified emails can be
+// linked, and only after POST /auth/link confirms the account's password.
+func respondWithIdentityLinkRequired(w http.ResponseWriter, user database.User, provider string, claims *oidcIDTokenClaims) {
+	if !claims.EmailVerified || !user.PasswordHash.Valid {
+		respondWithError(w, 409, "An account with this email already exists")
+		return
+	}
+	linkToken := jwt.NewWithClaims(jwt.SigningMethodHS256, identityLinkClaims{
+		Provider:        provider,
+		Email:           claims.Email,
+		IdentitySubject: claims.Subject,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID.String(),
+			Audience:  jwt.ClaimStrings{identityLinkAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(identityLinkTTL)),
+		},
+	})
+	signedLinkToken, err := linkToken.SignedString([]byte(jwtSecret))
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error creating link token: %s", err))
+		return
+	}
+	respondWithJson(w, 409, struct {
+		Error     string `json:"error"`
+		LinkToken string `json:"link_token"`
+		Provider  string `json:"provider"`
+		Email     string `json:"email"`
+	}{
+		Error:     "An account with this email already exists , confirm your password to link your account",
+		LinkToken: signedLinkToken,
+		Provider:  provider,
+		Email:     user.Email,
+	})
+}
+
+func (apiCfg *apiConfig) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		LinkToken string `json:"link_token"`
+		Password  string `json:"password"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
+		return
+	}
+	claims := &identityLinkClaims{}
+	_, err = jwt.ParseWithClaims(params.LinkToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(identityLinkAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		respondWithError(w, 400, "Invalid or expired link token")
+		return
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil || claims.IdentitySubject == "" {
+		respondWithError(w, 400, "Invalid or expired link token")
+		return
+	}
+	user, err := apiCfg.DB.GetUserById(r.Context(), userID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, 400, "Invalid or expired link token")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting user: %s", err))
+		return
+	}
+	if !apiCfg.checkLoginLockout(w, r, user) {
+		return
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(params.Password))
+	if !user.PasswordHash.Valid || err != nil {
+		err = apiCfg.recordLoginFailure(r, user)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error recording failed login: %s", err))
+			return
+		}
+		respondWithError(w, 401, "Invalid password")
+		return
+	}
+	_, mfaEnabled, err := apiCfg.getConfirmedTotp(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting two factor authentication: %s", err))
+		return
+	}
+	if !apiCfg.linkIdentity(w, r, user, claims.Provider, claims.IdentitySubject, claims.Email) {
+		return
+	}
+	// Link tokens are only issued for verified emails matching the account.
+	if !user.EmailVerified {
+		err = apiCfg.DB.SetUserEmailVerified(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error verifying email: %s", err))
+			return
+		}
+		user.EmailVerified = true
+	}
+	// The identity is linked once the password is confirmed, the session still
+	// needs the second factor.
+	if mfaEnabled {
+		mfaResponse, err := createMfaToken(user)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error in generating mfa token for user: %s", err))
+			return
+		}
+		respondWithJson(w, 200, mfaResponse)
+		return
+	}
+	err = apiCfg.DB.ClearLoginFailures(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error clearing failed logins: %s", err))
+		return
+	}
+	tokens, err := apiCfg.createSession(r, user)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in generating JWT token for user: %s", err))
+		return
+	}
+	respondWithJson(w, 200, tokens)
+}
+
+// linkIdentity stores the provider identity on the user. An identity can only
+// belong to one user, and a user has at most one identity per provider.
+func (apiCfg *apiConfig) linkIdentity(w http.ResponseWriter, r *http.Request, user database.User, provider string, subject string, email string) bool {
+	_, err := apiCfg.DB.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		ID:       uuid.New(),
+		UserID:   user.ID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	})
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+		switch pqErr.Constraint {
+		case "user_identities_provider_subject_key":
+			respondWithError(w, 409, "This account is linked to another user")
+		default:
+			respondWithError(w, 409, fmt.Sprintf("A %s account is already linked", provider))
+		}
+		return false
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error linking identity: %s", err))
+		return false
+	}
+	return true
+}
+
+func (apiCfg *apiConfig) GetUserIdentities(w http.ResponseWriter, r *http.Request, user database.User) {
+	identities, err := apiCfg.DB.GetUserIdentities(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting identities: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseIdentitiesToIdentities(user, identities))
+}
+
+func (apiCfg *apiConfig) StartIdentityLink(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.startOidcFlow(w, r, uuid.NullUUID{UUID: user.ID, Valid: true})
+}
+
+func (apiCfg *apiConfig) AddUserIdentity(w http.ResponseWriter, r *http.Request, user database.User) {
+	provider, claims, ok := apiCfg.finishOidcFlow(w, r, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if !ok {
+		return
+	}
+	if !apiCfg.linkIdentity(w, r, user, provider.Name, claims.Subject, claims.Email) {
+		return
+	}
+	respondWithJson(w, 200, fmt.Sprintf("%s account linked successfully", provider.DisplayName))
+}
+
+func (apiCfg *apiConfig) AddPasswordIdentity(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Password string `json:"password"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
+		return
+	}
+	if user.PasswordHash.Valid {
+		respondWithError(w, 409, "A password is already set")
+		return
+	}
+	err = validatePassword(params.Password)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
+	if err != nil {
+		respondWithError(w, 500, "Couldnt hash password")
+		return
+	}
+	err = apiCfg.DB.SetNewPassword(r.Context(), database.SetNewPasswordParams{
+		PasswordHash: sql.NullString{String: string(hashedPassword), Valid: true},
+		ID:           user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error setting password: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Password added successfully")
+}
+
+// RemoveUserIdentity removes the password or a provider identity, as long as
+// the account keeps at least one way to sign in.
+func (apiCfg *apiConfig) RemoveUserIdentity(w http.ResponseWriter, r *http.Request, user database.User) {
+	provider := r.PathValue("provider")
+	var removed int64
+	if provider == IdentityPassword {
+		if !user.PasswordHash.Valid {
+			respondWithError(w, 404, "No password is set")
+			return
+		}
+		var err error
+		removed, err = apiCfg.DB.RemoveUserPassword(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error removing password: %s", err))
+			return
+		}
+	} else {
+		identities, err := apiCfg.DB.GetUserIdentities(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error getting identities: %s", err))
+			return
+		}
+		linked := false
+		for _, identity := range identities {
+			if identity.Provider == provider {
+				linked = true
+			}
+		}
+		if !linked {
+			respondWithError(w, 404, "No such identity is linked")
+			return
+		}
+		removed, err = apiCfg.DB.DeleteUserIdentity(r.Context(), database.DeleteUserIdentityParams{
+			UserID:   user.ID,
+			Provider: provider,
+		})
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error removing identity: %s", err))
+			return
+		}
+	}
+	if removed == 0 {
+		respondWithError(w, 409, "Cannot remove the only login method of the account")
+		return
+	}
+	respondWithJson(w, 200, "Identity removed successfully")
+}
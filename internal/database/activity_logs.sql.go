@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const checkIfActivityLogExists = `-- name: CheckIfActivityLogExists :one
@@ -32,43 +33,86 @@ func (q *Queries) CheckIfActivityLogExists(ctx context.Context, arg CheckIfActiv
 	return exists, err
 }
 
-const deleteActivity = `-- name: DeleteActivity :exec
-DELETE FROM user_activities WHERE id = $1
+const deleteActivity = `-- name: DeleteActivity :execrows
+UPDATE user_activities SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
 `
 
-func (q *Queries) DeleteActivity(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteActivity, id)
-	return err
+type DeleteActivityParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
 }
 
-const editActivity = `-- name: EditActivity :exec
-UPDATE user_activities SET name = $1 , points = $2, activity_type = 'custom' WHERE id = $3
+func (q *Queries) DeleteActivity(ctx context.Context, arg DeleteActivityParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteActivity, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const editActivity = `-- name: EditActivity :execrows
+UPDATE user_activities SET name = $1 , points = $2, activity_type = 'custom', updated_at = NOW() WHERE id = $3 AND user_id = $4 AND deleted_at IS NULL
 `
 
 type EditActivityParams struct {
 	Name   string
 	Points int32
 	ID     uuid.UUID
+	UserID uuid.UUID
 }
 
-func (q *Queries) EditActivity(ctx context.Context, arg EditActivityParams) error {
-	_, err := q.db.ExecContext(ctx, editActivity, arg.Name, arg.Points, arg.ID)
-	return err
+func (q *Queries) EditActivity(ctx context.Context, arg EditActivityParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, editActivity,
+		arg.Name,
+		arg.Points,
+		arg.ID,
+		arg.UserID,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const getActivities = `-- name: GetActivities :many
-SELECT id , name , points , activity_type FROM user_activities WHERE user_id = $1 ORDER BY points DESC
+SELECT id , name , points , activity_type , scoring_model , scoring_daily_cap , scoring_threshold_minutes , category , tags , icon , colour , archived_at FROM user_activities
+WHERE user_id = $1
+  AND deleted_at IS NULL
+  AND ($2::text = '' OR category = $2::text)
+  AND ($3::text = '' OR $3::text = ANY(tags))
+  AND (CASE $4::text WHEN 'all' THEN TRUE WHEN 'true' THEN archived_at IS NOT NULL ELSE archived_at IS NULL END)
+ORDER BY points DESC
 `
 
+type GetActivitiesParams struct {
+	UserID   uuid.UUID
+	Category string
+	Tag      string
+	Archived string
+}
+
 type GetActivitiesRow struct {
-	ID           uuid.UUID
-	Name         string
-	Points       int32
-	ActivityType string
+	ID                      uuid.UUID
+	Name                    string
+	Points                  int32
+	ActivityType            string
+	ScoringModel            string
+	ScoringDailyCap         sql.NullInt32
+	ScoringThresholdMinutes sql.NullInt32
+	Category                string
+	Tags                    []string
+	Icon                    string
+	Colour                  string
+	ArchivedAt              sql.NullTime
 }
 
-func (q *Queries) GetActivities(ctx context.Context, userID uuid.UUID) ([]GetActivitiesRow, error) {
-	rows, err := q.db.QueryContext(ctx, getActivities, userID)
+func (q *Queries) GetActivities(ctx context.Context, arg GetActivitiesParams) ([]GetActivitiesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getActivities,
+		arg.UserID,
+		arg.Category,
+		arg.Tag,
+		arg.Archived,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -81,6 +125,14 @@ func (q *Queries) GetActivities(ctx context.Context, userID uuid.UUID) ([]GetAct
 			&i.Name,
 			&i.Points,
 			&i.ActivityType,
+			&i.ScoringModel,
+			&i.ScoringDailyCap,
+			&i.ScoringThresholdMinutes,
+			&i.Category,
+			pq.Array(&i.Tags),
+			&i.Icon,
+			&i.Colour,
+			&i.ArchivedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -150,6 +202,31 @@ func (q *Queries) GetDailyActivityLogsCount(ctx context.Context, userID uuid.UUI
 	return daily_activity_count, err
 }
 
+const getDailyActivityUsage = `-- name: GetDailyActivityUsage :one
+SELECT CAST(COALESCE(SUM(duration), 0) AS INT) AS minutes , CAST(COALESCE(SUM(points), 0) AS INT) AS points
+FROM user_activity_logs
+WHERE user_id = $1
+AND activity_id = $2
+AND DATE(logged_at) = CURRENT_DATE
+`
+
+type GetDailyActivityUsageParams struct {
+	UserID     uuid.UUID
+	ActivityID uuid.NullUUID
+}
+
+type GetDailyActivityUsageRow struct {
+	Minutes int32
+	Points  int32
+}
+
+func (q *Queries) GetDailyActivityUsage(ctx context.Context, arg GetDailyActivityUsageParams) (GetDailyActivityUsageRow, error) {
+	row := q.db.QueryRowContext(ctx, getDailyActivityUsage, arg.UserID, arg.ActivityID)
+	var i GetDailyActivityUsageRow
+	err := row.Scan(&i.Minutes, &i.Points)
+	return i, err
+}
+
 const getDailyMinutes = `-- name: GetDailyMinutes :one
 
 SELECT COALESCE(SUM(duration), 0)::BIGINT AS total_hours
@@ -252,23 +329,160 @@ func (q *Queries) GetRecentActivities(ctx context.Context, userID uuid.UUID) ([]
 	return items, nil
 }
 
+const getUserActivitiesForExport = `-- name: GetUserActivitiesForExport :many
+SELECT id, user_id, name, points, activity_type, created_at, updated_at, scoring_model, scoring_daily_cap, scoring_threshold_minutes, category, tags, icon, colour, archived_at, deleted_at FROM user_activities WHERE user_id = $1 ORDER BY created_at
+`
+
+func (q *Queries) GetUserActivitiesForExport(ctx context.Context, userID uuid.UUID) ([]UserActivity, error) {
+	rows, err := q.db.QueryContext(ctx, getUserActivitiesForExport, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserActivity
+	for rows.Next() {
+		var i UserActivity
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Name,
+			&i.Points,
+			&i.ActivityType,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ScoringModel,
+			&i.ScoringDailyCap,
+			&i.ScoringThresholdMinutes,
+			&i.Category,
+			pq.Array(&i.Tags),
+			&i.Icon,
+			&i.Colour,
+			&i.ArchivedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserActivity = `-- name: GetUserActivity :one
+SELECT id, user_id, name, points, activity_type, created_at, updated_at, scoring_model, scoring_daily_cap, scoring_threshold_minutes, category, tags, icon, colour, archived_at, deleted_at FROM user_activities WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+`
+
+type GetUserActivityParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetUserActivity(ctx context.Context, arg GetUserActivityParams) (UserActivity, error) {
+	row := q.db.QueryRowContext(ctx, getUserActivity, arg.ID, arg.UserID)
+	var i UserActivity
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Name,
+		&i.Points,
+		&i.ActivityType,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ScoringModel,
+		&i.ScoringDailyCap,
+		&i.ScoringThresholdMinutes,
+		&i.Category,
+		pq.Array(&i.Tags),
+		&i.Icon,
+		&i.Colour,
+		&i.ArchivedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUserActivityLogsForExport = `-- name: GetUserActivityLogsForExport :many
+SELECT user_activity_logs.id , activity_id , ua.name , duration , user_activity_logs.points , activity_description , logged_at FROM user_activity_logs LEFT JOIN user_activities ua ON ua.id = user_activity_logs.activity_id WHERE user_activity_logs.user_id = $1 ORDER BY logged_at
+`
+
+type GetUserActivityLogsForExportRow struct {
+	ID                  uuid.UUID
+	ActivityID          uuid.NullUUID
+	Name                sql.NullString
+	Duration            int32
+	Points              int32
+	ActivityDescription string
+	LoggedAt            time.Time
+}
+
+func (q *Queries) GetUserActivityLogsForExport(ctx context.Context, userID uuid.UUID) ([]GetUserActivityLogsForExportRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserActivityLogsForExport, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserActivityLogsForExportRow
+	for rows.Next() {
+		var i GetUserActivityLogsForExportRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActivityID,
+			&i.Name,
+			&i.Duration,
+			&i.Points,
+			&i.ActivityDescription,
+			&i.LoggedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const setActivity = `-- name: SetActivity :one
-INSERT INTO user_activities (id , user_id , name , points , activity_type ) VALUES ($1 , $2 , $3 , $4 , $5 ) RETURNING id , name , points , activity_type
+INSERT INTO user_activities (id , user_id , name , points , activity_type , scoring_model , scoring_daily_cap , scoring_threshold_minutes , category , tags , icon , colour ) VALUES ($1 , $2 , $3 , $4 , $5 , $6 , $7 , $8 , $9 , $10 , $11 , $12 ) RETURNING id , name , points , activity_type , scoring_model , scoring_daily_cap , scoring_threshold_minutes , category , tags , icon , colour , archived_at
 `
 
 type SetActivityParams struct {
-	ID           uuid.UUID
-	UserID       uuid.UUID
-	Name         string
-	Points       int32
-	ActivityType string
+	ID                      uuid.UUID
+	UserID                  uuid.UUID
+	Name                    string
+	Points                  int32
+	ActivityType            string
+	ScoringModel            string
+	ScoringDailyCap         sql.NullInt32
+	ScoringThresholdMinutes sql.NullInt32
+	Category                string
+	Tags                    []string
+	Icon                    string
+	Colour                  string
 }
 
 type SetActivityRow struct {
-	ID           uuid.UUID
-	Name         string
-	Points       int32
-	ActivityType string
+	ID                      uuid.UUID
+	Name                    string
+	Points                  int32
+	ActivityType            string
+	ScoringModel            string
+	ScoringDailyCap         sql.NullInt32
+	ScoringThresholdMinutes sql.NullInt32
+	Category                string
+	Tags                    []string
+	Icon                    string
+	Colour                  string
+	ArchivedAt              sql.NullTime
 }
 
 func (q *Queries) SetActivity(ctx context.Context, arg SetActivityParams) (SetActivityRow, error) {
@@ -278,6 +492,13 @@ func (q *Queries) SetActivity(ctx context.Context, arg SetActivityParams) (SetAc
 		arg.Name,
 		arg.Points,
 		arg.ActivityType,
+		arg.ScoringModel,
+		arg.ScoringDailyCap,
+		arg.ScoringThresholdMinutes,
+		arg.Category,
+		pq.Array(arg.Tags),
+		arg.Icon,
+		arg.Colour,
 	)
 	var i SetActivityRow
 	err := row.Scan(
@@ -285,10 +506,62 @@ func (q *Queries) SetActivity(ctx context.Context, arg SetActivityParams) (SetAc
 		&i.Name,
 		&i.Points,
 		&i.ActivityType,
+		&i.ScoringModel,
+		&i.ScoringDailyCap,
+		&i.ScoringThresholdMinutes,
+		&i.Category,
+		pq.Array(&i.Tags),
+		&i.Icon,
+		&i.Colour,
+		&i.ArchivedAt,
 	)
 	return i, err
 }
 
+const setActivityArchivedAt = `-- name: SetActivityArchivedAt :exec
+UPDATE user_activities SET archived_at = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3
+`
+
+type SetActivityArchivedAtParams struct {
+	ArchivedAt sql.NullTime
+	ID         uuid.UUID
+	UserID     uuid.UUID
+}
+
+func (q *Queries) SetActivityArchivedAt(ctx context.Context, arg SetActivityArchivedAtParams) error {
+	_, err := q.db.ExecContext(ctx, setActivityArchivedAt, arg.ArchivedAt, arg.ID, arg.UserID)
+	return err
+}
+
+const setActivityDetails = `-- name: SetActivityDetails :exec
+UPDATE user_activities
+SET category = COALESCE($1, category) ,
+    tags = COALESCE($2::text[], tags) ,
+    icon = COALESCE($3, icon) ,
+    colour = COALESCE($4, colour) ,
+    updated_at = NOW()
+WHERE id = $5
+`
+
+type SetActivityDetailsParams struct {
+	Category sql.NullString
+	Tags     []string
+	Icon     sql.NullString
+	Colour   sql.NullString
+	ID       uuid.UUID
+}
+
+func (q *Queries) SetActivityDetails(ctx context.Context, arg SetActivityDetailsParams) error {
+	_, err := q.db.ExecContext(ctx, setActivityDetails,
+		arg.Category,
+		pq.Array(arg.Tags),
+		arg.Icon,
+		arg.Colour,
+		arg.ID,
+	)
+	return err
+}
+
 const setActivityLog = `-- name: SetActivityLog :exec
 INSERT INTO user_activity_logs (id , user_id , activity_id , duration , points , logged_at , activity_description  ) VALUES ($1 , $2 , $3 , $4 , $5 , $6 , $7  )
 `
@@ -316,22 +589,23 @@ func (q *Queries) SetActivityLog(ctx context.Context, arg SetActivityLogParams)
 	return err
 }
 
-const setDefaultActivities = `-- name: SetDefaultActivities :exec
-INSERT INTO user_activities (id , user_id , name , points , activity_type ) 
-VALUES
-(uuid_generate_v4(), $1, 'Learning', 10, 'default'),
-(uuid_generate_v4(), $1, 'Exercise', 8, 'default'),
-(uuid_generate_v4(), $1, 'Meditation', 6, 'default'),
-(uuid_generate_v4(), $1, 'Reading', 4, 'default'),
-(uuid_generate_v4(), $1, 'Household Chores', 2, 'default'),
-(uuid_generate_v4(), $1, 'Watching Series', -2, 'default'),
-(uuid_generate_v4(), $1, 'Watching TV', -4, 'default'),
-(uuid_generate_v4(), $1, 'Gaming', -6, 'default'),
-(uuid_generate_v4(), $1, 'Social Media Scrolling', -8, 'default'),
-(uuid_generate_v4(), $1, 'Watching adult websites', -10, 'default')
+const setActivityScoring = `-- name: SetActivityScoring :exec
+UPDATE user_activities SET scoring_model = $2 , scoring_daily_cap = $3 , scoring_threshold_minutes = $4 , updated_at = NOW() WHERE id = $1
 `
 
-func (q *Queries) SetDefaultActivities(ctx context.Context, userID uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, setDefaultActivities, userID)
+type SetActivityScoringParams struct {
+	ID                      uuid.UUID
+	ScoringModel            string
+	ScoringDailyCap         sql.NullInt32
+	ScoringThresholdMinutes sql.NullInt32
+}
+
+func (q *Queries) SetActivityScoring(ctx context.Context, arg SetActivityScoringParams) error {
+	_, err := q.db.ExecContext(ctx, setActivityScoring,
+		arg.ID,
+		arg.ScoringModel,
+		arg.ScoringDailyCap,
+		arg.ScoringThresholdMinutes,
+	)
 	return err
 }
@@ -40,6 +40,52 @@ func (q *Queries) GetBestProductivityDay(ctx context.Context, arg GetBestProduct
 	return i, err
 }
 
+const getCategoryBreakdown = `-- name: GetCategoryBreakdown :many
+SELECT COALESCE(ua.category, '') AS category ,
+    CAST(COALESCE(SUM(ual.duration), 0) AS INTEGER) AS total_minutes ,
+    CAST(COALESCE(SUM(ual.points), 0) AS INTEGER) AS total_points
+FROM user_activity_logs ual
+LEFT JOIN user_activities ua ON ua.id = ual.activity_id
+WHERE ual.user_id = $1 AND ual.logged_at >= $2 AND ual.logged_at < $3
+GROUP BY COALESCE(ua.category, '')
+ORDER BY total_minutes DESC
+`
+
+type GetCategoryBreakdownParams struct {
+	UserID     uuid.UUID
+	LoggedAt   time.Time
+	LoggedAt_2 time.Time
+}
+
+type GetCategoryBreakdownRow struct {
+	Category     string
+	TotalMinutes int32
+	TotalPoints  int32
+}
+
+func (q *Queries) GetCategoryBreakdown(ctx context.Context, arg GetCategoryBreakdownParams) ([]GetCategoryBreakdownRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCategoryBreakdown, arg.UserID, arg.LoggedAt, arg.LoggedAt_2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCategoryBreakdownRow
+	for rows.Next() {
+		var i GetCategoryBreakdownRow
+		if err := rows.Scan(&i.Category, &i.TotalMinutes, &i.TotalPoints); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getProductiveUnProductiveTime = `-- name: GetProductiveUnProductiveTime :one
 SELECT 
     SUM(CASE WHEN points > 0 THEN duration ELSE 0 END) AS productive_time,
@@ -114,6 +160,53 @@ func (q *Queries) GetProductivityDays(ctx context.Context, arg GetProductivityDa
 	return items, nil
 }
 
+const getTagBreakdown = `-- name: GetTagBreakdown :many
+SELECT CAST(t.tag AS TEXT) AS tag ,
+    CAST(COALESCE(SUM(ual.duration), 0) AS INTEGER) AS total_minutes ,
+    CAST(COALESCE(SUM(ual.points), 0) AS INTEGER) AS total_points
+FROM user_activity_logs ual
+JOIN user_activities ua ON ua.id = ual.activity_id
+CROSS JOIN LATERAL UNNEST(ua.tags) AS t(tag)
+WHERE ual.user_id = $1 AND ual.logged_at >= $2 AND ual.logged_at < $3
+GROUP BY t.tag
+ORDER BY total_minutes DESC
+`
+
+type GetTagBreakdownParams struct {
+	UserID     uuid.UUID
+	LoggedAt   time.Time
+	LoggedAt_2 time.Time
+}
+
+type GetTagBreakdownRow struct {
+	Tag          string
+	TotalMinutes int32
+	TotalPoints  int32
+}
+
+func (q *Queries) GetTagBreakdown(ctx context.Context, arg GetTagBreakdownParams) ([]GetTagBreakdownRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTagBreakdown, arg.UserID, arg.LoggedAt, arg.LoggedAt_2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTagBreakdownRow
+	for rows.Next() {
+		var i GetTagBreakdownRow
+		if err := rows.Scan(&i.Tag, &i.TotalMinutes, &i.TotalPoints); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getTotalAndAverageProductivityPoints = `-- name: GetTotalAndAverageProductivityPoints :one
 WITH points_per_day AS ( SELECT DATE(logged_at) AS date, 
         COALESCE(SUM(points), 0) AS total_points
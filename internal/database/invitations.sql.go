@@ -7,14 +7,24 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-const createTeamInvitation = `-- name: CreateTeamInvitation :exec
-INSERT INTO team_invitations (id, team_id, sender_id, recipient_id, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6)
+const createTeamInvitation = `-- name: CreateTeamInvitation :one
+INSERT INTO team_invitations (id, team_id, sender_id, recipient_id, status, created_at, updated_at, expires_at)
+VALUES ($1, $2, $3, $4, 'pending', $5, $6, $7)
+ON CONFLICT (team_id, recipient_id) DO UPDATE
+SET sender_id = EXCLUDED.sender_id,
+    status = 'pending',
+    responded_at = NULL,
+    created_at = EXCLUDED.created_at,
+    updated_at = EXCLUDED.updated_at,
+    expires_at = EXCLUDED.expires_at
+WHERE team_invitations.status <> 'pending' OR team_invitations.expires_at <= NOW()
+RETURNING id, team_id, sender_id, recipient_id, status, created_at, updated_at, expires_at, responded_at
 `
 
 type CreateTeamInvitationParams struct {
@@ -24,41 +34,194 @@ type CreateTeamInvitationParams struct {
 	RecipientID uuid.UUID
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	ExpiresAt   time.Time
 }
 
-func (q *Queries) CreateTeamInvitation(ctx context.Context, arg CreateTeamInvitationParams) error {
-	_, err := q.db.ExecContext(ctx, createTeamInvitation,
+func (q *Queries) CreateTeamInvitation(ctx context.Context, arg CreateTeamInvitationParams) (TeamInvitation, error) {
+	row := q.db.QueryRowContext(ctx, createTeamInvitation,
 		arg.ID,
 		arg.TeamID,
 		arg.SenderID,
 		arg.RecipientID,
 		arg.CreatedAt,
 		arg.UpdatedAt,
+		arg.ExpiresAt,
 	)
-	return err
+	var i TeamInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.TeamID,
+		&i.SenderID,
+		&i.RecipientID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ExpiresAt,
+		&i.RespondedAt,
+	)
+	return i, err
 }
 
-const deleteTeamInvitation = `-- name: DeleteTeamInvitation :exec
-DELETE FROM team_invitations
-WHERE id = $1
+const createTeamInviteLink = `-- name: CreateTeamInviteLink :one
+INSERT INTO team_invite_links (id, team_id, created_by, token_hash, max_uses, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, team_id, created_by, token_hash, max_uses, uses, expires_at, revoked_at, created_at
 `
 
-func (q *Queries) DeleteTeamInvitation(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteTeamInvitation, id)
-	return err
+type CreateTeamInviteLinkParams struct {
+	ID        uuid.UUID
+	TeamID    uuid.UUID
+	CreatedBy uuid.UUID
+	TokenHash string
+	MaxUses   sql.NullInt32
+	ExpiresAt sql.NullTime
+}
+
+func (q *Queries) CreateTeamInviteLink(ctx context.Context, arg CreateTeamInviteLinkParams) (TeamInviteLink, error) {
+	row := q.db.QueryRowContext(ctx, createTeamInviteLink,
+		arg.ID,
+		arg.TeamID,
+		arg.CreatedBy,
+		arg.TokenHash,
+		arg.MaxUses,
+		arg.ExpiresAt,
+	)
+	var i TeamInviteLink
+	err := row.Scan(
+		&i.ID,
+		&i.TeamID,
+		&i.CreatedBy,
+		&i.TokenHash,
+		&i.MaxUses,
+		&i.Uses,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getPendingTeamInvitation = `-- name: GetPendingTeamInvitation :one
+SELECT id, team_id, sender_id, recipient_id, status, created_at, updated_at, expires_at, responded_at FROM team_invitations
+WHERE id = $1 AND recipient_id = $2 AND status = 'pending' AND expires_at > NOW()
+FOR UPDATE
+`
+
+type GetPendingTeamInvitationParams struct {
+	ID          uuid.UUID
+	RecipientID uuid.UUID
+}
+
+func (q *Queries) GetPendingTeamInvitation(ctx context.Context, arg GetPendingTeamInvitationParams) (TeamInvitation, error) {
+	row := q.db.QueryRowContext(ctx, getPendingTeamInvitation, arg.ID, arg.RecipientID)
+	var i TeamInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.TeamID,
+		&i.SenderID,
+		&i.RecipientID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ExpiresAt,
+		&i.RespondedAt,
+	)
+	return i, err
+}
+
+const getSentTeamInvitations = `-- name: GetSentTeamInvitations :many
+SELECT 
+    ti.id AS invitation_id,
+    t.id AS team_id,
+    t.name AS team_name,
+    u.username AS recipient_username,
+    CAST(CASE 
+        WHEN ti.status = 'pending' AND ti.expires_at <= NOW() THEN 'expired'
+        ELSE ti.status
+    END AS TEXT) AS status,
+    ti.created_at,
+    ti.expires_at,
+    ti.responded_at
+FROM 
+    team_invitations ti
+JOIN 
+    teams t ON ti.team_id = t.id
+JOIN 
+    users u ON ti.recipient_id = u.id
+WHERE 
+    ti.sender_id = $1
+ORDER BY 
+    ti.created_at DESC
+`
+
+type GetSentTeamInvitationsRow struct {
+	InvitationID      uuid.UUID
+	TeamID            uuid.UUID
+	TeamName          string
+	RecipientUsername string
+	Status            string
+	CreatedAt         time.Time
+	ExpiresAt         time.Time
+	RespondedAt       sql.NullTime
 }
 
-const getInvitationsCount = `-- name: GetInvitationsCount :one
+func (q *Queries) GetSentTeamInvitations(ctx context.Context, senderID uuid.UUID) ([]GetSentTeamInvitationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getSentTeamInvitations, senderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetSentTeamInvitationsRow
+	for rows.Next() {
+		var i GetSentTeamInvitationsRow
+		if err := rows.Scan(
+			&i.InvitationID,
+			&i.TeamID,
+			&i.TeamName,
+			&i.RecipientUsername,
+			&i.Status,
+			&i.CreatedAt,
+			&i.ExpiresAt,
+			&i.RespondedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
 
-SELECT COUNT(recipient_id) AS invite_count FROM team_invitations ti
-WHERE ti.recipient_id = $1 AND ti.seen = false
+const getTeamInvitation = `-- name: GetTeamInvitation :one
+SELECT id, team_id, sender_id, recipient_id, status, created_at, updated_at, expires_at, responded_at FROM team_invitations
+WHERE id = $1 AND team_id = $2
 `
 
-func (q *Queries) GetInvitationsCount(ctx context.Context, recipientID uuid.UUID) (int64, error) {
-	row := q.db.QueryRowContext(ctx, getInvitationsCount, recipientID)
-	var invite_count int64
-	err := row.Scan(&invite_count)
-	return invite_count, err
+type GetTeamInvitationParams struct {
+	ID     uuid.UUID
+	TeamID uuid.UUID
+}
+
+func (q *Queries) GetTeamInvitation(ctx context.Context, arg GetTeamInvitationParams) (TeamInvitation, error) {
+	row := q.db.QueryRowContext(ctx, getTeamInvitation, arg.ID, arg.TeamID)
+	var i TeamInvitation
+	err := row.Scan(
+		&i.ID,
+		&i.TeamID,
+		&i.SenderID,
+		&i.RecipientID,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ExpiresAt,
+		&i.RespondedAt,
+	)
+	return i, err
 }
 
 const getTeamInvitations = `-- name: GetTeamInvitations :many
@@ -67,25 +230,35 @@ SELECT
     t.id AS team_id,
     t.name AS team_name,
     t.team_industry AS team_industry,
-    t.team_size AS team_size
+    t.team_size AS team_size,
+    u.username AS sender_username,
+    ti.expires_at
 FROM 
     team_invitations ti
 JOIN 
     teams t 
 ON 
     ti.team_id = t.id
+JOIN 
+    users u
+ON 
+    ti.sender_id = u.id
 WHERE 
     ti.recipient_id = $1
+    AND ti.status = 'pending'
+    AND ti.expires_at > NOW()
 ORDER BY 
     ti.created_at DESC
 `
 
 type GetTeamInvitationsRow struct {
-	InvitationID uuid.UUID
-	TeamID       uuid.UUID
-	TeamName     string
-	TeamIndustry string
-	TeamSize     int32
+	InvitationID   uuid.UUID
+	TeamID         uuid.UUID
+	TeamName       string
+	TeamIndustry   string
+	TeamSize       int32
+	SenderUsername string
+	ExpiresAt      time.Time
 }
 
 func (q *Queries) GetTeamInvitations(ctx context.Context, recipientID uuid.UUID) ([]GetTeamInvitationsRow, error) {
@@ -103,6 +276,8 @@ func (q *Queries) GetTeamInvitations(ctx context.Context, recipientID uuid.UUID)
 			&i.TeamName,
 			&i.TeamIndustry,
 			&i.TeamSize,
+			&i.SenderUsername,
+			&i.ExpiresAt,
 		); err != nil {
 			return nil, err
 		}
@@ -117,13 +292,140 @@ func (q *Queries) GetTeamInvitations(ctx context.Context, recipientID uuid.UUID)
 	return items, nil
 }
 
-const setInvitationAsSeen = `-- name: SetInvitationAsSeen :exec
+const getTeamInviteLinkByTokenHash = `-- name: GetTeamInviteLinkByTokenHash :one
+SELECT id, team_id, created_by, token_hash, max_uses, uses, expires_at, revoked_at, created_at FROM team_invite_links
+WHERE token_hash = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetTeamInviteLinkByTokenHash(ctx context.Context, tokenHash string) (TeamInviteLink, error) {
+	row := q.db.QueryRowContext(ctx, getTeamInviteLinkByTokenHash, tokenHash)
+	var i TeamInviteLink
+	err := row.Scan(
+		&i.ID,
+		&i.TeamID,
+		&i.CreatedBy,
+		&i.TokenHash,
+		&i.MaxUses,
+		&i.Uses,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getTeamInviteLinks = `-- name: GetTeamInviteLinks :many
+SELECT id, team_id, created_by, token_hash, max_uses, uses, expires_at, revoked_at, created_at FROM team_invite_links
+WHERE team_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetTeamInviteLinks(ctx context.Context, teamID uuid.UUID) ([]TeamInviteLink, error) {
+	rows, err := q.db.QueryContext(ctx, getTeamInviteLinks, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TeamInviteLink
+	for rows.Next() {
+		var i TeamInviteLink
+		if err := rows.Scan(
+			&i.ID,
+			&i.TeamID,
+			&i.CreatedBy,
+			&i.TokenHash,
+			&i.MaxUses,
+			&i.Uses,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnseenTeamInvitationsCount = `-- name: GetUnseenTeamInvitationsCount :one
+SELECT COUNT(DISTINCT ti.id) FROM team_invitations ti
+JOIN notifications n ON n.user_id = ti.recipient_id AND n.type = 'invite.received'
+  AND n.payload->>'invitation_id' = ti.id::text
+WHERE ti.recipient_id = $1 AND ti.status = 'pending' AND ti.expires_at > NOW() AND n.read_at IS NULL
+`
+
+func (q *Queries) GetUnseenTeamInvitationsCount(ctx context.Context, recipientID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getUnseenTeamInvitationsCount, recipientID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const incrementTeamInviteLinkUses = `-- name: IncrementTeamInviteLinkUses :exec
+UPDATE team_invite_links
+SET uses = uses + 1
+WHERE id = $1
+`
+
+func (q *Queries) IncrementTeamInviteLinkUses(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, incrementTeamInviteLinkUses, id)
+	return err
+}
+
+const revokeSentTeamInvitations = `-- name: RevokeSentTeamInvitations :exec
 UPDATE team_invitations
-SET seen = true
-WHERE recipient_id = $1 AND seen = false
+SET status = 'revoked', responded_at = NOW(), updated_at = NOW()
+WHERE team_id = $1 AND sender_id = $2 AND status = 'pending'
 `
 
-func (q *Queries) SetInvitationAsSeen(ctx context.Context, recipientID uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, setInvitationAsSeen, recipientID)
+type RevokeSentTeamInvitationsParams struct {
+	TeamID   uuid.UUID
+	SenderID uuid.UUID
+}
+
+func (q *Queries) RevokeSentTeamInvitations(ctx context.Context, arg RevokeSentTeamInvitationsParams) error {
+	_, err := q.db.ExecContext(ctx, revokeSentTeamInvitations, arg.TeamID, arg.SenderID)
+	return err
+}
+
+const revokeTeamInviteLink = `-- name: RevokeTeamInviteLink :execrows
+UPDATE team_invite_links
+SET revoked_at = NOW()
+WHERE id = $1 AND team_id = $2 AND revoked_at IS NULL
+`
+
+type RevokeTeamInviteLinkParams struct {
+	ID     uuid.UUID
+	TeamID uuid.UUID
+}
+
+func (q *Queries) RevokeTeamInviteLink(ctx context.Context, arg RevokeTeamInviteLinkParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeTeamInviteLink, arg.ID, arg.TeamID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setTeamInvitationStatus = `-- name: SetTeamInvitationStatus :exec
+UPDATE team_invitations
+SET status = $1, responded_at = NOW(), updated_at = NOW()
+WHERE id = $2
+`
+
+type SetTeamInvitationStatusParams struct {
+	Status string
+	ID     uuid.UUID
+}
+
+func (q *Queries) SetTeamInvitationStatus(ctx context.Context, arg SetTeamInvitationStatusParams) error {
+	_, err := q.db.ExecContext(ctx, setTeamInvitationStatus, arg.Status, arg.ID)
 	return err
 }
@@ -7,10 +7,70 @@ package database
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const deleteMemberRole = `-- name: DeleteMemberRole :exec
+DELETE FROM team_user_roles WHERE team_membership_id = $1 AND role_id = $2
+`
+
+type DeleteMemberRoleParams struct {
+	TeamMembershipID uuid.UUID
+	RoleID           uuid.UUID
+}
+
+func (q *Queries) DeleteMemberRole(ctx context.Context, arg DeleteMemberRoleParams) error {
+	_, err := q.db.ExecContext(ctx, deleteMemberRole, arg.TeamMembershipID, arg.RoleID)
+	return err
+}
+
+const deleteTeamMembership = `-- name: DeleteTeamMembership :exec
+DELETE FROM team_memberships WHERE id = $1
+`
+
+func (q *Queries) DeleteTeamMembership(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteTeamMembership, id)
+	return err
+}
+
+const getMemberRoles = `-- name: GetMemberRoles :many
+SELECT tr.id, tr.role_name
+FROM team_user_roles tur
+JOIN team_roles tr ON tr.id = tur.role_id
+WHERE tur.team_membership_id = $1
+ORDER BY tr.role_name
+`
+
+type GetMemberRolesRow struct {
+	ID       uuid.UUID
+	RoleName string
+}
+
+func (q *Queries) GetMemberRoles(ctx context.Context, teamMembershipID uuid.UUID) ([]GetMemberRolesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getMemberRoles, teamMembershipID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetMemberRolesRow
+	for rows.Next() {
+		var i GetMemberRolesRow
+		if err := rows.Scan(&i.ID, &i.RoleName); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getNotAssignedRoles = `-- name: GetNotAssignedRoles :many
 SELECT 
     tr.id, 
@@ -21,18 +81,25 @@ LEFT JOIN
     team_user_roles tur ON tr.id = tur.role_id 
     AND tur.team_membership_id = $1
 WHERE 
-    tur.role_id IS NULL
+    tr.team_id = $2
+    AND tr.role_name <> 'owner'
+    AND tur.role_id IS NULL
 ORDER BY 
     tr.role_name
 `
 
+type GetNotAssignedRolesParams struct {
+	TeamMembershipID uuid.UUID
+	TeamID           uuid.UUID
+}
+
 type GetNotAssignedRolesRow struct {
 	ID       uuid.UUID
 	RoleName string
 }
 
-func (q *Queries) GetNotAssignedRoles(ctx context.Context, teamMembershipID uuid.UUID) ([]GetNotAssignedRolesRow, error) {
-	rows, err := q.db.QueryContext(ctx, getNotAssignedRoles, teamMembershipID)
+func (q *Queries) GetNotAssignedRoles(ctx context.Context, arg GetNotAssignedRolesParams) ([]GetNotAssignedRolesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getNotAssignedRoles, arg.TeamMembershipID, arg.TeamID)
 	if err != nil {
 		return nil, err
 	}
@@ -54,6 +121,28 @@ func (q *Queries) GetNotAssignedRoles(ctx context.Context, teamMembershipID uuid
 	return items, nil
 }
 
+const getOldestOtherTeamMembership = `-- name: GetOldestOtherTeamMembership :one
+SELECT id, team_id, user_id, created_at, updated_at FROM team_memberships WHERE team_id = $1 AND user_id <> $2 ORDER BY created_at LIMIT 1
+`
+
+type GetOldestOtherTeamMembershipParams struct {
+	TeamID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetOldestOtherTeamMembership(ctx context.Context, arg GetOldestOtherTeamMembershipParams) (TeamMembership, error) {
+	row := q.db.QueryRowContext(ctx, getOldestOtherTeamMembership, arg.TeamID, arg.UserID)
+	var i TeamMembership
+	err := row.Scan(
+		&i.ID,
+		&i.TeamID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const getTeamMembers = `-- name: GetTeamMembers :many
 SELECT 
     tm.id,
@@ -111,8 +200,110 @@ func (q *Queries) GetTeamMembers(ctx context.Context, arg GetTeamMembersParams)
 	return items, nil
 }
 
+const getTeamMembership = `-- name: GetTeamMembership :one
+SELECT id, team_id, user_id, created_at, updated_at FROM team_memberships WHERE id = $1 AND team_id = $2
+`
+
+type GetTeamMembershipParams struct {
+	ID     uuid.UUID
+	TeamID uuid.UUID
+}
+
+func (q *Queries) GetTeamMembership(ctx context.Context, arg GetTeamMembershipParams) (TeamMembership, error) {
+	row := q.db.QueryRowContext(ctx, getTeamMembership, arg.ID, arg.TeamID)
+	var i TeamMembership
+	err := row.Scan(
+		&i.ID,
+		&i.TeamID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getTeamMembershipByUser = `-- name: GetTeamMembershipByUser :one
+SELECT id, team_id, user_id, created_at, updated_at FROM team_memberships WHERE team_id = $1 AND user_id = $2
+`
+
+type GetTeamMembershipByUserParams struct {
+	TeamID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) GetTeamMembershipByUser(ctx context.Context, arg GetTeamMembershipByUserParams) (TeamMembership, error) {
+	row := q.db.QueryRowContext(ctx, getTeamMembershipByUser, arg.TeamID, arg.UserID)
+	var i TeamMembership
+	err := row.Scan(
+		&i.ID,
+		&i.TeamID,
+		&i.UserID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getUserTeamMemberships = `-- name: GetUserTeamMemberships :many
+SELECT
+    t.id AS team_id,
+    t.name,
+    tm.created_at AS joined_at,
+    COALESCE(CAST(STRING_AGG(tr.role_name, ', ') AS TEXT), '') AS roles
+FROM
+    team_memberships tm
+JOIN
+    teams t ON tm.team_id = t.id
+LEFT JOIN
+    team_user_roles tur ON tm.id = tur.team_membership_id
+LEFT JOIN
+    team_roles tr ON tur.role_id = tr.id
+WHERE
+    tm.user_id = $1
+GROUP BY
+    t.id, t.name, tm.created_at
+ORDER BY
+    tm.created_at
+`
+
+type GetUserTeamMembershipsRow struct {
+	TeamID   uuid.UUID
+	Name     string
+	JoinedAt time.Time
+	Roles    string
+}
+
+func (q *Queries) GetUserTeamMemberships(ctx context.Context, userID uuid.UUID) ([]GetUserTeamMembershipsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserTeamMemberships, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserTeamMembershipsRow
+	for rows.Next() {
+		var i GetUserTeamMembershipsRow
+		if err := rows.Scan(
+			&i.TeamID,
+			&i.Name,
+			&i.JoinedAt,
+			&i.Roles,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const setMemberRoles = `-- name: SetMemberRoles :exec
 INSERT INTO team_user_roles (id, team_membership_id, role_id) VALUES ($1, $2, $3)
+ON CONFLICT (team_membership_id, role_id) DO NOTHING
 `
 
 type SetMemberRolesParams struct {
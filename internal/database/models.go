@@ -6,6 +6,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -28,11 +29,48 @@ type ActivityLog struct {
 	ActivityDescription string
 }
 
+type ActivityTemplate struct {
+	ID          uuid.UUID
+	Slug        sql.NullString
+	Name        string
+	Description string
+	CreatedBy   uuid.NullUUID
+	PublishedAt sql.NullTime
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type ActivityTemplateItem struct {
+	ID                      uuid.UUID
+	TemplateID              uuid.UUID
+	Name                    string
+	Points                  int32
+	ScoringModel            string
+	ScoringDailyCap         sql.NullInt32
+	ScoringThresholdMinutes sql.NullInt32
+	Category                string
+	Tags                    []string
+	Icon                    string
+	Colour                  string
+}
+
 type AllActivity struct {
 	ID   uuid.UUID
 	Type string
 }
 
+type ApiToken struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Name        string
+	TokenHash   string
+	TokenPrefix string
+	Scopes      []string
+	CreatedAt   time.Time
+	LastUsedAt  sql.NullTime
+	ExpiresAt   sql.NullTime
+}
+
 type CustomActivity struct {
 	ActivityID uuid.UUID
 	UserID     uuid.UUID
@@ -41,20 +79,84 @@ type CustomActivity struct {
 	CreatedAt  time.Time
 }
 
+type EmailVerification struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	NewEmail  sql.NullString
+}
+
+type LlmUsage struct {
+	UserID uuid.UUID
+	Day    time.Time
+	Calls  int32
+}
+
+type LoginFailure struct {
+	UserID       uuid.UUID
+	FailedCount  int32
+	LastFailedAt time.Time
+	LockedUntil  sql.NullTime
+}
+
+type MfaRecoveryCode struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	CodeHash  string
+	UsedAt    sql.NullTime
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID          uuid.UUID
+	UserID      uuid.UUID
+	Type        string
+	Payload     json.RawMessage
+	ReadAt      sql.NullTime
+	DeliveredAt sql.NullTime
+	CreatedAt   time.Time
+}
+
+type OidcLoginState struct {
+	StateHash    string
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	UserID       uuid.NullUUID
+	CreatedAt    time.Time
+	ExpiresAt    time.Time
+}
+
 type PasswordReset struct {
 	ID        uuid.UUID
 	UserID    uuid.UUID
-	Token     string
+	TokenHash string
 	CreatedAt sql.NullTime
 	ExpiresAt time.Time
 }
 
+type Session struct {
+	ID                uuid.UUID
+	UserID            uuid.UUID
+	RefreshTokenHash  string
+	PreviousTokenHash sql.NullString
+	UserAgent         string
+	IpAddress         string
+	CreatedAt         time.Time
+	LastUsedAt        time.Time
+	ExpiresAt         time.Time
+	RevokedAt         sql.NullTime
+}
+
 type SuggestFeature struct {
 	ID          uuid.UUID
 	Title       string
 	Description string
 	Username    string
 	Upvote      int32
+	UserID      uuid.NullUUID
 }
 
 type Team struct {
@@ -69,13 +171,13 @@ type Team struct {
 }
 
 type TeamActivity struct {
-	ID            uuid.UUID
-	TeamID        uuid.UUID
-	ActivityName  string
-	Points        int32
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	ActivityRoles []string
+	ID           uuid.UUID
+	TeamID       uuid.UUID
+	ActivityName string
+	Points       int32
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ArchivedAt   sql.NullTime
 }
 
 type TeamActivityLog struct {
@@ -96,6 +198,12 @@ type TeamActivityRequest struct {
 	CreatedAt    time.Time
 }
 
+type TeamActivityRole struct {
+	TeamActivityID uuid.UUID
+	RoleID         uuid.UUID
+	Points         sql.NullInt32
+}
+
 type TeamInvitation struct {
 	ID          uuid.UUID
 	TeamID      uuid.UUID
@@ -104,7 +212,30 @@ type TeamInvitation struct {
 	Status      string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
-	Seen        bool
+	ExpiresAt   time.Time
+	RespondedAt sql.NullTime
+}
+
+type TeamInviteLink struct {
+	ID        uuid.UUID
+	TeamID    uuid.UUID
+	CreatedBy uuid.UUID
+	TokenHash string
+	MaxUses   sql.NullInt32
+	Uses      int32
+	ExpiresAt sql.NullTime
+	RevokedAt sql.NullTime
+	CreatedAt time.Time
+}
+
+type TeamJoinRequest struct {
+	ID          uuid.UUID
+	TeamID      uuid.UUID
+	UserID      uuid.UUID
+	Message     string
+	Status      string
+	CreatedAt   time.Time
+	RespondedAt sql.NullTime
 }
 
 type TeamMembership struct {
@@ -127,24 +258,44 @@ type TeamUserRole struct {
 	RoleID           uuid.UUID
 }
 
+type UsedWsTicket struct {
+	Jti       uuid.UUID
+	ExpiresAt time.Time
+}
+
 type User struct {
-	ID           uuid.UUID
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	Username     string
-	Email        string
-	PasswordHash sql.NullString
-	GoogleID     sql.NullString
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Username            string
+	Email               string
+	PasswordHash        sql.NullString
+	TokensValidAfter    sql.NullTime
+	EmailVerified       bool
+	DeletionScheduledAt sql.NullTime
+	DisplayName         string
+	AvatarUrl           string
+	Timezone            string
+	Locale              string
 }
 
 type UserActivity struct {
-	ID           uuid.UUID
-	UserID       uuid.UUID
-	Name         string
-	Points       int32
-	ActivityType string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	ID                      uuid.UUID
+	UserID                  uuid.UUID
+	Name                    string
+	Points                  int32
+	ActivityType            string
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	ScoringModel            string
+	ScoringDailyCap         sql.NullInt32
+	ScoringThresholdMinutes sql.NullInt32
+	Category                string
+	Tags                    []string
+	Icon                    string
+	Colour                  string
+	ArchivedAt              sql.NullTime
+	DeletedAt               sql.NullTime
 }
 
 type UserActivityLog struct {
@@ -167,9 +318,51 @@ type UserGoal struct {
 	Status     string
 }
 
+type UserIdentity struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
 type UserStreak struct {
 	UserID         uuid.UUID
 	CurrentStreak  int32
 	LongestStreak  int32
 	LastLoggedDate sql.NullTime
 }
+
+type UserTotp struct {
+	UserID       uuid.UUID
+	Secret       string
+	ConfirmedAt  sql.NullTime
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+type Webhook struct {
+	ID         uuid.UUID
+	UserID     uuid.NullUUID
+	TeamID     uuid.NullUUID
+	CreatedBy  uuid.UUID
+	Url        string
+	Secret     string
+	EventTypes []string
+	CreatedAt  time.Time
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	WebhookID      uuid.UUID
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	LastError      sql.NullString
+	CreatedAt      time.Time
+}
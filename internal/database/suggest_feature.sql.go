@@ -11,8 +11,17 @@ import (
 	"github.com/google/uuid"
 )
 
+const anonymizeUserSuggestFeatures = `-- name: AnonymizeUserSuggestFeatures :exec
+UPDATE suggest_feature SET username = 'Deleted user', user_id = NULL WHERE user_id = $1
+`
+
+func (q *Queries) AnonymizeUserSuggestFeatures(ctx context.Context, userID uuid.NullUUID) error {
+	_, err := q.db.ExecContext(ctx, anonymizeUserSuggestFeatures, userID)
+	return err
+}
+
 const createSuggestFeature = `-- name: CreateSuggestFeature :exec
-INSERT INTO suggest_feature (id , title, description , username) VALUES ($1, $2 , $3 , $4) RETURNING id, title, description, username, upvote
+INSERT INTO suggest_feature (id , title, description , username , user_id) VALUES ($1, $2 , $3 , $4 , $5) RETURNING id, title, description, username, upvote, user_id
 `
 
 type CreateSuggestFeatureParams struct {
@@ -20,6 +29,7 @@ type CreateSuggestFeatureParams struct {
 	Title       string
 	Description string
 	Username    string
+	UserID      uuid.NullUUID
 }
 
 func (q *Queries) CreateSuggestFeature(ctx context.Context, arg CreateSuggestFeatureParams) error {
@@ -28,12 +38,13 @@ func (q *Queries) CreateSuggestFeature(ctx context.Context, arg CreateSuggestFea
 		arg.Title,
 		arg.Description,
 		arg.Username,
+		arg.UserID,
 	)
 	return err
 }
 
 const getSuggestFeature = `-- name: GetSuggestFeature :many
-SELECT id, title, description, username, upvote FROM suggest_feature ORDER BY upvote DESC
+SELECT id, title, description, username, upvote, user_id FROM suggest_feature ORDER BY upvote DESC
 `
 
 func (q *Queries) GetSuggestFeature(ctx context.Context) ([]SuggestFeature, error) {
@@ -51,6 +62,7 @@ func (q *Queries) GetSuggestFeature(ctx context.Context) ([]SuggestFeature, erro
 			&i.Description,
 			&i.Username,
 			&i.Upvote,
+			&i.UserID,
 		); err != nil {
 			return nil, err
 		}
@@ -65,8 +77,56 @@ func (q *Queries) GetSuggestFeature(ctx context.Context) ([]SuggestFeature, erro
 	return items, nil
 }
 
+const getUserSuggestFeatures = `-- name: GetUserSuggestFeatures :many
+SELECT id, title, description, username, upvote, user_id FROM suggest_feature WHERE user_id = $1
+`
+
+func (q *Queries) GetUserSuggestFeatures(ctx context.Context, userID uuid.NullUUID) ([]SuggestFeature, error) {
+	rows, err := q.db.QueryContext(ctx, getUserSuggestFeatures, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SuggestFeature
+	for rows.Next() {
+		var i SuggestFeature
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Description,
+			&i.Username,
+			&i.Upvote,
+			&i.UserID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const renameUserSuggestFeatures = `-- name: RenameUserSuggestFeatures :exec
+UPDATE suggest_feature SET username = $2 WHERE user_id = $1
+`
+
+type RenameUserSuggestFeaturesParams struct {
+	UserID   uuid.NullUUID
+	Username string
+}
+
+func (q *Queries) RenameUserSuggestFeatures(ctx context.Context, arg RenameUserSuggestFeaturesParams) error {
+	_, err := q.db.ExecContext(ctx, renameUserSuggestFeatures, arg.UserID, arg.Username)
+	return err
+}
+
 const suggestFeatureDownvote = `-- name: SuggestFeatureDownvote :exec
-UPDATE suggest_feature SET upvote = upvote - 1 WHERE id = $1 RETURNING id, title, description, username, upvote
+UPDATE suggest_feature SET upvote = upvote - 1 WHERE id = $1 RETURNING id, title, description, username, upvote, user_id
 `
 
 func (q *Queries) SuggestFeatureDownvote(ctx context.Context, id uuid.UUID) error {
@@ -75,7 +135,7 @@ func (q *Queries) SuggestFeatureDownvote(ctx context.Context, id uuid.UUID) erro
 }
 
 const suggestFeatureUpvote = `-- name: SuggestFeatureUpvote :exec
-UPDATE suggest_feature SET upvote = upvote + 1 WHERE id = $1 RETURNING id, title, description, username, upvote
+UPDATE suggest_feature SET upvote = upvote + 1 WHERE id = $1 RETURNING id, title, description, username, upvote, user_id
 `
 
 func (q *Queries) SuggestFeatureUpvote(ctx context.Context, id uuid.UUID) error {
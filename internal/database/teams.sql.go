@@ -7,10 +7,10 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/lib/pq"
 )
 
 const createTeam = `-- name: CreateTeam :one
@@ -88,6 +88,140 @@ func (q *Queries) CreateTeamUserRoles(ctx context.Context, arg CreateTeamUserRol
 	return err
 }
 
+const deleteTeam = `-- name: DeleteTeam :exec
+DELETE FROM teams WHERE id = $1
+`
+
+func (q *Queries) DeleteTeam(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteTeam, id)
+	return err
+}
+
+const deleteTeamActivity = `-- name: DeleteTeamActivity :exec
+DELETE FROM team_activities WHERE id = $1 AND team_id = $2
+`
+
+type DeleteTeamActivityParams struct {
+	ID     uuid.UUID
+	TeamID uuid.UUID
+}
+
+func (q *Queries) DeleteTeamActivity(ctx context.Context, arg DeleteTeamActivityParams) error {
+	_, err := q.db.ExecContext(ctx, deleteTeamActivity, arg.ID, arg.TeamID)
+	return err
+}
+
+const deleteTeamActivityRoles = `-- name: DeleteTeamActivityRoles :exec
+DELETE FROM team_activity_roles WHERE team_activity_id = $1
+`
+
+func (q *Queries) DeleteTeamActivityRoles(ctx context.Context, teamActivityID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteTeamActivityRoles, teamActivityID)
+	return err
+}
+
+const deleteTeamRole = `-- name: DeleteTeamRole :exec
+DELETE FROM team_roles WHERE id = $1 AND team_id = $2
+`
+
+type DeleteTeamRoleParams struct {
+	ID     uuid.UUID
+	TeamID uuid.UUID
+}
+
+func (q *Queries) DeleteTeamRole(ctx context.Context, arg DeleteTeamRoleParams) error {
+	_, err := q.db.ExecContext(ctx, deleteTeamRole, arg.ID, arg.TeamID)
+	return err
+}
+
+const deleteUserTeamActivityRequests = `-- name: DeleteUserTeamActivityRequests :exec
+DELETE FROM team_activity_requests WHERE team_id = $1 AND user_id = $2
+`
+
+type DeleteUserTeamActivityRequestsParams struct {
+	TeamID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteUserTeamActivityRequests(ctx context.Context, arg DeleteUserTeamActivityRequestsParams) error {
+	_, err := q.db.ExecContext(ctx, deleteUserTeamActivityRequests, arg.TeamID, arg.UserID)
+	return err
+}
+
+const discoverTeams = `-- name: DiscoverTeams :many
+SELECT t.id, t.name, t.team_industry, t.team_size,
+    (SELECT COUNT(*) FROM team_memberships tm WHERE tm.team_id = t.id) AS member_count,
+    EXISTS (SELECT 1 FROM team_memberships tm WHERE tm.team_id = t.id AND tm.user_id = $1) AS is_member,
+    EXISTS (SELECT 1 FROM team_join_requests tjr WHERE tjr.team_id = t.id AND tjr.user_id = $1 AND tjr.status = 'pending') AS has_pending_request
+FROM teams t
+WHERE t.is_private = FALSE
+  AND ($2::text = '' OR t.name ILIKE '%' || $2::text || '%')
+  AND ($3::text = '' OR t.team_industry ILIKE $3::text)
+  AND ($4::integer = 0 OR t.team_size >= $4::integer)
+  AND ($5::integer = 0 OR t.team_size <= $5::integer)
+ORDER BY t.name
+LIMIT $6 OFFSET $7
+`
+
+type DiscoverTeamsParams struct {
+	UserID    uuid.UUID
+	Search    string
+	Industry  string
+	MinSize   int32
+	MaxSize   int32
+	RowLimit  int32
+	RowOffset int32
+}
+
+type DiscoverTeamsRow struct {
+	ID                uuid.UUID
+	Name              string
+	TeamIndustry      string
+	TeamSize          int32
+	MemberCount       int64
+	IsMember          bool
+	HasPendingRequest bool
+}
+
+func (q *Queries) DiscoverTeams(ctx context.Context, arg DiscoverTeamsParams) ([]DiscoverTeamsRow, error) {
+	rows, err := q.db.QueryContext(ctx, discoverTeams,
+		arg.UserID,
+		arg.Search,
+		arg.Industry,
+		arg.MinSize,
+		arg.MaxSize,
+		arg.RowLimit,
+		arg.RowOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DiscoverTeamsRow
+	for rows.Next() {
+		var i DiscoverTeamsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.TeamIndustry,
+			&i.TeamSize,
+			&i.MemberCount,
+			&i.IsMember,
+			&i.HasPendingRequest,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getAllTeamRoles = `-- name: GetAllTeamRoles :many
 SELECT id, role_name FROM team_roles
 WHERE team_id = $1
@@ -122,17 +256,25 @@ func (q *Queries) GetAllTeamRoles(ctx context.Context, teamID uuid.UUID) ([]GetA
 }
 
 const getTeamActivities = `-- name: GetTeamActivities :many
-SELECT activity_name, points , activity_roles FROM team_activities WHERE team_id = $1
+SELECT id, activity_name, points, archived_at FROM team_activities
+WHERE team_id = $1 AND (archived_at IS NULL OR $2::boolean)
+ORDER BY created_at
 `
 
+type GetTeamActivitiesParams struct {
+	TeamID          uuid.UUID
+	IncludeArchived bool
+}
+
 type GetTeamActivitiesRow struct {
-	ActivityName  string
-	Points        int32
-	ActivityRoles []string
+	ID           uuid.UUID
+	ActivityName string
+	Points       int32
+	ArchivedAt   sql.NullTime
 }
 
-func (q *Queries) GetTeamActivities(ctx context.Context, teamID uuid.UUID) ([]GetTeamActivitiesRow, error) {
-	rows, err := q.db.QueryContext(ctx, getTeamActivities, teamID)
+func (q *Queries) GetTeamActivities(ctx context.Context, arg GetTeamActivitiesParams) ([]GetTeamActivitiesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTeamActivities, arg.TeamID, arg.IncludeArchived)
 	if err != nil {
 		return nil, err
 	}
@@ -140,7 +282,79 @@ func (q *Queries) GetTeamActivities(ctx context.Context, teamID uuid.UUID) ([]Ge
 	var items []GetTeamActivitiesRow
 	for rows.Next() {
 		var i GetTeamActivitiesRow
-		if err := rows.Scan(&i.ActivityName, &i.Points, pq.Array(&i.ActivityRoles)); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActivityName,
+			&i.Points,
+			&i.ArchivedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTeamActivity = `-- name: GetTeamActivity :one
+SELECT id, team_id, activity_name, points, created_at, updated_at, archived_at FROM team_activities WHERE id = $1 AND team_id = $2
+`
+
+type GetTeamActivityParams struct {
+	ID     uuid.UUID
+	TeamID uuid.UUID
+}
+
+func (q *Queries) GetTeamActivity(ctx context.Context, arg GetTeamActivityParams) (TeamActivity, error) {
+	row := q.db.QueryRowContext(ctx, getTeamActivity, arg.ID, arg.TeamID)
+	var i TeamActivity
+	err := row.Scan(
+		&i.ID,
+		&i.TeamID,
+		&i.ActivityName,
+		&i.Points,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ArchivedAt,
+	)
+	return i, err
+}
+
+const getTeamActivityRoles = `-- name: GetTeamActivityRoles :many
+SELECT tar.team_activity_id, tr.id AS role_id, tr.role_name, tar.points
+FROM team_activity_roles tar
+JOIN team_roles tr ON tr.id = tar.role_id
+WHERE tr.team_id = $1
+ORDER BY tr.role_name
+`
+
+type GetTeamActivityRolesRow struct {
+	TeamActivityID uuid.UUID
+	RoleID         uuid.UUID
+	RoleName       string
+	Points         sql.NullInt32
+}
+
+func (q *Queries) GetTeamActivityRoles(ctx context.Context, teamID uuid.UUID) ([]GetTeamActivityRolesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getTeamActivityRoles, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetTeamActivityRolesRow
+	for rows.Next() {
+		var i GetTeamActivityRolesRow
+		if err := rows.Scan(
+			&i.TeamActivityID,
+			&i.RoleID,
+			&i.RoleName,
+			&i.Points,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -184,6 +398,33 @@ func (q *Queries) GetTeamInFo(ctx context.Context, id uuid.UUID) (GetTeamInFoRow
 	return i, err
 }
 
+const getTeamOwnerRole = `-- name: GetTeamOwnerRole :one
+SELECT id, role_name, team_id FROM team_roles WHERE team_id = $1 AND role_name = 'owner'
+`
+
+func (q *Queries) GetTeamOwnerRole(ctx context.Context, teamID uuid.UUID) (TeamRole, error) {
+	row := q.db.QueryRowContext(ctx, getTeamOwnerRole, teamID)
+	var i TeamRole
+	err := row.Scan(&i.ID, &i.RoleName, &i.TeamID)
+	return i, err
+}
+
+const getTeamRole = `-- name: GetTeamRole :one
+SELECT id, role_name, team_id FROM team_roles WHERE id = $1 AND team_id = $2
+`
+
+type GetTeamRoleParams struct {
+	ID     uuid.UUID
+	TeamID uuid.UUID
+}
+
+func (q *Queries) GetTeamRole(ctx context.Context, arg GetTeamRoleParams) (TeamRole, error) {
+	row := q.db.QueryRowContext(ctx, getTeamRole, arg.ID, arg.TeamID)
+	var i TeamRole
+	err := row.Scan(&i.ID, &i.RoleName, &i.TeamID)
+	return i, err
+}
+
 const getTeamRoles = `-- name: GetTeamRoles :many
 SELECT id, role_name FROM team_roles
 WHERE team_id = $1 AND role_name <> 'owner'
@@ -217,24 +458,74 @@ func (q *Queries) GetTeamRoles(ctx context.Context, teamID uuid.UUID) ([]GetTeam
 	return items, nil
 }
 
+const getTeamsCreatedByUser = `-- name: GetTeamsCreatedByUser :many
+SELECT id, name, team_industry, team_size, is_private, created_by, created_at, updated_at FROM teams WHERE created_by = $1
+`
+
+func (q *Queries) GetTeamsCreatedByUser(ctx context.Context, createdBy uuid.UUID) ([]Team, error) {
+	rows, err := q.db.QueryContext(ctx, getTeamsCreatedByUser, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Team
+	for rows.Next() {
+		var i Team
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.TeamIndustry,
+			&i.TeamSize,
+			&i.IsPrivate,
+			&i.CreatedBy,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUserTeamActivities = `-- name: GetUserTeamActivities :many
-WITH user_roles AS (
-    SELECT array_agg(tr.role_name) AS roles
+WITH membership AS (
+    SELECT tm.id
     FROM team_memberships tm
-    JOIN team_user_roles tur ON tur.team_membership_id = tm.id
-    JOIN team_roles tr ON tr.id = tur.role_id
     WHERE tm.user_id = $1
-      AND tm.team_id =  $2
+      AND tm.team_id = $2
 ),
 filtered_activities AS (
-    SELECT ta.id, ta.team_id, ta.activity_name, ta.points, ta.created_at, ta.updated_at
-    FROM team_activities ta, user_roles ur
-    WHERE ta.team_id =  $2   AND (
-        'owner' = ANY(ur.roles)  
+    SELECT ta.id, ta.team_id, ta.activity_name,
+        CAST(COALESCE((
+            SELECT MAX(tar.points)
+            FROM team_activity_roles tar
+            JOIN team_user_roles tur ON tur.role_id = tar.role_id
+            WHERE tar.team_activity_id = ta.id
+              AND tur.team_membership_id = m.id
+        ), ta.points) AS INTEGER) AS points,
+        ta.created_at, ta.updated_at
+    FROM team_activities ta, membership m
+    WHERE ta.team_id = $2 AND ta.archived_at IS NULL AND (
+        EXISTS (
+            SELECT 1
+            FROM team_user_roles tur
+            JOIN team_roles tr ON tr.id = tur.role_id
+            WHERE tur.team_membership_id = m.id
+              AND tr.role_name = 'owner'
+        )
         OR EXISTS (
             SELECT 1
-            FROM unnest(ta.activity_roles) ar
-            WHERE ar = ANY(ur.roles)
+            FROM team_activity_roles tar
+            JOIN team_user_roles tur ON tur.role_id = tar.role_id
+            WHERE tar.team_activity_id = ta.id
+              AND tur.team_membership_id = m.id
         )
     )
 )
@@ -338,6 +629,30 @@ func (q *Queries) GetUserTeams(ctx context.Context, userID uuid.UUID) ([]GetUser
 	return items, nil
 }
 
+const isTeamOwner = `-- name: IsTeamOwner :one
+SELECT EXISTS (
+    SELECT 1
+    FROM team_memberships tm
+    JOIN team_user_roles tur ON tur.team_membership_id = tm.id
+    JOIN team_roles tr ON tr.id = tur.role_id
+    WHERE tm.team_id = $1
+      AND tm.user_id = $2
+      AND tr.role_name = 'owner'
+)
+`
+
+type IsTeamOwnerParams struct {
+	TeamID uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) IsTeamOwner(ctx context.Context, arg IsTeamOwnerParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isTeamOwner, arg.TeamID, arg.UserID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const isUserTeamOwner = `-- name: IsUserTeamOwner :one
 SELECT 
     COALESCE(tr.role_name = 'owner', false) AS is_owner
@@ -366,18 +681,32 @@ func (q *Queries) IsUserTeamOwner(ctx context.Context, arg IsUserTeamOwnerParams
 	return is_owner, err
 }
 
+const renameTeamRole = `-- name: RenameTeamRole :exec
+UPDATE team_roles SET role_name = $1 WHERE id = $2 AND team_id = $3
+`
+
+type RenameTeamRoleParams struct {
+	RoleName string
+	ID       uuid.UUID
+	TeamID   uuid.UUID
+}
+
+func (q *Queries) RenameTeamRole(ctx context.Context, arg RenameTeamRoleParams) error {
+	_, err := q.db.ExecContext(ctx, renameTeamRole, arg.RoleName, arg.ID, arg.TeamID)
+	return err
+}
+
 const setTeamActivity = `-- name: SetTeamActivity :exec
-INSERT INTO team_activities (id,team_id ,  activity_name, points, created_at , updated_at , activity_roles) VALUES ($1, $2, $3, $4 , $5 , $6 , $7)
+INSERT INTO team_activities (id,team_id ,  activity_name, points, created_at , updated_at) VALUES ($1, $2, $3, $4 , $5 , $6)
 `
 
 type SetTeamActivityParams struct {
-	ID            uuid.UUID
-	TeamID        uuid.UUID
-	ActivityName  string
-	Points        int32
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	ActivityRoles []string
+	ID           uuid.UUID
+	TeamID       uuid.UUID
+	ActivityName string
+	Points       int32
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
 }
 
 func (q *Queries) SetTeamActivity(ctx context.Context, arg SetTeamActivityParams) error {
@@ -388,11 +717,55 @@ func (q *Queries) SetTeamActivity(ctx context.Context, arg SetTeamActivityParams
 		arg.Points,
 		arg.CreatedAt,
 		arg.UpdatedAt,
-		pq.Array(arg.ActivityRoles),
 	)
 	return err
 }
 
+const setTeamActivityArchivedAt = `-- name: SetTeamActivityArchivedAt :exec
+UPDATE team_activities SET archived_at = $1, updated_at = NOW() WHERE id = $2 AND team_id = $3
+`
+
+type SetTeamActivityArchivedAtParams struct {
+	ArchivedAt sql.NullTime
+	ID         uuid.UUID
+	TeamID     uuid.UUID
+}
+
+func (q *Queries) SetTeamActivityArchivedAt(ctx context.Context, arg SetTeamActivityArchivedAtParams) error {
+	_, err := q.db.ExecContext(ctx, setTeamActivityArchivedAt, arg.ArchivedAt, arg.ID, arg.TeamID)
+	return err
+}
+
+const setTeamActivityRole = `-- name: SetTeamActivityRole :exec
+INSERT INTO team_activity_roles (team_activity_id, role_id, points) VALUES ($1, $2, $3)
+ON CONFLICT (team_activity_id, role_id) DO UPDATE SET points = EXCLUDED.points
+`
+
+type SetTeamActivityRoleParams struct {
+	TeamActivityID uuid.UUID
+	RoleID         uuid.UUID
+	Points         sql.NullInt32
+}
+
+func (q *Queries) SetTeamActivityRole(ctx context.Context, arg SetTeamActivityRoleParams) error {
+	_, err := q.db.ExecContext(ctx, setTeamActivityRole, arg.TeamActivityID, arg.RoleID, arg.Points)
+	return err
+}
+
+const setTeamCreatedBy = `-- name: SetTeamCreatedBy :exec
+UPDATE teams SET created_by = $1, updated_at = NOW() WHERE id = $2
+`
+
+type SetTeamCreatedByParams struct {
+	CreatedBy uuid.UUID
+	ID        uuid.UUID
+}
+
+func (q *Queries) SetTeamCreatedBy(ctx context.Context, arg SetTeamCreatedByParams) error {
+	_, err := q.db.ExecContext(ctx, setTeamCreatedBy, arg.CreatedBy, arg.ID)
+	return err
+}
+
 const setTeamRole = `-- name: SetTeamRole :one
 INSERT INTO team_roles (id, role_name, team_id) VALUES ($1, $2, $3) RETURNING id, role_name, team_id
 `
@@ -409,3 +782,24 @@ func (q *Queries) SetTeamRole(ctx context.Context, arg SetTeamRoleParams) (TeamR
 	err := row.Scan(&i.ID, &i.RoleName, &i.TeamID)
 	return i, err
 }
+
+const updateTeamActivity = `-- name: UpdateTeamActivity :exec
+UPDATE team_activities SET activity_name = $1, points = $2, updated_at = NOW() WHERE id = $3 AND team_id = $4
+`
+
+type UpdateTeamActivityParams struct {
+	ActivityName string
+	Points       int32
+	ID           uuid.UUID
+	TeamID       uuid.UUID
+}
+
+func (q *Queries) UpdateTeamActivity(ctx context.Context, arg UpdateTeamActivityParams) error {
+	_, err := q.db.ExecContext(ctx, updateTeamActivity,
+		arg.ActivityName,
+		arg.Points,
+		arg.ID,
+		arg.TeamID,
+	)
+	return err
+}
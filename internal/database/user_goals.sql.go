@@ -12,6 +12,41 @@ import (
 	"github.com/google/uuid"
 )
 
+const getUserGoals = `-- name: GetUserGoals :many
+SELECT id, user_id, goal_date, goal_points, created_at, updated_at, status FROM user_goals WHERE user_id = $1 ORDER BY goal_date
+`
+
+func (q *Queries) GetUserGoals(ctx context.Context, userID uuid.UUID) ([]UserGoal, error) {
+	rows, err := q.db.QueryContext(ctx, getUserGoals, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserGoal
+	for rows.Next() {
+		var i UserGoal
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.GoalDate,
+			&i.GoalPoints,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Status,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const setGoalCompleted = `-- name: SetGoalCompleted :exec
 
 UPDATE user_goals SET status = 'completed' WHERE user_id = $1 AND DATE(created_at) = CURRENT_DATE
@@ -12,6 +12,24 @@ import (
 	"github.com/google/uuid"
 )
 
+const breakStreak = `-- name: BreakStreak :execrows
+UPDATE user_streaks SET current_streak = 0
+WHERE user_id = $1 AND current_streak > 0 AND last_logged_date = $2
+`
+
+type BreakStreakParams struct {
+	UserID         uuid.UUID
+	LastLoggedDate sql.NullTime
+}
+
+func (q *Queries) BreakStreak(ctx context.Context, arg BreakStreakParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, breakStreak, arg.UserID, arg.LastLoggedDate)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getStreakData = `-- name: GetStreakData :one
 SELECT current_streak, longest_streak , last_logged_date FROM user_streaks WHERE user_id = $1
 `
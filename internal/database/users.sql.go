@@ -13,15 +13,40 @@ import (
 	"github.com/google/uuid"
 )
 
-const createPasswordReset = `-- name: CreatePasswordReset :exec
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users SET deletion_scheduled_at = NULL, updated_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	return err
+}
+
+const consumePasswordReset = `-- name: ConsumePasswordReset :one
+DELETE FROM password_reset WHERE token_hash = $1 RETURNING id, user_id, token_hash, created_at, expires_at
+`
+
+func (q *Queries) ConsumePasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error) {
+	row := q.db.QueryRowContext(ctx, consumePasswordReset, tokenHash)
+	var i PasswordReset
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.TokenHash,
+		&i.CreatedAt,
+		&i.ExpiresAt,
+	)
+	return i, err
+}
 
-INSERT INTO password_reset (id , user_id , token , expires_at) VALUES ($1 , $2 , $3  ,$4)
+const createPasswordReset = `-- name: CreatePasswordReset :exec
+INSERT INTO password_reset (id , user_id , token_hash , expires_at) VALUES ($1 , $2 , $3  ,$4)
 `
 
 type CreatePasswordResetParams struct {
 	ID        uuid.UUID
 	UserID    uuid.UUID
-	Token     string
+	TokenHash string
 	ExpiresAt time.Time
 }
 
@@ -29,24 +54,24 @@ func (q *Queries) CreatePasswordReset(ctx context.Context, arg CreatePasswordRes
 	_, err := q.db.ExecContext(ctx, createPasswordReset,
 		arg.ID,
 		arg.UserID,
-		arg.Token,
+		arg.TokenHash,
 		arg.ExpiresAt,
 	)
 	return err
 }
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id , created_at , updated_at , username , email , password_hash , google_id) VALUES ($1, $2, $3, $4 , $5 , $6 , $7) RETURNING id, created_at, updated_at, username, email, password_hash, google_id
+INSERT INTO users (id , created_at , updated_at , username , email , password_hash , email_verified) VALUES ($1, $2, $3, $4 , $5 , $6 , $7) RETURNING id, created_at, updated_at, username, email, password_hash, tokens_valid_after, email_verified, deletion_scheduled_at, display_name, avatar_url, timezone, locale
 `
 
 type CreateUserParams struct {
-	ID           uuid.UUID
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	Username     string
-	Email        string
-	PasswordHash sql.NullString
-	GoogleID     sql.NullString
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Username      string
+	Email         string
+	PasswordHash  sql.NullString
+	EmailVerified bool
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -57,7 +82,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.Username,
 		arg.Email,
 		arg.PasswordHash,
-		arg.GoogleID,
+		arg.EmailVerified,
 	)
 	var i User
 	err := row.Scan(
@@ -67,39 +92,37 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Username,
 		&i.Email,
 		&i.PasswordHash,
-		&i.GoogleID,
+		&i.EmailVerified,
+		&i.DeletionScheduledAt,
+		&i.TokensValidAfter,
+		&i.AvatarUrl,
+		&i.DisplayName,
+		&i.Locale,
+		&i.Timezone,
 	)
 	return i, err
 }
 
-const deletePasswordReset = `-- name: DeletePasswordReset :exec
-DELETE FROM password_reset WHERE token = $1
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1
 `
 
-func (q *Queries) DeletePasswordReset(ctx context.Context, token string) error {
-	_, err := q.db.ExecContext(ctx, deletePasswordReset, token)
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
 	return err
 }
 
-const getPasswordReset = `-- name: GetPasswordReset :one
-
-SELECT u.email , pr.expires_at FROM password_reset pr  JOIN users u  ON u.id = pr.user_id  WHERE token = $1
+const deleteUserPasswordResets = `-- name: DeleteUserPasswordResets :exec
+DELETE FROM password_reset WHERE user_id = $1
 `
 
-type GetPasswordResetRow struct {
-	Email     string
-	ExpiresAt time.Time
-}
-
-func (q *Queries) GetPasswordReset(ctx context.Context, token string) (GetPasswordResetRow, error) {
-	row := q.db.QueryRowContext(ctx, getPasswordReset, token)
-	var i GetPasswordResetRow
-	err := row.Scan(&i.Email, &i.ExpiresAt)
-	return i, err
+func (q *Queries) DeleteUserPasswordResets(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUserPasswordResets, userID)
+	return err
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, username, email, password_hash, google_id FROM users WHERE email = $1
+SELECT id, created_at, updated_at, username, email, password_hash, tokens_valid_after, email_verified, deletion_scheduled_at, display_name, avatar_url, timezone, locale FROM users WHERE email = $1
 `
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
@@ -112,17 +135,23 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Username,
 		&i.Email,
 		&i.PasswordHash,
-		&i.GoogleID,
+		&i.EmailVerified,
+		&i.DeletionScheduledAt,
+		&i.TokensValidAfter,
+		&i.AvatarUrl,
+		&i.DisplayName,
+		&i.Locale,
+		&i.Timezone,
 	)
 	return i, err
 }
 
-const getUserByGoogleId = `-- name: GetUserByGoogleId :one
-SELECT id, created_at, updated_at, username, email, password_hash, google_id FROM users WHERE google_id = $1
+const getUserById = `-- name: GetUserById :one
+SELECT id, created_at, updated_at, username, email, password_hash, tokens_valid_after, email_verified, deletion_scheduled_at, display_name, avatar_url, timezone, locale FROM users WHERE id = $1
 `
 
-func (q *Queries) GetUserByGoogleId(ctx context.Context, googleID sql.NullString) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUserByGoogleId, googleID)
+func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserById, id)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -131,13 +160,19 @@ func (q *Queries) GetUserByGoogleId(ctx context.Context, googleID sql.NullString
 		&i.Username,
 		&i.Email,
 		&i.PasswordHash,
-		&i.GoogleID,
+		&i.EmailVerified,
+		&i.DeletionScheduledAt,
+		&i.TokensValidAfter,
+		&i.AvatarUrl,
+		&i.DisplayName,
+		&i.Locale,
+		&i.Timezone,
 	)
 	return i, err
 }
 
 const getUserByUsername = `-- name: GetUserByUsername :one
-SELECT id, created_at, updated_at, username, email, password_hash, google_id FROM users WHERE username = $1
+SELECT id, created_at, updated_at, username, email, password_hash, tokens_valid_after, email_verified, deletion_scheduled_at, display_name, avatar_url, timezone, locale FROM users WHERE username = $1
 `
 
 func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
@@ -150,7 +185,13 @@ func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User,
 		&i.Username,
 		&i.Email,
 		&i.PasswordHash,
-		&i.GoogleID,
+		&i.EmailVerified,
+		&i.DeletionScheduledAt,
+		&i.TokensValidAfter,
+		&i.AvatarUrl,
+		&i.DisplayName,
+		&i.Locale,
+		&i.Timezone,
 	)
 	return i, err
 }
@@ -170,6 +211,8 @@ LEFT JOIN
 ON 
     u.id = ti.recipient_id 
     AND ti.team_id = $1
+    AND ti.status = 'pending'
+    AND ti.expires_at > NOW()
 WHERE 
     u.id != $2
     AND u.username ILIKE $3
@@ -213,17 +256,145 @@ func (q *Queries) GetUsers(ctx context.Context, arg GetUsersParams) ([]GetUsersR
 	return items, nil
 }
 
-const setNewPassword = `-- name: SetNewPassword :exec
+const getUsersDueForDeletion = `-- name: GetUsersDueForDeletion :many
+SELECT id FROM users WHERE deletion_scheduled_at <= $1
+`
 
-UPDATE users SET password_hash = $1 WHERE email = $2
+func (q *Queries) GetUsersDueForDeletion(ctx context.Context, deletionScheduledAt sql.NullTime) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersDueForDeletion, deletionScheduledAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const removeUserPassword = `-- name: RemoveUserPassword :execrows
+UPDATE users SET password_hash = NULL, updated_at = NOW()
+WHERE id = $1 AND EXISTS (SELECT 1 FROM user_identities WHERE user_id = $1)
+`
+
+func (q *Queries) RemoveUserPassword(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeUserPassword, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :exec
+UPDATE users SET deletion_scheduled_at = $2, updated_at = NOW() WHERE id = $1
+`
+
+type ScheduleUserDeletionParams struct {
+	ID                  uuid.UUID
+	DeletionScheduledAt sql.NullTime
+}
+
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, arg ScheduleUserDeletionParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleUserDeletion, arg.ID, arg.DeletionScheduledAt)
+	return err
+}
+
+const setNewPassword = `-- name: SetNewPassword :exec
+UPDATE users SET password_hash = $1, updated_at = NOW() WHERE id = $2
 `
 
 type SetNewPasswordParams struct {
 	PasswordHash sql.NullString
-	Email        string
+	ID           uuid.UUID
 }
 
 func (q *Queries) SetNewPassword(ctx context.Context, arg SetNewPasswordParams) error {
-	_, err := q.db.ExecContext(ctx, setNewPassword, arg.PasswordHash, arg.Email)
+	_, err := q.db.ExecContext(ctx, setNewPassword, arg.PasswordHash, arg.ID)
+	return err
+}
+
+const setUserEmail = `-- name: SetUserEmail :exec
+UPDATE users SET email = $2, email_verified = TRUE, updated_at = NOW() WHERE id = $1
+`
+
+type SetUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) SetUserEmail(ctx context.Context, arg SetUserEmailParams) error {
+	_, err := q.db.ExecContext(ctx, setUserEmail, arg.ID, arg.Email)
+	return err
+}
+
+const setUserEmailVerified = `-- name: SetUserEmailVerified :exec
+UPDATE users SET email_verified = TRUE, updated_at = NOW() WHERE id = $1
+`
+
+func (q *Queries) SetUserEmailVerified(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, setUserEmailVerified, id)
+	return err
+}
+
+const setUserTokensValidAfter = `-- name: SetUserTokensValidAfter :exec
+UPDATE users SET tokens_valid_after = NOW() WHERE id = $1
+`
+
+func (q *Queries) SetUserTokensValidAfter(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, setUserTokensValidAfter, id)
 	return err
 }
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users SET username = $2, display_name = $3, avatar_url = $4, timezone = $5, locale = $6, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, username, email, password_hash, tokens_valid_after, email_verified, deletion_scheduled_at, display_name, avatar_url, timezone, locale
+`
+
+type UpdateUserProfileParams struct {
+	ID          uuid.UUID
+	Username    string
+	DisplayName string
+	AvatarUrl   string
+	Timezone    string
+	Locale      string
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.ID,
+		arg.Username,
+		arg.DisplayName,
+		arg.AvatarUrl,
+		arg.Timezone,
+		arg.Locale,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Username,
+		&i.Email,
+		&i.PasswordHash,
+		&i.TokensValidAfter,
+		&i.EmailVerified,
+		&i.DeletionScheduledAt,
+		&i.DisplayName,
+		&i.AvatarUrl,
+		&i.Timezone,
+		&i.Locale,
+	)
+	return i, err
+}
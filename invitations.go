@@ -1,50 +1,90 @@
 package main
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/mrdkvcs/go-base-backend/internal/database"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 )
 
-func (apiCfg *apiConfig) CreateTeamInvitation(w http.ResponseWriter, r *http.Request) {
+const teamInvitationTTL = 7 * 24 * time.Hour
+
+func (apiCfg *apiConfig) CreateTeamInvitation(w http.ResponseWriter, r *http.Request, user database.User) {
+	if !requireVerifiedEmail(w, user) {
+		return
+	}
 	type parameters struct {
 		RecipientID string `json:"recipient_id"`
-		SenderID    string `json:"sender_id"`
 	}
 	params := parameters{}
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
 	teamId := r.PathValue("teamid")
 	parsedTeamUUID, err := uuid.Parse(teamId)
 	if err != nil {
-		respondWithJson(w, 500, "Error parsing team id")
+		respondWithError(w, 400, "Error parsing team id")
+		return
 	}
 	parsedRecipientUUID, err := uuid.Parse(params.RecipientID)
 	if err != nil {
-		respondWithJson(w, 500, "Error parsing recipient id")
+		respondWithError(w, 400, "Error parsing recipient id")
+		return
 	}
-	parsedSenderUUID, err := uuid.Parse(params.SenderID)
-	if err != nil {
-		respondWithJson(w, 500, "Error parsing sender id")
+	if parsedRecipientUUID == user.ID {
+		respondWithError(w, 400, "You cannot invite yourself")
+		return
 	}
-	err = apiCfg.DB.CreateTeamInvitation(r.Context(), database.CreateTeamInvitationParams{
+	_, err = apiCfg.DB.GetTeamMembershipByUser(r.Context(), database.GetTeamMembershipByUserParams{
+		TeamID: parsedTeamUUID,
+		UserID: user.ID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 403, "Only team members can send invitations")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting team membership: %s", err))
+		return
+	}
+	_, err = apiCfg.DB.GetTeamMembershipByUser(r.Context(), database.GetTeamMembershipByUserParams{
+		TeamID: parsedTeamUUID,
+		UserID: parsedRecipientUUID,
+	})
+	if err == nil {
+		respondWithError(w, 409, "User is already a member of this team")
+		return
+	} else if err != sql.ErrNoRows {
+		respondWithError(w, 500, fmt.Sprintf("Error getting team membership: %s", err))
+		return
+	}
+	invitation, err := apiCfg.DB.CreateTeamInvitation(r.Context(), database.CreateTeamInvitationParams{
 		ID:          uuid.New(),
 		TeamID:      parsedTeamUUID,
-		SenderID:    parsedSenderUUID,
+		SenderID:    user.ID,
 		RecipientID: parsedRecipientUUID,
 		CreatedAt:   time.Now().UTC(),
 		UpdatedAt:   time.Now().UTC(),
+		ExpiresAt:   time.Now().UTC().Add(teamInvitationTTL),
 	})
-	if err != nil {
-		respondWithJson(w, 500, "Error creating team invitation")
+	if err == sql.ErrNoRows {
+		respondWithError(w, 409, "User already has a pending invitation to this team")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, "Error creating team invitation")
 		return
 	}
 	respondWithJson(w, 200, "Team invitation sent successfully ")
-	broadcast <- params.RecipientID
+	apiCfg.notify(r.Context(), parsedRecipientUUID, EventInviteReceived, InviteReceivedEvent{InvitationID: invitation.ID, TeamID: parsedTeamUUID, SenderUsername: user.Username})
 }
 func (apiCfg *apiConfig) GetTeamInvitations(w http.ResponseWriter, r *http.Request, user database.User) {
 	teamInvites, err := apiCfg.DB.GetTeamInvitations(r.Context(), user.ID)
@@ -54,8 +94,18 @@ func (apiCfg *apiConfig) GetTeamInvitations(w http.ResponseWriter, r *http.Reque
 	}
 	respondWithJson(w, 200, databaseTeamInvitationsToTeamInvitations(teamInvites))
 }
+
+func (apiCfg *apiConfig) GetSentTeamInvitations(w http.ResponseWriter, r *http.Request, user database.User) {
+	sentInvites, err := apiCfg.DB.GetSentTeamInvitations(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting sent team invitations: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseSentTeamInvitationsToSentTeamInvitations(sentInvites))
+}
+
 func (apiCfg *apiConfig) GetInvitationsCount(w http.ResponseWriter, r *http.Request, user database.User) {
-	inviteCount, err := apiCfg.DB.GetInvitationsCount(r.Context(), user.ID)
+	inviteCount, err := apiCfg.DB.GetUnseenTeamInvitationsCount(r.Context(), user.ID)
 	if err != nil {
 		respondWithJson(w, 500, "Error getting  invitations count")
 		return
@@ -64,7 +114,10 @@ func (apiCfg *apiConfig) GetInvitationsCount(w http.ResponseWriter, r *http.Requ
 }
 
 func (apiCfg *apiConfig) SetInvitationsAsSeen(w http.ResponseWriter, r *http.Request, user database.User) {
-	err := apiCfg.DB.SetInvitationAsSeen(r.Context(), user.ID)
+	err := apiCfg.DB.MarkAllNotificationsRead(r.Context(), database.MarkAllNotificationsReadParams{
+		UserID: user.ID,
+		Type:   EventInviteReceived,
+	})
 	if err != nil {
 		log.Printf("Error setting invitation as seen: %s", err)
 		return
@@ -72,7 +125,6 @@ func (apiCfg *apiConfig) SetInvitationsAsSeen(w http.ResponseWriter, r *http.Req
 }
 func (apiCfg *apiConfig) AcceptTeamInvite(w http.ResponseWriter, r *http.Request, user database.User) {
 	type parameters struct {
-		TeamID   string `json:"team_id"`
 		InviteID string `json:"invitation_id"`
 	}
 	params := parameters{}
@@ -82,37 +134,303 @@ func (apiCfg *apiConfig) AcceptTeamInvite(w http.ResponseWriter, r *http.Request
 		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
 		return
 	}
-	parsedTeamUUID, err := uuid.Parse(params.TeamID)
+	parsedInviteUUID, err := uuid.Parse(params.InviteID)
 	if err != nil {
-		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing invite uuid: %s", err))
 		return
 	}
-	parsedInviteUUID, err := uuid.Parse(params.InviteID)
+	tx, err := db.BeginTx(r.Context(), nil)
 	if err != nil {
-		respondWithError(w, 400, fmt.Sprintf("Error in parsing invite uuid: %s", err))
+		respondWithError(w, 500, fmt.Sprintf("Error in starting transaction: %s", err))
 		return
 	}
-	_, err = apiCfg.DB.CreateTeamMembership(r.Context(), database.CreateTeamMembershipParams{
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	invitation, err := qtx.GetPendingTeamInvitation(r.Context(), database.GetPendingTeamInvitationParams{
+		ID:          parsedInviteUUID,
+		RecipientID: user.ID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Invitation not found or no longer valid")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team invitation: %s", err))
+		return
+	}
+	_, err = qtx.CreateTeamMembership(r.Context(), database.CreateTeamMembershipParams{
 		ID:     uuid.New(),
-		TeamID: parsedTeamUUID,
+		TeamID: invitation.TeamID,
 		UserID: user.ID,
 	})
-	err = apiCfg.DB.DeleteTeamInvitation(r.Context(), parsedInviteUUID)
 	if err != nil {
-		respondWithError(w, 500, fmt.Sprintf("Error in deleting team invitation: %s", err))
+		if isUniqueViolation(err) {
+			respondWithError(w, 409, "You are already a member of this team")
+			return
+		}
+		respondWithError(w, 500, fmt.Sprintf("Error in joining team: %s", err))
 		return
 	}
+	err = qtx.SetTeamInvitationStatus(r.Context(), database.SetTeamInvitationStatusParams{
+		Status: "accepted",
+		ID:     invitation.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in accepting team invitation: %s", err))
+		return
+	}
+	err = qtx.CancelPendingTeamJoinRequest(r.Context(), database.CancelPendingTeamJoinRequestParams{
+		TeamID: invitation.TeamID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in cancelling join request: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	apiCfg.emitTeamWebhookEvent(r.Context(), invitation.TeamID, WebhookTeamMemberJoined, TeamMemberJoinedWebhook{TeamID: invitation.TeamID, UserID: user.ID, Username: user.Username})
 }
-func (apiCfg *apiConfig) DeclineTeamInvite(w http.ResponseWriter, r *http.Request) {
+func (apiCfg *apiConfig) DeclineTeamInvite(w http.ResponseWriter, r *http.Request, user database.User) {
 	invitationId := r.PathValue("invitationid")
 	parsedInviteUUID, err := uuid.Parse(invitationId)
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error in parsing invite uuid: %s", err))
 		return
 	}
-	err = apiCfg.DB.DeleteTeamInvitation(r.Context(), parsedInviteUUID)
+	invitation, err := apiCfg.DB.GetPendingTeamInvitation(r.Context(), database.GetPendingTeamInvitationParams{
+		ID:          parsedInviteUUID,
+		RecipientID: user.ID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Invitation not found or no longer valid")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team invitation: %s", err))
+		return
+	}
+	err = apiCfg.DB.SetTeamInvitationStatus(r.Context(), database.SetTeamInvitationStatusParams{
+		Status: "declined",
+		ID:     invitation.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in declining team invitation: %s", err))
+		return
+	}
+}
+
+// RevokeTeamInvitation lets the sender (or the team owner) withdraw a pending
+// invitation.
+func (apiCfg *apiConfig) RevokeTeamInvitation(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
+	parsedInviteUUID, err := uuid.Parse(r.PathValue("invitationid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing invite uuid: %s", err))
+		return
+	}
+	invitation, err := apiCfg.DB.GetTeamInvitation(r.Context(), database.GetTeamInvitationParams{
+		ID:     parsedInviteUUID,
+		TeamID: parsedTeamUUID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Invitation not found")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team invitation: %s", err))
+		return
+	}
+	if invitation.SenderID != user.ID && !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	if invitation.Status != "pending" || !invitation.ExpiresAt.After(time.Now()) {
+		respondWithError(w, 409, "Only pending invitations can be revoked")
+		return
+	}
+	err = apiCfg.DB.SetTeamInvitationStatus(r.Context(), database.SetTeamInvitationStatusParams{
+		Status: "revoked",
+		ID:     invitation.ID,
+	})
 	if err != nil {
-		respondWithError(w, 500, fmt.Sprintf("Error in deleting team invitation: %s", err))
+		respondWithError(w, 500, fmt.Sprintf("Error in revoking team invitation: %s", err))
 		return
 	}
+	respondWithJson(w, 200, "Team invitation revoked successfully")
+}
+
+func (apiCfg *apiConfig) CreateTeamInviteLink(w http.ResponseWriter, r *http.Request, user database.User) {
+	if !requireVerifiedEmail(w, user) {
+		return
+	}
+	type parameters struct {
+		MaxUses        int32 `json:"max_uses"`
+		ExpiresInHours int32 `json:"expires_in_hours"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
+	if params.MaxUses < 0 || params.ExpiresInHours < 0 {
+		respondWithError(w, 400, "max_uses and expires_in_hours cannot be negative")
+		return
+	}
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	token, err := generateResetToken()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error generating invite token: %s", err))
+		return
+	}
+	inviteLink, err := apiCfg.DB.CreateTeamInviteLink(r.Context(), database.CreateTeamInviteLinkParams{
+		ID:        uuid.New(),
+		TeamID:    parsedTeamUUID,
+		CreatedBy: user.ID,
+		TokenHash: hashToken(token),
+		MaxUses:   sql.NullInt32{Int32: params.MaxUses, Valid: params.MaxUses > 0},
+		ExpiresAt: sql.NullTime{Time: time.Now().UTC().Add(time.Duration(params.ExpiresInHours) * time.Hour), Valid: params.ExpiresInHours > 0},
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error creating invite link: %s", err))
+		return
+	}
+	teamInviteLink := databaseTeamInviteLinkToTeamInviteLink(inviteLink)
+	teamInviteLink.Token = token
+	teamInviteLink.URL = fmt.Sprintf("%s/teams/join?token=%s", frontendURL, url.QueryEscape(token))
+	respondWithJson(w, 200, teamInviteLink)
+}
+
+func (apiCfg *apiConfig) GetTeamInviteLinks(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	inviteLinks, err := apiCfg.DB.GetTeamInviteLinks(r.Context(), parsedTeamUUID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting invite links: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseTeamInviteLinksToTeamInviteLinks(inviteLinks))
+}
+
+func (apiCfg *apiConfig) RevokeTeamInviteLink(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
+	parsedLinkUUID, err := uuid.Parse(r.PathValue("linkid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing invite link uuid: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	revoked, err := apiCfg.DB.RevokeTeamInviteLink(r.Context(), database.RevokeTeamInviteLinkParams{
+		ID:     parsedLinkUUID,
+		TeamID: parsedTeamUUID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error revoking invite link: %s", err))
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, 404, "Invite link not found or already revoked")
+		return
+	}
+	respondWithJson(w, 200, "Invite link revoked successfully")
+}
+
+func (apiCfg *apiConfig) JoinTeamWithInviteLink(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Token string `json:"token"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in starting transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	inviteLink, err := qtx.GetTeamInviteLinkByTokenHash(r.Context(), hashToken(params.Token))
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Invalid invite link")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting invite link: %s", err))
+		return
+	}
+	if inviteLink.RevokedAt.Valid ||
+		(inviteLink.ExpiresAt.Valid && inviteLink.ExpiresAt.Time.Before(time.Now())) ||
+		(inviteLink.MaxUses.Valid && inviteLink.Uses >= inviteLink.MaxUses.Int32) {
+		respondWithError(w, 410, "This invite link is no longer valid")
+		return
+	}
+	_, err = qtx.CreateTeamMembership(r.Context(), database.CreateTeamMembershipParams{
+		ID:     uuid.New(),
+		TeamID: inviteLink.TeamID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, 409, "You are already a member of this team")
+			return
+		}
+		respondWithError(w, 500, fmt.Sprintf("Error in joining team: %s", err))
+		return
+	}
+	err = qtx.IncrementTeamInviteLinkUses(r.Context(), inviteLink.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error updating invite link: %s", err))
+		return
+	}
+	err = qtx.CancelPendingTeamJoinRequest(r.Context(), database.CancelPendingTeamJoinRequestParams{
+		TeamID: inviteLink.TeamID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in cancelling join request: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	apiCfg.emitTeamWebhookEvent(r.Context(), inviteLink.TeamID, WebhookTeamMemberJoined, TeamMemberJoinedWebhook{TeamID: inviteLink.TeamID, UserID: user.ID, Username: user.Username})
+	respondWithJson(w, 200, struct {
+		TeamID uuid.UUID `json:"team_id"`
+	}{TeamID: inviteLink.TeamID})
+}
+
+// hashToken returns the hex encoded SHA-256 of a random token. Tokens are
+// high-entropy, so a fast hash is enough to keep them unusable if leaked.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const discoverTeamsPageSize = 20
+
+// DiscoverTeams lists public teams. Private teams never show up here and can
+// only be joined through an invitation or invite link.
+func (apiCfg *apiConfig) DiscoverTeams(w http.ResponseWriter, r *http.Request, user database.User) {
+	query := r.URL.Query()
+	minSize, err := parseQueryInt32(r, "min_size")
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing min_size: %s", err))
+		return
+	}
+	maxSize, err := parseQueryInt32(r, "max_size")
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing max_size: %s", err))
+		return
+	}
+	page, err := parseQueryInt32(r, "page")
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing page: %s", err))
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	teams, err := apiCfg.DB.DiscoverTeams(r.Context(), database.DiscoverTeamsParams{
+		UserID:    user.ID,
+		Search:    strings.TrimSpace(query.Get("q")),
+		Industry:  strings.TrimSpace(query.Get("industry")),
+		MinSize:   minSize,
+		MaxSize:   maxSize,
+		RowLimit:  discoverTeamsPageSize,
+		RowOffset: (page - 1) * discoverTeamsPageSize,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in discovering teams: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseDiscoveredTeamsToDiscoveredTeams(teams))
+}
+
+func (apiCfg *apiConfig) RequestToJoinTeam(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Message string `json:"message"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
+	team, err := apiCfg.DB.GetTeamInFo(r.Context(), parsedTeamUUID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Team not found")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team info: %s", err))
+		return
+	}
+	if team.IsPrivate {
+		respondWithError(w, 403, "This team is invite-only")
+		return
+	}
+	_, err = apiCfg.DB.GetTeamMembershipByUser(r.Context(), database.GetTeamMembershipByUserParams{
+		TeamID: parsedTeamUUID,
+		UserID: user.ID,
+	})
+	if err == nil {
+		respondWithError(w, 409, "You are already a member of this team")
+		return
+	} else if err != sql.ErrNoRows {
+		respondWithError(w, 500, fmt.Sprintf("Error getting team membership: %s", err))
+		return
+	}
+	joinRequest, err := apiCfg.DB.CreateTeamJoinRequest(r.Context(), database.CreateTeamJoinRequestParams{
+		ID:      uuid.New(),
+		TeamID:  parsedTeamUUID,
+		UserID:  user.ID,
+		Message: strings.TrimSpace(params.Message),
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, 409, "You already have a pending request for this team")
+			return
+		}
+		respondWithError(w, 500, fmt.Sprintf("Error in creating join request: %s", err))
+		return
+	}
+	respondWithJson(w, 200, struct {
+		ID uuid.UUID `json:"id"`
+	}{ID: joinRequest.ID})
+}
+
+func (apiCfg *apiConfig) GetTeamJoinRequests(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	joinRequests, err := apiCfg.DB.GetTeamJoinRequests(r.Context(), parsedTeamUUID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting join requests: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseTeamJoinRequestsToTeamJoinRequests(joinRequests))
+}
+
+func (apiCfg *apiConfig) ApproveTeamJoinRequest(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedTeamUUID, parsedRequestUUID, ok := apiCfg.parseManagedJoinRequest(w, r, user)
+	if !ok {
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in starting transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	joinRequest, err := qtx.GetPendingTeamJoinRequest(r.Context(), database.GetPendingTeamJoinRequestParams{
+		ID:     parsedRequestUUID,
+		TeamID: parsedTeamUUID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Join request not found")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting join request: %s", err))
+		return
+	}
+	// A failed insert aborts the transaction, so an existing membership has to
+	// be found before adding the user rather than by the unique violation.
+	_, err = qtx.GetTeamMembershipByUser(r.Context(), database.GetTeamMembershipByUserParams{
+		TeamID: joinRequest.TeamID,
+		UserID: joinRequest.UserID,
+	})
+	if err != nil && err != sql.ErrNoRows {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team membership: %s", err))
+		return
+	}
+	joined := err == sql.ErrNoRows
+	if joined {
+		_, err = qtx.CreateTeamMembership(r.Context(), database.CreateTeamMembershipParams{
+			ID:     uuid.New(),
+			TeamID: joinRequest.TeamID,
+			UserID: joinRequest.UserID,
+		})
+		if err != nil {
+			if isUniqueViolation(err) {
+				respondWithError(w, 409, "User is already a member of this team")
+				return
+			}
+			respondWithError(w, 500, fmt.Sprintf("Error in adding team member: %s", err))
+			return
+		}
+	}
+	err = qtx.SetTeamJoinRequestStatus(r.Context(), database.SetTeamJoinRequestStatusParams{
+		Status: "approved",
+		ID:     joinRequest.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in approving join request: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	if joined {
+		apiCfg.emitTeamMemberJoined(r.Context(), joinRequest.TeamID, joinRequest.UserID)
+	}
+	respondWithJson(w, 200, "Join request approved successfully")
+}
+
+func (apiCfg *apiConfig) DenyTeamJoinRequest(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedTeamUUID, parsedRequestUUID, ok := apiCfg.parseManagedJoinRequest(w, r, user)
+	if !ok {
+		return
+	}
+	joinRequest, err := apiCfg.DB.GetPendingTeamJoinRequest(r.Context(), database.GetPendingTeamJoinRequestParams{
+		ID:     parsedRequestUUID,
+		TeamID: parsedTeamUUID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Join request not found")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting join request: %s", err))
+		return
+	}
+	err = apiCfg.DB.SetTeamJoinRequestStatus(r.Context(), database.SetTeamJoinRequestStatusParams{
+		Status: "denied",
+		ID:     joinRequest.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in denying join request: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Join request denied successfully")
+}
+
+func (apiCfg *apiConfig) GetUserTeamJoinRequests(w http.ResponseWriter, r *http.Request, user database.User) {
+	joinRequests, err := apiCfg.DB.GetUserTeamJoinRequests(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting join requests: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseUserTeamJoinRequestsToUserTeamJoinRequests(joinRequests))
+}
+
+func (apiCfg *apiConfig) CancelTeamJoinRequest(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedRequestUUID, err := uuid.Parse(r.PathValue("requestid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing join request uuid: %s", err))
+		return
+	}
+	cancelled, err := apiCfg.DB.CancelTeamJoinRequest(r.Context(), database.CancelTeamJoinRequestParams{
+		ID:     parsedRequestUUID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in cancelling join request: %s", err))
+		return
+	}
+	if cancelled == 0 {
+		respondWithError(w, 404, "Join request not found")
+		return
+	}
+	respondWithJson(w, 200, "Join request cancelled successfully")
+}
+
+// parseManagedJoinRequest parses the team and join request ids from the path
+// and makes sure the user owns the team.
+func (apiCfg *apiConfig) parseManagedJoinRequest(w http.ResponseWriter, r *http.Request, user database.User) (uuid.UUID, uuid.UUID, bool) {
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return uuid.Nil, uuid.Nil, false
+	}
+	parsedRequestUUID, err := uuid.Parse(r.PathValue("requestid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing join request uuid: %s", err))
+		return uuid.Nil, uuid.Nil, false
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return uuid.Nil, uuid.Nil, false
+	}
+	return parsedTeamUUID, parsedRequestUUID, true
+}
+
+// parseQueryInt32 reads an optional non-negative integer query parameter,
+// returning 0 when it is missing.
+func parseQueryInt32(r *http.Request, name string) (int32, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	if parsed < 0 {
+		return 0, fmt.Errorf("%s cannot be negative", name)
+	}
+	return int32(parsed), nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"github.com/lib/pq"
 	"log"
 	"net/http"
 )
@@ -27,3 +28,8 @@ func respondWithJson(w http.ResponseWriter, code int, payload interface{}) {
 	w.WriteHeader(code)
 	w.Write(data)
 }
+
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
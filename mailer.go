@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"net/smtp"
+	"os"
+)
+
+// sendEmail sends a plain text email through the SMTP account configured in
+// the .env file.
+func sendEmail(to string, subject string, body string) error {
+	err := godotenv.Load(".env")
+	if err != nil {
+		fmt.Println("Error loading .env file")
+		return err
+	}
+	from := os.Getenv("SMTP_USER")
+	if from == "" {
+		fmt.Println("Couldnt get username from .env file")
+		return errors.New("Error getting smtp user from .env")
+	}
+	password := os.Getenv("SMTP_PASSWORD")
+	if password == "" {
+		return errors.New("Error getting smtp password from .env")
+	}
+	message := fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\n\n%s", from, to, subject, body)
+	smtpHost := "smtp.gmail.com"
+	smtpPort := "587"
+	auth := smtp.PlainAuth("", from, password, smtpHost)
+	return smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{to}, []byte(message))
+}
+
+func sendResetEmail(email string, token string) error {
+	resetLink := fmt.Sprintf("%s/reset-password?token=%s", frontendURL, token)
+	body := fmt.Sprintf("Password Reset \n\n Here is your link to reset your password : " + resetLink)
+	return sendEmail(email, "Password reset", body)
+}
+
+func sendVerificationEmail(email string, token string) error {
+	verifyLink := fmt.Sprintf("%s/verify-email?token=%s", frontendURL, token)
+	body := fmt.Sprintf("Email verification \n\n Here is your link to verify your email address : %s", verifyLink)
+	return sendEmail(email, "Verify your email address", body)
+}
+
+func sendEmailChangeNotice(email string, newEmail string) error {
+	body := fmt.Sprintf("Email change \n\n A change of your account email to %s was requested. If this was not you , change your password right away.", newEmail)
+	return sendEmail(email, "Your email address is being changed", body)
+}
+
+func sendReminderEmail(userEmail string, goalPoints, totalPoints int32) {
+	body := fmt.Sprintf("Hello,\n\nYou are currently  below your productivity goal for the day.\nYour total daily points : %d\nYour goal points: %d\n\nKeep pushing to reach your target!\n\nBest regards,\nYour Productivity Tracker",
+		totalPoints, goalPoints)
+	err := sendEmail(userEmail, "Productivity Goal Reminder", body)
+	if err != nil {
+		fmt.Printf("error sending email: %v", err)
+	} else {
+		fmt.Println("Email sent succesfully")
+	}
+}
@@ -7,10 +7,10 @@ import (
 	"github.com/jub0bs/cors"
 	_ "github.com/lib/pq"
 	"github.com/mrdkvcs/go-base-backend/internal/database"
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/google"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 )
 
 type apiConfig struct {
@@ -21,8 +21,10 @@ var apiconfig apiConfig
 var db *sql.DB
 var stopChan chan struct{} = make(chan struct{})
 var api_key string = ""
-var oauthConfig *oauth2.Config
+var oidcProviders map[string]*oidcProvider
 var jwtSecret string
+var frontendURL string
+var allowedOrigins = []string{"http://localhost:5173", "http://localhost:5174"}
 var extractSystemInstruction = `
 You are an assistant that extracts the core activity and duration from user input. Your goal is to interpret the user's activity and extract the duration in minutes. Before processing, translate the input into English to ensure accurate extraction. Focus on extracting the activity itself, and convert any time mentioned to the equivalent number of minutes. The output should be in the following structured format: Activity: <activity>, Duration: <duration>. The duration should be expressed as a number of minutes without any units or extra text.
 
@@ -82,24 +84,24 @@ func main() {
 		return
 	}
 
-	oauthClientId := os.Getenv("OAUTH_GOOGLE_CLIENT_ID")
-	if oauthClientId == "" {
-		fmt.Println("OAUTH_GOOGLE_CLIENT_ID environment variable is not set")
-		return
+	if os.Getenv("LLM_DAILY_QUOTA") != "" {
+		quota, err := strconv.Atoi(os.Getenv("LLM_DAILY_QUOTA"))
+		if err != nil || quota < 0 {
+			fmt.Println("LLM_DAILY_QUOTA must be a non-negative number")
+			return
+		}
+		llmDailyQuota = int32(quota)
 	}
 
-	oauthClientSecret := os.Getenv("OAUTH_GOOGLE_CLIENT_SECRET")
-	if oauthClientSecret == "" {
-		fmt.Println("OAUTH_GOOGLE_CLIENT_SECRET environment variable is not set")
-		return
+	frontendURL = os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		frontendURL = "http://localhost:5173"
 	}
 
-	oauthConfig = &oauth2.Config{
-		ClientID:     oauthClientId,
-		ClientSecret: oauthClientSecret,
-		RedirectURL:  "http://localhost:5173/google/auth/callback",
-		Scopes:       []string{"https://www.googleapis.com/auth/userinfo.email", "https://www.googleapis.com/auth/userinfo.profile"},
-		Endpoint:     google.Endpoint,
+	oidcProviders, err = loadOidcProviders()
+	if err != nil {
+		fmt.Println(err)
+		return
 	}
 
 	if os.Getenv("JWT_SECRET") != "" {
@@ -115,9 +117,21 @@ func main() {
 	}
 
 	apiconfig = apiConfig{DB: database.New(db)}
+
+	var backplane Backplane = newMemoryBackplane()
+	if os.Getenv("WS_BACKPLANE") == "postgres" {
+		backplane, err = newPostgresBackplane(dbUrl, db, apiconfig.loadNotificationEvent)
+		if err != nil {
+			fmt.Println("Could not start websocket backplane")
+			return
+		}
+	}
+	hub = newHub(backplane, apiconfig.markNotificationsDelivered)
+	go apiconfig.startWebhookWorker()
+	go apiconfig.startAccountDeletionWorker()
 	corsMw, err := cors.NewMiddleware(cors.Config{
-		Origins:        []string{"http://localhost:5173", "http://localhost:5174"},
-		Methods:        []string{"GET", "POST", "DELETE", "PUT"},
+		Origins:        allowedOrigins,
+		Methods:        []string{"GET", "POST", "DELETE", "PUT", "PATCH"},
 		RequestHeaders: []string{"Authorization"},
 	})
 	if err != nil {
@@ -125,49 +139,124 @@ func main() {
 	}
 	corsMw.SetDebug(true)
 	router := http.NewServeMux()
-	router.HandleFunc("GET /ws", handleConnections)
-	router.HandleFunc("POST /register", apiconfig.CreateUser)
-	router.HandleFunc("POST /login", apiconfig.LogInUser)
-	router.HandleFunc("POST /forgot-password", apiconfig.ForgotPasswordHandler)
-	router.HandleFunc("POST /reset-password", apiconfig.ResetPasswordHandler)
+	router.HandleFunc("GET /ws", apiconfig.handleConnections)
+	router.HandleFunc("POST /ws/ticket", apiconfig.middlewareAuth(apiconfig.CreateWsTicket))
+	router.HandleFunc("POST /register", newRateLimiter(5, time.Hour).byIP(apiconfig.CreateUser))
+	router.HandleFunc("POST /login", newRateLimiter(10, time.Minute).byIP(apiconfig.LogInUser))
+	router.HandleFunc("POST /verify-email", newRateLimiter(30, time.Minute).byIP(apiconfig.VerifyEmail))
+	router.HandleFunc("POST /verify-email/resend", apiconfig.middlewareAuth(apiconfig.ResendEmailVerification))
+	router.HandleFunc("POST /auth/mfa", newRateLimiter(10, time.Minute).byIP(apiconfig.CompleteMfaLogin))
+	router.HandleFunc("GET /user/mfa", apiconfig.middlewareAuth(apiconfig.GetMfaStatus))
+	router.HandleFunc("POST /user/mfa/totp", apiconfig.middlewareAuth(apiconfig.EnrollTotp))
+	router.HandleFunc("POST /user/mfa/totp/confirm", apiconfig.middlewareAuth(apiconfig.ConfirmTotp))
+	router.HandleFunc("DELETE /user/mfa/totp", apiconfig.middlewareAuth(apiconfig.DisableTotp))
+	router.HandleFunc("POST /user/mfa/recovery-codes", apiconfig.middlewareAuth(apiconfig.RegenerateRecoveryCodes))
+	router.HandleFunc("POST /user/api-tokens", apiconfig.middlewareAuth(apiconfig.CreateApiToken))
+	router.HandleFunc("GET /user/api-tokens", apiconfig.middlewareAuth(apiconfig.GetUserApiTokens))
+	router.HandleFunc("DELETE /user/api-tokens/{tokenid}", apiconfig.middlewareAuth(apiconfig.RevokeApiToken))
+	router.HandleFunc("POST /auth/refresh", newRateLimiter(30, time.Minute).byIP(apiconfig.RefreshSession))
+	router.HandleFunc("POST /logout", apiconfig.middlewareAuth(apiconfig.LogOutUser))
+	router.HandleFunc("GET /user/sessions", apiconfig.middlewareAuth(apiconfig.GetUserSessions))
+	router.HandleFunc("DELETE /user/sessions/{sessionid}", apiconfig.middlewareAuth(apiconfig.RevokeUserSession))
+	router.HandleFunc("POST /forgot-password", newRateLimiter(5, 15*time.Minute).byIP(apiconfig.ForgotPasswordHandler))
+	router.HandleFunc("POST /reset-password", newRateLimiter(5, 15*time.Minute).byIP(apiconfig.ResetPasswordHandler))
 	router.HandleFunc("GET /user", apiconfig.middlewareAuth(apiconfig.GetUserByEmail))
-	router.HandleFunc("POST /google/auth/callback", apiconfig.googleCallback)
-	router.HandleFunc("GET /activities", apiconfig.middlewareAuth(apiconfig.GetActivites))
+	router.HandleFunc("PATCH /user", apiconfig.middlewareAuth(apiconfig.UpdateUser))
+	router.HandleFunc("POST /user/email", apiconfig.middlewareAuth(newRateLimiter(5, 15*time.Minute).byUser(apiconfig.ChangeEmail)))
+	router.HandleFunc("POST /user/password", apiconfig.middlewareAuth(newRateLimiter(5, 15*time.Minute).byUser(apiconfig.ChangePassword)))
+	router.HandleFunc("DELETE /user", apiconfig.middlewareAuth(apiconfig.DeleteUser))
+	router.HandleFunc("GET /user/export", apiconfig.middlewareAuth(apiconfig.ExportUserData))
+	router.HandleFunc("GET /auth/providers", apiconfig.GetAuthProviders)
+	router.HandleFunc("POST /auth/{provider}/authorize", apiconfig.StartOidcLogin)
+	router.HandleFunc("POST /auth/{provider}/callback", newRateLimiter(30, time.Minute).byIP(apiconfig.OidcLoginCallback))
+	router.HandleFunc("POST /auth/link", newRateLimiter(10, time.Minute).byIP(apiconfig.LinkIdentity))
+	router.HandleFunc("GET /user/identities", apiconfig.middlewareAuth(apiconfig.GetUserIdentities))
+	router.HandleFunc("POST /user/identities/password", apiconfig.middlewareAuth(apiconfig.AddPasswordIdentity))
+	router.HandleFunc("POST /user/identities/{provider}/authorize", apiconfig.middlewareAuth(apiconfig.StartIdentityLink))
+	router.HandleFunc("POST /user/identities/{provider}", apiconfig.middlewareAuth(apiconfig.AddUserIdentity))
+	router.HandleFunc("DELETE /user/identities/{provider}", apiconfig.middlewareAuth(apiconfig.RemoveUserIdentity))
+	router.HandleFunc("GET /activities", apiconfig.middlewareAuthWithScope(ScopeReadLogs, apiconfig.GetActivites))
 	router.HandleFunc("POST /activities", apiconfig.middlewareAuth(apiconfig.SetActivity))
-	router.HandleFunc("DELETE /activities/{id}", apiconfig.DeleteActivity)
-	router.HandleFunc("PUT /activities/{id}", apiconfig.EditActivity)
-	router.HandleFunc("POST /activities/logs", apiconfig.middlewareAuth(apiconfig.SetActivityLog))
-	router.HandleFunc("POST /activities/logs/specific", apiconfig.middlewareAuth(apiconfig.SetSpecificActivityLog))
-	router.HandleFunc("POST /activities/logs/new", apiconfig.middlewareAuth(apiconfig.SetNewActivity))
-	router.HandleFunc("GET /activities/logs/exist", apiconfig.middlewareAuth(apiconfig.CheckActivityLogExists))
-	router.HandleFunc("GET /activities/daily/logs", apiconfig.middlewareAuth(apiconfig.GetDailyActivityLogs))
-	router.HandleFunc("GET /dailystats", apiconfig.middlewareAuth(apiconfig.GetDailyStats))
-	router.HandleFunc("POST /productivitystats", apiconfig.middlewareAuth(apiconfig.GetProductivityStats))
+	router.HandleFunc("DELETE /activities/{id}", apiconfig.middlewareAuth(apiconfig.DeleteActivity))
+	router.HandleFunc("PUT /activities/{id}", apiconfig.middlewareAuth(apiconfig.EditActivity))
+	router.HandleFunc("POST /activities/{id}/archive", apiconfig.middlewareAuth(apiconfig.ArchiveActivity))
+	router.HandleFunc("POST /activities/{id}/unarchive", apiconfig.middlewareAuth(apiconfig.UnarchiveActivity))
+	router.HandleFunc("GET /activities/categories", apiconfig.middlewareAuthWithScope(ScopeReadLogs, apiconfig.GetActivityCategories))
+	router.HandleFunc("GET /activity-templates", apiconfig.middlewareAuth(apiconfig.GetActivityTemplates))
+	router.HandleFunc("POST /activity-templates", apiconfig.middlewareAuth(apiconfig.CreateActivityTemplate))
+	router.HandleFunc("GET /activity-templates/{templateid}", apiconfig.middlewareAuth(apiconfig.GetActivityTemplate))
+	router.HandleFunc("DELETE /activity-templates/{templateid}", apiconfig.middlewareAuth(apiconfig.DeleteActivityTemplate))
+	router.HandleFunc("POST /activity-templates/{templateid}/publish", apiconfig.middlewareAuth(apiconfig.PublishActivityTemplate))
+	router.HandleFunc("POST /activity-templates/{templateid}/unpublish", apiconfig.middlewareAuth(apiconfig.UnpublishActivityTemplate))
+	router.HandleFunc("POST /activity-templates/{templateid}/import", apiconfig.middlewareAuth(apiconfig.ImportActivityTemplate))
+	router.HandleFunc("POST /teams/{teamid}/activity-templates/{templateid}/import", apiconfig.middlewareAuth(apiconfig.ImportActivityTemplateToTeam))
+	router.HandleFunc("POST /activities/logs", apiconfig.middlewareAuthWithScope(ScopeWriteLogs, newRateLimiter(10, time.Minute).byUser(apiconfig.SetActivityLog)))
+	router.HandleFunc("POST /activities/logs/specific", apiconfig.middlewareAuthWithScope(ScopeWriteLogs, apiconfig.SetSpecificActivityLog))
+	router.HandleFunc("POST /activities/logs/new", apiconfig.middlewareAuthWithScope(ScopeWriteLogs, apiconfig.SetNewActivity))
+	router.HandleFunc("GET /activities/logs/exist", apiconfig.middlewareAuthWithScope(ScopeReadLogs, apiconfig.CheckActivityLogExists))
+	router.HandleFunc("GET /activities/daily/logs", apiconfig.middlewareAuthWithScope(ScopeReadLogs, apiconfig.GetDailyActivityLogs))
+	router.HandleFunc("GET /dailystats", apiconfig.middlewareAuthWithScope(ScopeReadStats, apiconfig.GetDailyStats))
+	router.HandleFunc("POST /productivitystats", apiconfig.middlewareAuthWithScope(ScopeReadStats, apiconfig.GetProductivityStats))
 	router.HandleFunc("POST /productivitygoals", apiconfig.middlewareAuth(apiconfig.SetProductivityGoal))
 	router.HandleFunc("POST /suggestFeature", apiconfig.middlewareAuth(apiconfig.createSuggestFeature))
 	router.HandleFunc("GET /suggestFeature", apiconfig.GetSuggestFeature)
 	router.HandleFunc("PUT /suggestFeature/upvote/{id}", apiconfig.SetSuggestFeatureUpVote)
 	router.HandleFunc("PUT /suggestFeature/downvote/{id}", apiconfig.SetSuggestFeatureDownVote)
 	router.HandleFunc("POST /teams", apiconfig.middlewareAuth(apiconfig.CreateTeam))
-	router.HandleFunc("GET /teams", apiconfig.middlewareAuth(apiconfig.GetUserTeams))
+	router.HandleFunc("GET /teams", apiconfig.middlewareAuthWithScope(ScopeTeams, apiconfig.GetUserTeams))
 	router.HandleFunc("GET /teams/{teamid}", apiconfig.GetTeamInfo)
+	router.HandleFunc("GET /teams/discover", apiconfig.middlewareAuth(apiconfig.DiscoverTeams))
+	router.HandleFunc("POST /teams/{teamid}/join-requests", apiconfig.middlewareAuth(apiconfig.RequestToJoinTeam))
+	router.HandleFunc("GET /teams/{teamid}/join-requests", apiconfig.middlewareAuth(apiconfig.GetTeamJoinRequests))
+	router.HandleFunc("POST /teams/{teamid}/join-requests/{requestid}/approve", apiconfig.middlewareAuth(apiconfig.ApproveTeamJoinRequest))
+	router.HandleFunc("POST /teams/{teamid}/join-requests/{requestid}/deny", apiconfig.middlewareAuth(apiconfig.DenyTeamJoinRequest))
+	router.HandleFunc("GET /user/join-requests", apiconfig.middlewareAuth(apiconfig.GetUserTeamJoinRequests))
+	router.HandleFunc("DELETE /user/join-requests/{requestid}", apiconfig.middlewareAuth(apiconfig.CancelTeamJoinRequest))
 	router.HandleFunc("GET /teams/{teamid}/activities", apiconfig.GetTeamActivities)
-	router.HandleFunc("POST /teams/{teamid}/roles", apiconfig.SetTeamRole)
+	router.HandleFunc("POST /teams/{teamid}/roles", apiconfig.middlewareAuth(apiconfig.SetTeamRole))
+	router.HandleFunc("PUT /teams/{teamid}/roles/{roleid}", apiconfig.middlewareAuth(apiconfig.RenameTeamRole))
+	router.HandleFunc("DELETE /teams/{teamid}/roles/{roleid}", apiconfig.middlewareAuth(apiconfig.DeleteTeamRole))
 	router.HandleFunc("GET /teams/{teamid}/roles", apiconfig.GetTeamRoles)
-	router.HandleFunc("POST /teams/{teamid}/activities", apiconfig.SetTeamActivity)
-	router.HandleFunc("GET /teams/{teamid}/ownership", apiconfig.middlewareAuth(apiconfig.IsUserTeamOwner))
-	router.HandleFunc("GET /teams/{teamid}/user/activities", apiconfig.middlewareAuth(apiconfig.GetUserTeamActivities))
+	router.HandleFunc("POST /teams/{teamid}/activities", apiconfig.middlewareAuth(apiconfig.SetTeamActivity))
+	router.HandleFunc("PUT /teams/{teamid}/activities/{activityid}", apiconfig.middlewareAuth(apiconfig.UpdateTeamActivity))
+	router.HandleFunc("DELETE /teams/{teamid}/activities/{activityid}", apiconfig.middlewareAuth(apiconfig.DeleteTeamActivity))
+	router.HandleFunc("POST /teams/{teamid}/activities/{activityid}/archive", apiconfig.middlewareAuth(apiconfig.ArchiveTeamActivity))
+	router.HandleFunc("POST /teams/{teamid}/activities/{activityid}/unarchive", apiconfig.middlewareAuth(apiconfig.UnarchiveTeamActivity))
+	router.HandleFunc("GET /teams/{teamid}/ownership", apiconfig.middlewareAuthWithScope(ScopeTeams, apiconfig.IsUserTeamOwner))
+	router.HandleFunc("GET /teams/{teamid}/user/activities", apiconfig.middlewareAuthWithScope(ScopeTeams, apiconfig.GetUserTeamActivities))
 	router.HandleFunc("GET /users", apiconfig.GetUsers)
-	router.HandleFunc("POST /teams/{teamid}/invitation", apiconfig.CreateTeamInvitation)
+	router.HandleFunc("POST /teams/{teamid}/invitation", apiconfig.middlewareAuth(apiconfig.CreateTeamInvitation))
+	router.HandleFunc("DELETE /teams/{teamid}/invitations/{invitationid}", apiconfig.middlewareAuth(apiconfig.RevokeTeamInvitation))
+	router.HandleFunc("POST /teams/{teamid}/invite-links", apiconfig.middlewareAuth(apiconfig.CreateTeamInviteLink))
+	router.HandleFunc("GET /teams/{teamid}/invite-links", apiconfig.middlewareAuth(apiconfig.GetTeamInviteLinks))
+	router.HandleFunc("DELETE /teams/{teamid}/invite-links/{linkid}", apiconfig.middlewareAuth(apiconfig.RevokeTeamInviteLink))
+	router.HandleFunc("POST /teams/join", apiconfig.middlewareAuth(apiconfig.JoinTeamWithInviteLink))
 	router.HandleFunc("GET /user/invitations", apiconfig.middlewareAuth(apiconfig.GetTeamInvitations))
+	router.HandleFunc("GET /user/invitations/sent", apiconfig.middlewareAuth(apiconfig.GetSentTeamInvitations))
 	router.HandleFunc("GET /user/invitations/count", apiconfig.middlewareAuth(apiconfig.GetInvitationsCount))
-	router.HandleFunc("UPDATE /user/invitations/seen", apiconfig.middlewareAuth(apiconfig.SetInvitationsAsSeen))
+	router.HandleFunc("PUT /user/invitations/seen", apiconfig.middlewareAuth(apiconfig.SetInvitationsAsSeen))
+	router.HandleFunc("POST /user/webhooks", apiconfig.middlewareAuth(apiconfig.CreateUserWebhook))
+	router.HandleFunc("GET /user/webhooks", apiconfig.middlewareAuth(apiconfig.GetUserWebhooks))
+	router.HandleFunc("POST /teams/{teamid}/webhooks", apiconfig.middlewareAuth(apiconfig.CreateTeamWebhook))
+	router.HandleFunc("GET /teams/{teamid}/webhooks", apiconfig.middlewareAuth(apiconfig.GetTeamWebhooks))
+	router.HandleFunc("DELETE /webhooks/{webhookid}", apiconfig.middlewareAuth(apiconfig.DeleteWebhook))
+	router.HandleFunc("GET /webhooks/{webhookid}/deliveries", apiconfig.middlewareAuth(apiconfig.GetWebhookDeliveries))
+	router.HandleFunc("POST /webhooks/{webhookid}/test", apiconfig.middlewareAuth(apiconfig.SendTestWebhook))
+	router.HandleFunc("GET /user/notifications", apiconfig.middlewareAuth(apiconfig.GetNotifications))
+	router.HandleFunc("GET /user/notifications/unread-count", apiconfig.middlewareAuth(apiconfig.GetUnreadNotificationsCount))
+	router.HandleFunc("POST /user/notifications/{notificationid}/read", apiconfig.middlewareAuth(apiconfig.MarkNotificationRead))
+	router.HandleFunc("POST /user/notifications/read-all", apiconfig.middlewareAuth(apiconfig.MarkAllNotificationsRead))
 	router.HandleFunc("POST /user/invitations/accept", apiconfig.middlewareAuth(apiconfig.AcceptTeamInvite))
-	router.HandleFunc("DELETE /user/invitations/{invitationid}", apiconfig.DeclineTeamInvite)
-	router.HandleFunc("GET /teams/{teamid}/members", apiconfig.middlewareAuth(apiconfig.GetTeamMembers))
-	router.HandleFunc("POST /teams/{teamid}/roles/{membership_id}", apiconfig.SetMemberRoles)
+	router.HandleFunc("DELETE /user/invitations/{invitationid}", apiconfig.middlewareAuth(apiconfig.DeclineTeamInvite))
+	router.HandleFunc("GET /teams/{teamid}/members", apiconfig.middlewareAuthWithScope(ScopeTeams, apiconfig.GetTeamMembers))
+	router.HandleFunc("POST /teams/{teamid}/roles/{membership_id}", apiconfig.middlewareAuth(apiconfig.SetMemberRoles))
 	router.HandleFunc("GET /teams/{teamid}/roles/{membership_id}", apiconfig.GetNotAssignedRoles)
-	go handleMessages()
+	router.HandleFunc("DELETE /teams/{teamid}", apiconfig.middlewareAuth(apiconfig.DeleteTeam))
+	router.HandleFunc("DELETE /teams/{teamid}/members/{membership_id}", apiconfig.middlewareAuth(apiconfig.RemoveTeamMember))
+	router.HandleFunc("PUT /teams/{teamid}/members/{membership_id}/roles", apiconfig.middlewareAuth(apiconfig.ReplaceMemberRoles))
+	router.HandleFunc("DELETE /teams/{teamid}/members/{membership_id}/roles/{roleid}", apiconfig.middlewareAuth(apiconfig.UnassignMemberRole))
+	router.HandleFunc("POST /teams/{teamid}/leave", apiconfig.middlewareAuth(apiconfig.LeaveTeam))
+	router.HandleFunc("POST /teams/{teamid}/transfer-ownership", apiconfig.middlewareAuth(apiconfig.TransferTeamOwnership))
 	handler := corsMw.Wrap(router)
 	fmt.Println("Server running on port: " + port)
 	http.ListenAndServe(":"+port, handler)
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
@@ -25,7 +27,7 @@ func (apiCfg *apiConfig) GetTeamMembers(w http.ResponseWriter, r *http.Request,
 	}
 	respondWithJson(w, 200, databaseMembersToMembers(members))
 }
-func (apiCfg *apiConfig) SetMemberRoles(w http.ResponseWriter, r *http.Request) {
+func (apiCfg *apiConfig) SetMemberRoles(w http.ResponseWriter, r *http.Request, user database.User) {
 	type parameters struct {
 		SelectedRoles []string `json:"selected_roles"`
 	}
@@ -36,36 +38,382 @@ func (apiCfg *apiConfig) SetMemberRoles(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
 		return
 	}
-	membershipId := r.PathValue("membership_id")
-	teamMembershipUUID, err := uuid.Parse(membershipId)
+	membership, ok := apiCfg.getManagedTeamMembership(w, r, user)
+	if !ok {
+		return
+	}
+	roleIDs, ok := apiCfg.parseTeamRoleIDs(w, r, membership.TeamID, params.SelectedRoles)
+	if !ok {
+		return
+	}
+	for _, roleID := range roleIDs {
+		err = apiCfg.DB.SetMemberRoles(r.Context(), database.SetMemberRolesParams{
+			ID:               uuid.New(),
+			TeamMembershipID: membership.ID,
+			RoleID:           roleID,
+		})
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error in setting member roles: %s", err))
+			return
+		}
+	}
+}
+
+func (apiCfg *apiConfig) ReplaceMemberRoles(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		RoleIDs []string `json:"role_ids"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, 400, fmt.Sprintf("Error in parsing team membership uuid: %s", err))
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
+	membership, ok := apiCfg.getManagedTeamMembership(w, r, user)
+	if !ok {
+		return
+	}
+	roleIDs, ok := apiCfg.parseTeamRoleIDs(w, r, membership.TeamID, params.RoleIDs)
+	if !ok {
+		return
+	}
+	wantedRoles := make(map[uuid.UUID]bool)
+	for _, roleID := range roleIDs {
+		wantedRoles[roleID] = true
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in starting transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	currentRoles, err := qtx.GetMemberRoles(r.Context(), membership.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting member roles: %s", err))
 		return
 	}
-	for _, roleId := range params.SelectedRoles {
-		roleUUID, err := uuid.Parse(roleId)
+	for _, currentRole := range currentRoles {
+		if currentRole.RoleName == teamOwnerRole {
+			continue
+		}
+		if wantedRoles[currentRole.ID] {
+			delete(wantedRoles, currentRole.ID)
+			continue
+		}
+		err = qtx.DeleteMemberRole(r.Context(), database.DeleteMemberRoleParams{
+			TeamMembershipID: membership.ID,
+			RoleID:           currentRole.ID,
+		})
 		if err != nil {
-			respondWithError(w, 400, fmt.Sprintf("Error in parsing role uuid: %s", err))
+			respondWithError(w, 500, fmt.Sprintf("Error in removing member role: %s", err))
 			return
 		}
-		err = apiCfg.DB.SetMemberRoles(r.Context(), database.SetMemberRolesParams{
+	}
+	for roleID := range wantedRoles {
+		err = qtx.SetMemberRoles(r.Context(), database.SetMemberRolesParams{
 			ID:               uuid.New(),
-			TeamMembershipID: teamMembershipUUID,
-			RoleID:           roleUUID,
+			TeamMembershipID: membership.ID,
+			RoleID:           roleID,
 		})
 		if err != nil {
 			respondWithError(w, 500, fmt.Sprintf("Error in setting member roles: %s", err))
 			return
 		}
 	}
+	memberRoles, err := qtx.GetMemberRoles(r.Context(), membership.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting member roles: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseMemberRolesToTeamRoles(memberRoles))
+}
+
+func (apiCfg *apiConfig) UnassignMemberRole(w http.ResponseWriter, r *http.Request, user database.User) {
+	membership, ok := apiCfg.getManagedTeamMembership(w, r, user)
+	if !ok {
+		return
+	}
+	parsedRoleUUID, err := uuid.Parse(r.PathValue("roleid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing role uuid: %s", err))
+		return
+	}
+	teamRole, ok := apiCfg.getEditableTeamRole(w, r, parsedRoleUUID, membership.TeamID)
+	if !ok {
+		return
+	}
+	err = apiCfg.DB.DeleteMemberRole(r.Context(), database.DeleteMemberRoleParams{
+		TeamMembershipID: membership.ID,
+		RoleID:           teamRole.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in removing member role: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Role removed from member successfully")
 }
+
+// getManagedTeamMembership resolves the {teamid} and {membership_id} path
+// values to a membership of that team, provided the user owns the team.
+func (apiCfg *apiConfig) getManagedTeamMembership(w http.ResponseWriter, r *http.Request, user database.User) (database.TeamMembership, bool) {
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return database.TeamMembership{}, false
+	}
+	parsedMembershipUUID, err := uuid.Parse(r.PathValue("membership_id"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team membership uuid: %s", err))
+		return database.TeamMembership{}, false
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return database.TeamMembership{}, false
+	}
+	membership, err := apiCfg.DB.GetTeamMembership(r.Context(), database.GetTeamMembershipParams{
+		ID:     parsedMembershipUUID,
+		TeamID: parsedTeamUUID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Team member not found")
+		return database.TeamMembership{}, false
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team membership: %s", err))
+		return database.TeamMembership{}, false
+	}
+	return membership, true
+}
+
 func (apiCfg *apiConfig) GetNotAssignedRoles(w http.ResponseWriter, r *http.Request) {
+	teamId := r.PathValue("teamid")
+	parsedTeamUUID, err := uuid.Parse(teamId)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
 	memberShipId := r.PathValue("membership_id")
 	parsedmembershipUUID, err := uuid.Parse(memberShipId)
 	if err != nil {
 		respondWithError(w, 500, fmt.Sprintf("Error in parsing team membership uuid: %s", err))
 		return
 	}
-	notAssignedRoles, err := apiCfg.DB.GetNotAssignedRoles(r.Context(), parsedmembershipUUID)
+	notAssignedRoles, err := apiCfg.DB.GetNotAssignedRoles(r.Context(), database.GetNotAssignedRolesParams{
+		TeamMembershipID: parsedmembershipUUID,
+		TeamID:           parsedTeamUUID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting not assigned roles: %s", err))
+		return
+	}
 	respondWithJson(w, 200, databaseNotAssignedRolesToNotAssignedRoles(notAssignedRoles))
 }
+
+func (apiCfg *apiConfig) RemoveTeamMember(w http.ResponseWriter, r *http.Request, user database.User) {
+	teamId := r.PathValue("teamid")
+	parsedTeamUUID, err := uuid.Parse(teamId)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
+	membershipId := r.PathValue("membership_id")
+	parsedMembershipUUID, err := uuid.Parse(membershipId)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team membership uuid: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	membership, err := apiCfg.DB.GetTeamMembership(r.Context(), database.GetTeamMembershipParams{
+		ID:     parsedMembershipUUID,
+		TeamID: parsedTeamUUID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Team member not found")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team membership: %s", err))
+		return
+	}
+	if membership.UserID == user.ID {
+		respondWithError(w, 409, "The team owner cannot be removed , transfer the ownership or delete the team instead")
+		return
+	}
+	err = apiCfg.removeTeamMembership(r.Context(), membership)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in removing team member: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Team member removed successfully")
+}
+
+func (apiCfg *apiConfig) LeaveTeam(w http.ResponseWriter, r *http.Request, user database.User) {
+	teamId := r.PathValue("teamid")
+	parsedTeamUUID, err := uuid.Parse(teamId)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
+	membership, err := apiCfg.DB.GetTeamMembershipByUser(r.Context(), database.GetTeamMembershipByUserParams{
+		TeamID: parsedTeamUUID,
+		UserID: user.ID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "You are not a member of this team")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team membership: %s", err))
+		return
+	}
+	isOwner, err := apiCfg.DB.IsTeamOwner(r.Context(), database.IsTeamOwnerParams{
+		TeamID: parsedTeamUUID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team ownership: %s", err))
+		return
+	}
+	if isOwner {
+		respondWithError(w, 409, "The team owner cannot leave the team , transfer the ownership or delete the team instead")
+		return
+	}
+	err = apiCfg.removeTeamMembership(r.Context(), membership)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in leaving team: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "You left the team successfully")
+}
+
+// removeTeamMembership deletes the membership together with its role
+// assignments and pending activity requests, and revokes the invitations the
+// member still has pending for the team. Team activity logs are kept as history.
+func (apiCfg *apiConfig) removeTeamMembership(ctx context.Context, membership database.TeamMembership) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	err = qtx.RevokeSentTeamInvitations(ctx, database.RevokeSentTeamInvitationsParams{
+		TeamID:   membership.TeamID,
+		SenderID: membership.UserID,
+	})
+	if err != nil {
+		return err
+	}
+	err = qtx.DeleteUserTeamActivityRequests(ctx, database.DeleteUserTeamActivityRequestsParams{
+		TeamID: membership.TeamID,
+		UserID: membership.UserID,
+	})
+	if err != nil {
+		return err
+	}
+	err = qtx.DeleteTeamMembership(ctx, membership.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (apiCfg *apiConfig) TransferTeamOwnership(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		MembershipID string `json:"membership_id"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
+	teamId := r.PathValue("teamid")
+	parsedTeamUUID, err := uuid.Parse(teamId)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
+	parsedMembershipUUID, err := uuid.Parse(params.MembershipID)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team membership uuid: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	newOwnerMembership, err := apiCfg.DB.GetTeamMembership(r.Context(), database.GetTeamMembershipParams{
+		ID:     parsedMembershipUUID,
+		TeamID: parsedTeamUUID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Team member not found")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team membership: %s", err))
+		return
+	}
+	if newOwnerMembership.UserID == user.ID {
+		respondWithError(w, 400, "You are already the owner of this team")
+		return
+	}
+	currentOwnerMembership, err := apiCfg.DB.GetTeamMembershipByUser(r.Context(), database.GetTeamMembershipByUserParams{
+		TeamID: parsedTeamUUID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team membership: %s", err))
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in starting transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	err = transferTeamOwnership(r.Context(), qtx, currentOwnerMembership, newOwnerMembership)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in transferring team ownership: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Team ownership transferred successfully")
+}
+
+// transferTeamOwnership moves the owner role and teams.created_by from one
+// member of the team to another.
+func transferTeamOwnership(ctx context.Context, qtx *database.Queries, currentOwnerMembership database.TeamMembership, newOwnerMembership database.TeamMembership) error {
+	ownerRole, err := qtx.GetTeamOwnerRole(ctx, currentOwnerMembership.TeamID)
+	if err != nil {
+		return err
+	}
+	err = qtx.DeleteMemberRole(ctx, database.DeleteMemberRoleParams{
+		TeamMembershipID: currentOwnerMembership.ID,
+		RoleID:           ownerRole.ID,
+	})
+	if err != nil {
+		return err
+	}
+	err = qtx.SetMemberRoles(ctx, database.SetMemberRolesParams{
+		ID:               uuid.New(),
+		TeamMembershipID: newOwnerMembership.ID,
+		RoleID:           ownerRole.ID,
+	})
+	if err != nil {
+		return err
+	}
+	return qtx.SetTeamCreatedBy(ctx, database.SetTeamCreatedByParams{
+		CreatedBy: newOwnerMembership.UserID,
+		ID:        newOwnerMembership.TeamID,
+	})
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpIssuer = "Productivity Tracker"
+	totpPeriod = 30
+	totpDigits = 6
+	// Codes of this many neighbouring time steps are accepted to allow for
+	// clock drift.
+	totpSkew          = 1
+	recoveryCodeCount = 10
+	// How long the password step of a login stays valid while waiting for
+	// the second factor.
+	mfaTokenTTL      = 5 * time.Minute
+	mfaTokenAudience = "mfa"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type mfaRequiredResponse struct {
+	MfaRequired bool      `json:"mfa_required"`
+	MfaToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// totpCode computes the RFC 6238 code of the secret for a time step.
+func totpCode(secret []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// matchTotpCode returns the time step the code belongs to, if it is valid now.
+func matchTotpCode(secret string, code string) (int64, bool) {
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		return 0, false
+	}
+	code = strings.TrimSpace(code)
+	current := time.Now().Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func generateRecoveryCodes() ([]string, error) {
+	codes := []string{}
+	for i := 0; i < recoveryCodeCount; i++ {
+		random := make([]byte, 10)
+		_, err := rand.Read(random)
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(random))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+	}
+	return codes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return hashToken(strings.ReplaceAll(code, "-", ""))
+}
+
+// replaceRecoveryCodes invalidates the user's recovery codes and returns a
+// new set. Only their hashes are stored.
+func (apiCfg *apiConfig) replaceRecoveryCodes(ctx context.Context, qtx *database.Queries, userID uuid.UUID) ([]string, error) {
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	codeHashes := []string{}
+	for _, code := range codes {
+		codeHashes = append(codeHashes, hashRecoveryCode(code))
+	}
+	err = qtx.DeleteUserRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = qtx.CreateRecoveryCodes(ctx, database.CreateRecoveryCodesParams{
+		UserID:     userID,
+		CodeHashes: codeHashes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused recovery
+// code. Both can only be used once.
+func (apiCfg *apiConfig) verifySecondFactor(ctx context.Context, totp database.UserTotp, code string, recoveryCode string) (bool, error) {
+	if recoveryCode != "" {
+		used, err := apiCfg.DB.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+			UserID:   totp.UserID,
+			CodeHash: hashRecoveryCode(recoveryCode),
+		})
+		return used == 1, err
+	}
+	step, ok := matchTotpCode(totp.Secret, code)
+	if !ok {
+		return false, nil
+	}
+	used, err := apiCfg.DB.UseTotpStep(ctx, database.UseTotpStepParams{
+		UserID:       totp.UserID,
+		LastUsedStep: step,
+	})
+	return used == 1, err
+}
+
+// getConfirmedTotp returns the user's TOTP enrollment and whether it is active.
+func (apiCfg *apiConfig) getConfirmedTotp(ctx context.Context, userID uuid.UUID) (database.UserTotp, bool, error) {
+	totp, err := apiCfg.DB.GetUserTotp(ctx, userID)
+	if err == sql.ErrNoRows {
+		return database.UserTotp{}, false, nil
+	} else if err != nil {
+		return database.UserTotp{}, false, err
+	}
+	return totp, totp.ConfirmedAt.Valid, nil
+}
+
+// createMfaToken issues the token LogInUser returns instead of a session when
+// the account has two-factor authentication enabled.
+func createMfaToken(user database.User) (mfaRequiredResponse, error) {
+	expiresAt := time.Now().Add(mfaTokenTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Subject:   user.ID.String(),
+		Audience:  jwt.ClaimStrings{mfaTokenAudience},
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	})
+	signedToken, err := token.SignedString([]byte(jwtSecret))
+	if err != nil {
+		return mfaRequiredResponse{}, err
+	}
+	return mfaRequiredResponse{MfaRequired: true, MfaToken: signedToken, ExpiresAt: expiresAt}, nil
+}
+
+func (apiCfg *apiConfig) GetMfaStatus(w http.ResponseWriter, r *http.Request, user database.User) {
+	_, enabled, err := apiCfg.getConfirmedTotp(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting two-factor authentication: %s", err))
+		return
+	}
+	remaining := int64(0)
+	if enabled {
+		remaining, err = apiCfg.DB.CountUnusedRecoveryCodes(r.Context(), user.ID)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error counting recovery codes: %s", err))
+			return
+		}
+	}
+	respondWithJson(w, 200, struct {
+		TotpEnabled            bool  `json:"totp_enabled"`
+		RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+	}{TotpEnabled: enabled, RecoveryCodesRemaining: remaining})
+}
+
+// EnrollTotp creates a new secret for the user. It has to be confirmed with a
+// code before logins ask for it.
+func (apiCfg *apiConfig) EnrollTotp(w http.ResponseWriter, r *http.Request, user database.User) {
+	if !user.PasswordHash.Valid {
+		respondWithError(w, 400, "Two-factor authentication is only available for accounts with a password")
+		return
+	}
+	_, enabled, err := apiCfg.getConfirmedTotp(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting two-factor authentication: %s", err))
+		return
+	}
+	if enabled {
+		respondWithError(w, 409, "Two-factor authentication is already enabled")
+		return
+	}
+	key := make([]byte, 20)
+	_, err = rand.Read(key)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error generating secret: %s", err))
+		return
+	}
+	secret := totpEncoding.EncodeToString(key)
+	err = apiCfg.DB.UpsertUserTotp(r.Context(), database.UpsertUserTotpParams{
+		UserID: user.ID,
+		Secret: secret,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error saving secret: %s", err))
+		return
+	}
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", totpIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	otpauthURI := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + totpIssuer + ":" + user.Email,
+		RawQuery: query.Encode(),
+	}
+	respondWithJson(w, 200, struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}{Secret: secret, OtpauthURI: otpauthURI.String()})
+}
+
+// ConfirmTotp enables two-factor authentication once the first code matches
+// and returns the recovery codes. They are not shown again.
+func (apiCfg *apiConfig) ConfirmTotp(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
+		return
+	}
+	totp, err := apiCfg.DB.GetUserTotp(r.Context(), user.ID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Two-factor authentication enrollment not found")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting two-factor authentication: %s", err))
+		return
+	}
+	if totp.ConfirmedAt.Valid {
+		respondWithError(w, 409, "Two-factor authentication is already enabled")
+		return
+	}
+	step, ok := matchTotpCode(totp.Secret, params.Code)
+	if !ok {
+		respondWithError(w, 400, "Invalid code")
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	err = qtx.ConfirmUserTotp(r.Context(), database.ConfirmUserTotpParams{
+		UserID:       user.ID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error confirming two-factor authentication: %s", err))
+		return
+	}
+	codes, err := apiCfg.replaceRecoveryCodes(r.Context(), qtx, user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error creating recovery codes: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{RecoveryCodes: codes})
+}
+
+func (apiCfg *apiConfig) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Code string `json:"code"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
+		return
+	}
+	totp, enabled, err := apiCfg.getConfirmedTotp(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting two-factor authentication: %s", err))
+		return
+	}
+	if !enabled {
+		respondWithError(w, 404, "Two-factor authentication is not enabled")
+		return
+	}
+	valid, err := apiCfg.verifySecondFactor(r.Context(), totp, params.Code, "")
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error verifying code: %s", err))
+		return
+	}
+	if !valid {
+		respondWithError(w, 400, "Invalid code")
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	codes, err := apiCfg.replaceRecoveryCodes(r.Context(), apiCfg.DB.WithTx(tx), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error creating recovery codes: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{RecoveryCodes: codes})
+}
+
+// DisableTotp turns two-factor authentication off. It needs the password and
+// a code or recovery code, so a stolen session alone cannot remove it.
+func (apiCfg *apiConfig) DisableTotp(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
+		return
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(params.Password))
+	if !user.PasswordHash.Valid || err != nil {
+		respondWithError(w, 401, "Invalid password")
+		return
+	}
+	totp, enabled, err := apiCfg.getConfirmedTotp(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting two-factor authentication: %s", err))
+		return
+	}
+	if !enabled {
+		respondWithError(w, 404, "Two-factor authentication is not enabled")
+		return
+	}
+	valid, err := apiCfg.verifySecondFactor(r.Context(), totp, params.Code, params.RecoveryCode)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error verifying code: %s", err))
+		return
+	}
+	if !valid {
+		respondWithError(w, 400, "Invalid code")
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	err = qtx.DeleteUserTotp(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error disabling two-factor authentication: %s", err))
+		return
+	}
+	err = qtx.DeleteUserRecoveryCodes(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error deleting recovery codes: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Two-factor authentication disabled successfully")
+}
+
+// CompleteMfaLogin exchanges the mfa token from LogInUser and a valid code
+// for a session.
+func (apiCfg *apiConfig) CompleteMfaLogin(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		MfaToken     string `json:"mfa_token"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
+		return
+	}
+	claims := &jwt.RegisteredClaims{}
+	_, err = jwt.ParseWithClaims(params.MfaToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(jwtSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(mfaTokenAudience), jwt.WithExpirationRequired())
+	if err != nil {
+		respondWithError(w, 401, "Invalid or expired mfa token , please log in again")
+		return
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		respondWithError(w, 401, "Invalid or expired mfa token , please log in again")
+		return
+	}
+	user, err := apiCfg.DB.GetUserById(r.Context(), userID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, 401, "Invalid or expired mfa token , please log in again")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting user: %s", err))
+		return
+	}
+	// A password reset in the meantime invalidates the password step.
+	if claims.IssuedAt == nil || user.TokensValidAfter.Valid && claims.IssuedAt.Time.Before(user.TokensValidAfter.Time.Truncate(time.Second)) {
+		respondWithError(w, 401, "Invalid or expired mfa token , please log in again")
+		return
+	}
+	totp, enabled, err := apiCfg.getConfirmedTotp(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting two-factor authentication: %s", err))
+		return
+	}
+	if enabled {
+		if !apiCfg.checkLoginLockout(w, r, user) {
+			return
+		}
+		valid, err := apiCfg.verifySecondFactor(r.Context(), totp, params.Code, params.RecoveryCode)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error verifying code: %s", err))
+			return
+		}
+		if !valid {
+			err = apiCfg.recordLoginFailure(r, user)
+			if err != nil {
+				respondWithError(w, 500, fmt.Sprintf("Error verifying code: %s", err))
+				return
+			}
+			respondWithError(w, 401, "Invalid code")
+			return
+		}
+	}
+	err = apiCfg.DB.ClearLoginFailures(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error clearing failed logins: %s", err))
+		return
+	}
+	tokens, err := apiCfg.createSession(r, user)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in generating JWT token for user: %s", err))
+		return
+	}
+	respondWithJson(w, 200, tokens)
+}
@@ -1,55 +1,159 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/mrdkvcs/go-base-backend/internal/database"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 )
 
 type authHandler func(http.ResponseWriter, *http.Request, database.User)
 
+type contextKey string
+
+const (
+	userContextKey   contextKey = "user"
+	claimsContextKey contextKey = "claims"
+)
+
+// authError is an authentication failure that is the client's fault and is
+// answered with a 401, as opposed to a database error.
+type authError struct {
+	message string
+}
+
+func (e *authError) Error() string {
+	return e.message
+}
+
 func (apiCfg *apiConfig) middlewareAuth(handler authHandler) http.HandlerFunc {
+	return apiCfg.middlewareAuthWithScope("", handler)
+}
+
+// middlewareAuthWithScope is middlewareAuth for endpoints that also accept
+// personal API tokens, as long as the token was granted the scope. Routes
+// wrapped with middlewareAuth refuse API tokens.
+func (apiCfg *apiConfig) middlewareAuthWithScope(scope string, handler authHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var tokenString string
-		authHeader := r.Header.Get("Authorization")
-		if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
-			tokenString = strings.TrimPrefix(authHeader, "Bearer ")
-		} else {
-			cookie, err := r.Cookie("token")
-			if err == nil {
-				tokenString = cookie.Value
+		tokenString := getRequestToken(r)
+		var user database.User
+		var claims *Claims
+		var err error
+		if strings.HasPrefix(tokenString, apiTokenPrefix) {
+			var apiToken database.ApiToken
+			user, apiToken, err = apiCfg.authenticateApiToken(r.Context(), tokenString)
+			if err == nil && scope == "" {
+				respondWithError(w, 403, "API tokens cannot be used for this endpoint")
+				return
+			} else if err == nil && !slices.Contains(apiToken.Scopes, scope) {
+				respondWithError(w, 403, fmt.Sprintf("API token is missing the %s scope", scope))
+				return
 			}
+		} else {
+			user, claims, err = apiCfg.authenticate(r.Context(), tokenString)
 		}
-
-		if tokenString == "" {
-			respondWithJson(w, 401, "Unauthorized user , missing token")
+		var authErr *authError
+		if errors.As(err, &authErr) {
+			respondWithError(w, 401, fmt.Sprintf("Unauthorized user , %s", authErr.message))
 			return
-		}
-
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-			}
-			return []byte(jwtSecret), nil
-		})
-
-		if err != nil {
-			respondWithJson(w, 401, "Unauthorized user , invalid token")
+		} else if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error in authenticating user: %s", err))
 			return
 		}
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		if claims != nil {
+			ctx = context.WithValue(ctx, claimsContextKey, claims)
+		}
+		handler(w, r.WithContext(ctx), user)
+	}
+}
 
-		if !token.Valid {
-			respondWithJson(w, 401, "Unauthorized user , token expired")
-			return
+// authenticate resolves the user an access token was issued to. Tokens issued
+// before the user's tokens_valid_after, or for a revoked session, are refused.
+func (apiCfg *apiConfig) authenticate(ctx context.Context, tokenString string) (database.User, *Claims, error) {
+	if tokenString == "" {
+		return database.User{}, nil, &authError{"missing token"}
+	}
+	claims, err := parseAccessToken(tokenString)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return database.User{}, nil, &authError{"token expired"}
+	} else if err != nil {
+		return database.User{}, nil, &authError{"invalid token"}
+	}
+	user, err := apiCfg.DB.GetUserById(ctx, claims.UserID)
+	if err == sql.ErrNoRows {
+		return database.User{}, nil, &authError{"user not found"}
+	} else if err != nil {
+		return database.User{}, nil, err
+	}
+	if user.TokensValidAfter.Valid {
+		// iat only has second precision.
+		validAfter := user.TokensValidAfter.Time.Truncate(time.Second)
+		if claims.IssuedAt == nil || claims.IssuedAt.Time.Before(validAfter) {
+			return database.User{}, nil, &authError{"token revoked"}
 		}
-		user, err := apiCfg.DB.GetUserByEmail(r.Context(), claims.Email)
-		if err != nil {
-			respondWithError(w, 500, fmt.Sprintf("Could not find user with email %s", claims.Email))
-			return
+	}
+	sessionActive, err := apiCfg.DB.IsSessionActive(ctx, database.IsSessionActiveParams{
+		ID:     claims.SessionID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return database.User{}, nil, err
+	}
+	if !sessionActive {
+		return database.User{}, nil, &authError{"session revoked"}
+	}
+	return user, claims, nil
+}
+
+// userFromContext returns the user middlewareAuth authenticated the request as.
+func userFromContext(ctx context.Context) (database.User, bool) {
+	user, ok := ctx.Value(userContextKey).(database.User)
+	return user, ok
+}
+
+// claimsFromContext returns the access token claims of an authenticated request.
+func claimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*Claims)
+	return claims, ok
+}
+
+// getRequestToken returns the bearer token from the Authorization header,
+// falling back to the token cookie.
+func getRequestToken(r *http.Request) string {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
+		return strings.TrimPrefix(authHeader, "Bearer ")
+	}
+	cookie, err := r.Cookie("token")
+	if err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// parseAccessToken verifies a login JWT. Websocket tickets and Google link
+// tokens are signed with the same secret, so they are rejected here by their
+// audience.
+func parseAccessToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
 		}
-		handler(w, r, user)
+		return []byte(jwtSecret), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(claims.Audience) > 0 {
+		return nil, fmt.Errorf("Only access tokens can be used to authenticate")
 	}
+	return claims, nil
 }
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"database/sql"
+	"encoding/json"
 	"github.com/google/uuid"
 	"github.com/mrdkvcs/go-base-backend/internal/database"
 	"time"
@@ -11,6 +13,12 @@ type ActivityRow interface {
 	GetName() string
 	GetPoints() int32
 	GetActivityType() string
+	GetScoring() ActivityScoring
+	GetCategory() string
+	GetTags() []string
+	GetIcon() string
+	GetColour() string
+	GetArchivedAt() sql.NullTime
 }
 
 type GetActivitiesRowWrapper struct {
@@ -21,6 +29,14 @@ func (g GetActivitiesRowWrapper) GetID() uuid.UUID        { return g.ID }
 func (g GetActivitiesRowWrapper) GetName() string         { return g.Name }
 func (g GetActivitiesRowWrapper) GetPoints() int32        { return g.Points }
 func (g GetActivitiesRowWrapper) GetActivityType() string { return g.ActivityType }
+func (g GetActivitiesRowWrapper) GetScoring() ActivityScoring {
+	return databaseScoringToActivityScoring(g.ScoringModel, g.ScoringDailyCap, g.ScoringThresholdMinutes)
+}
+func (g GetActivitiesRowWrapper) GetCategory() string         { return g.Category }
+func (g GetActivitiesRowWrapper) GetTags() []string           { return g.Tags }
+func (g GetActivitiesRowWrapper) GetIcon() string             { return g.Icon }
+func (g GetActivitiesRowWrapper) GetColour() string           { return g.Colour }
+func (g GetActivitiesRowWrapper) GetArchivedAt() sql.NullTime { return g.ArchivedAt }
 
 type SetActivityRowWrapper struct {
 	database.SetActivityRow
@@ -30,15 +46,39 @@ func (s SetActivityRowWrapper) GetID() uuid.UUID        { return s.ID }
 func (s SetActivityRowWrapper) GetName() string         { return s.Name }
 func (s SetActivityRowWrapper) GetPoints() int32        { return s.Points }
 func (s SetActivityRowWrapper) GetActivityType() string { return s.ActivityType }
+func (s SetActivityRowWrapper) GetScoring() ActivityScoring {
+	return databaseScoringToActivityScoring(s.ScoringModel, s.ScoringDailyCap, s.ScoringThresholdMinutes)
+}
+func (s SetActivityRowWrapper) GetCategory() string         { return s.Category }
+func (s SetActivityRowWrapper) GetTags() []string           { return s.Tags }
+func (s SetActivityRowWrapper) GetIcon() string             { return s.Icon }
+func (s SetActivityRowWrapper) GetColour() string           { return s.Colour }
+func (s SetActivityRowWrapper) GetArchivedAt() sql.NullTime { return s.ArchivedAt }
 
 type User struct {
-	ID           uuid.UUID   `json:"id"`
-	CreatedAt    time.Time   `json:"created_at"`
-	UpdatedAt    time.Time   `json:"updated_at"`
-	Username     string      `json:"username"`
-	Email        string      `json:"email"`
-	PasswordHash interface{} `json:"password_hash"`
-	GoogleID     interface{} `json:"google_id"`
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	Username            string     `json:"username"`
+	DisplayName         string     `json:"display_name"`
+	AvatarURL           string     `json:"avatar_url"`
+	Timezone            string     `json:"timezone"`
+	Locale              string     `json:"locale"`
+	Email               string     `json:"email"`
+	EmailVerified       bool       `json:"email_verified"`
+	HasPassword         bool       `json:"has_password"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+}
+
+type Identity struct {
+	Provider string     `json:"provider"`
+	Email    string     `json:"email,omitempty"`
+	LinkedAt *time.Time `json:"linked_at,omitempty"`
+}
+
+type AuthProvider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
 }
 
 type SearchedUser struct {
@@ -55,10 +95,53 @@ type SuggestFeature struct {
 }
 
 type Activity struct {
-	ActivityID uuid.UUID `json:"activity_id"`
-	Name       string    `json:"name"`
-	Points     int32     `json:"points"`
-	Type       string    `json:"type"`
+	ActivityID uuid.UUID       `json:"activity_id"`
+	Name       string          `json:"name"`
+	Points     int32           `json:"points"`
+	Type       string          `json:"type"`
+	Scoring    ActivityScoring `json:"scoring"`
+	Category   string          `json:"category"`
+	Tags       []string        `json:"tags"`
+	Icon       string          `json:"icon"`
+	Colour     string          `json:"colour"`
+	Archived   bool            `json:"archived"`
+}
+
+type ActivityScoring struct {
+	Model            string `json:"model"`
+	DailyCap         *int32 `json:"daily_cap,omitempty"`
+	ThresholdMinutes *int32 `json:"threshold_minutes,omitempty"`
+}
+
+type ActivityTemplate struct {
+	ID              uuid.UUID              `json:"id"`
+	Slug            *string                `json:"slug"`
+	Name            string                 `json:"name"`
+	Description     string                 `json:"description"`
+	Curated         bool                   `json:"curated"`
+	CreatedBy       *uuid.UUID             `json:"created_by"`
+	CreatorUsername *string                `json:"creator_username"`
+	Published       bool                   `json:"published"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+	Activities      []ActivityTemplateItem `json:"activities"`
+}
+
+type ActivityTemplateItem struct {
+	Name     string          `json:"name"`
+	Points   int32           `json:"points"`
+	Scoring  ActivityScoring `json:"scoring"`
+	Category string          `json:"category"`
+	Tags     []string        `json:"tags"`
+	Icon     string          `json:"icon"`
+	Colour   string          `json:"colour"`
+}
+
+// ActivityTemplateImport lists the activities an import created and the ones
+// it skipped because an activity with the same name already existed.
+type ActivityTemplateImport struct {
+	Imported []string `json:"imported"`
+	Skipped  []string `json:"skipped"`
 }
 
 type ActivityLog struct {
@@ -95,6 +178,24 @@ type ProductivityStats struct {
 	BestProductivityDay        BestProductivityDay        `json:"best_productivity_day"`
 	ProductivityDays           []ProductivityDay          `json:"productivity_days"`
 	ProductiveUnProductiveTime ProductiveUnProductiveTime `json:"time"`
+	Categories                 []CategoryBreakdown        `json:"categories"`
+	Tags                       []TagBreakdown             `json:"tags"`
+}
+
+// CategoryBreakdown is the time and points logged in a category, one time logs
+// and uncategorised activities are counted under an empty category.
+type CategoryBreakdown struct {
+	Category     string `json:"category"`
+	TotalMinutes int32  `json:"total_minutes"`
+	TotalPoints  int32  `json:"total_points"`
+}
+
+// TagBreakdown is the time and points logged for activities with the tag, a
+// log counts towards every tag of its activity.
+type TagBreakdown struct {
+	Tag          string `json:"tag"`
+	TotalMinutes int32  `json:"total_minutes"`
+	TotalPoints  int32  `json:"total_points"`
 }
 
 type DailyProductiveTime struct {
@@ -137,9 +238,18 @@ type Team struct {
 }
 
 type TeamActivity struct {
-	ActivityName  string   `json:"activity_name"`
-	Points        int32    `json:"points"`
-	ActivityRoles []string `json:"activity_roles"`
+	ID            uuid.UUID          `json:"id"`
+	ActivityName  string             `json:"activity_name"`
+	Points        int32              `json:"points"`
+	Archived      bool               `json:"archived"`
+	ActivityRoles []string           `json:"activity_roles"`
+	Roles         []TeamActivityRole `json:"roles"`
+}
+
+type TeamActivityRole struct {
+	ID       uuid.UUID `json:"id"`
+	RoleName string    `json:"role_name"`
+	Points   *int32    `json:"points"`
 }
 
 type UserTeams struct {
@@ -176,11 +286,185 @@ type TeamRole struct {
 	RoleName string    `json:"role_name"`
 }
 type TeamInvitation struct {
-	InvitationID uuid.UUID `json:"invitation_id"`
-	TeamID       uuid.UUID `json:"team_id"`
-	TeamName     string    `json:"team_name"`
-	TeamIndustry string    `json:"team_industry"`
-	TeamSize     int32     `json:"team_size"`
+	InvitationID   uuid.UUID `json:"invitation_id"`
+	TeamID         uuid.UUID `json:"team_id"`
+	TeamName       string    `json:"team_name"`
+	TeamIndustry   string    `json:"team_industry"`
+	TeamSize       int32     `json:"team_size"`
+	SenderUsername string    `json:"sender_username"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+type DiscoveredTeam struct {
+	ID                uuid.UUID `json:"id"`
+	Name              string    `json:"team_name"`
+	TeamIndustry      string    `json:"team_industry"`
+	TeamSize          int32     `json:"team_size"`
+	MemberCount       int64     `json:"member_count"`
+	IsMember          bool      `json:"is_member"`
+	HasPendingRequest bool      `json:"has_pending_request"`
+}
+
+type TeamJoinRequest struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type UserTeamJoinRequest struct {
+	ID          uuid.UUID  `json:"id"`
+	TeamID      uuid.UUID  `json:"team_id"`
+	TeamName    string     `json:"team_name"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RespondedAt *time.Time `json:"responded_at"`
+}
+
+type Notification struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	Read      bool            `json:"read"`
+	ReadAt    *time.Time      `json:"read_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type Webhook struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     *uuid.UUID `json:"user_id"`
+	TeamID     *uuid.UUID `json:"team_id"`
+	URL        string     `json:"url"`
+	Secret     string     `json:"secret,omitempty"`
+	EventTypes []string   `json:"event_types"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at"`
+	ResponseStatus *int32          `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type ApiToken struct {
+	ID          uuid.UUID  `json:"id"`
+	Name        string     `json:"name"`
+	TokenPrefix string     `json:"token_prefix"`
+	Scopes      []string   `json:"scopes"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IpAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+type SentTeamInvitation struct {
+	InvitationID      uuid.UUID  `json:"invitation_id"`
+	TeamID            uuid.UUID  `json:"team_id"`
+	TeamName          string     `json:"team_name"`
+	RecipientUsername string     `json:"recipient_username"`
+	Status            string     `json:"status"`
+	CreatedAt         time.Time  `json:"created_at"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RespondedAt       *time.Time `json:"responded_at"`
+}
+
+type TeamInviteLink struct {
+	ID        uuid.UUID  `json:"id"`
+	Token     string     `json:"token,omitempty"`
+	URL       string     `json:"url,omitempty"`
+	MaxUses   *int32     `json:"max_uses"`
+	Uses      int32      `json:"uses"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	Revoked   bool       `json:"revoked"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type AccountExport struct {
+	ExportedAt      time.Time                `json:"exported_at"`
+	Profile         ExportedProfile          `json:"profile"`
+	Activities      []ExportedActivity       `json:"activities"`
+	ActivityLogs    []ExportedActivityLog    `json:"activity_logs"`
+	Goals           []ExportedGoal           `json:"goals"`
+	Streak          *ExportedStreak          `json:"streak"`
+	TeamMemberships []ExportedTeamMembership `json:"team_memberships"`
+	Suggestions     []SuggestFeature         `json:"suggestions"`
+}
+
+type ExportedProfile struct {
+	ID                  uuid.UUID  `json:"id"`
+	Username            string     `json:"username"`
+	DisplayName         string     `json:"display_name"`
+	AvatarURL           string     `json:"avatar_url"`
+	Timezone            string     `json:"timezone"`
+	Locale              string     `json:"locale"`
+	Email               string     `json:"email"`
+	EmailVerified       bool       `json:"email_verified"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+}
+
+type ExportedActivity struct {
+	ID         uuid.UUID       `json:"id"`
+	Name       string          `json:"name"`
+	Points     int32           `json:"points"`
+	Type       string          `json:"type"`
+	Scoring    ActivityScoring `json:"scoring"`
+	Category   string          `json:"category"`
+	Tags       []string        `json:"tags"`
+	Icon       string          `json:"icon"`
+	Colour     string          `json:"colour"`
+	ArchivedAt *time.Time      `json:"archived_at"`
+	DeletedAt  *time.Time      `json:"deleted_at"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+type ExportedActivityLog struct {
+	ID                  uuid.UUID  `json:"id"`
+	ActivityID          *uuid.UUID `json:"activity_id"`
+	ActivityName        *string    `json:"activity_name"`
+	Duration            int32      `json:"duration"`
+	Points              int32      `json:"points"`
+	ActivityDescription string     `json:"activity_description"`
+	LoggedAt            time.Time  `json:"logged_at"`
+}
+
+type ExportedGoal struct {
+	GoalDate   time.Time `json:"goal_date"`
+	GoalPoints int32     `json:"goal_points"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ExportedStreak struct {
+	CurrentStreak  int32      `json:"current_streak"`
+	LongestStreak  int32      `json:"longest_streak"`
+	LastLoggedDate *time.Time `json:"last_logged_date"`
+}
+
+type ExportedTeamMembership struct {
+	TeamID   uuid.UUID `json:"team_id"`
+	TeamName string    `json:"team_name"`
+	Roles    string    `json:"roles"`
+	JoinedAt time.Time `json:"joined_at"`
 }
 
 func databaseSuggestFeaturesToSuggestFeatures(dbSuggestFeatures []database.SuggestFeature) []SuggestFeature {
@@ -209,12 +493,71 @@ func databaseActivityLogsToActivityLogs(dbDailyActivityLogs []database.GetDailyA
 func databaseTeamInvitationsToTeamInvitations(dbTeamInvitations []database.GetTeamInvitationsRow) []TeamInvitation {
 	teamInvitations := []TeamInvitation{}
 	for _, dbTeamInvitation := range dbTeamInvitations {
-		teamInvitation := TeamInvitation{InvitationID: dbTeamInvitation.InvitationID, TeamID: dbTeamInvitation.TeamID, TeamName: dbTeamInvitation.TeamName, TeamIndustry: dbTeamInvitation.TeamIndustry, TeamSize: dbTeamInvitation.TeamSize}
+		teamInvitation := TeamInvitation{InvitationID: dbTeamInvitation.InvitationID, TeamID: dbTeamInvitation.TeamID, TeamName: dbTeamInvitation.TeamName, TeamIndustry: dbTeamInvitation.TeamIndustry, TeamSize: dbTeamInvitation.TeamSize, SenderUsername: dbTeamInvitation.SenderUsername, ExpiresAt: dbTeamInvitation.ExpiresAt}
 		teamInvitations = append(teamInvitations, teamInvitation)
 	}
 	return teamInvitations
 }
 
+func databaseSentTeamInvitationsToSentTeamInvitations(dbSentTeamInvitations []database.GetSentTeamInvitationsRow) []SentTeamInvitation {
+	sentTeamInvitations := []SentTeamInvitation{}
+	for _, dbSentTeamInvitation := range dbSentTeamInvitations {
+		sentTeamInvitation := SentTeamInvitation{InvitationID: dbSentTeamInvitation.InvitationID, TeamID: dbSentTeamInvitation.TeamID, TeamName: dbSentTeamInvitation.TeamName, RecipientUsername: dbSentTeamInvitation.RecipientUsername, Status: dbSentTeamInvitation.Status, CreatedAt: dbSentTeamInvitation.CreatedAt, ExpiresAt: dbSentTeamInvitation.ExpiresAt}
+		if dbSentTeamInvitation.RespondedAt.Valid {
+			sentTeamInvitation.RespondedAt = &dbSentTeamInvitation.RespondedAt.Time
+		}
+		sentTeamInvitations = append(sentTeamInvitations, sentTeamInvitation)
+	}
+	return sentTeamInvitations
+}
+
+func databaseDiscoveredTeamsToDiscoveredTeams(dbDiscoveredTeams []database.DiscoverTeamsRow) []DiscoveredTeam {
+	discoveredTeams := []DiscoveredTeam{}
+	for _, dbDiscoveredTeam := range dbDiscoveredTeams {
+		discoveredTeams = append(discoveredTeams, DiscoveredTeam{ID: dbDiscoveredTeam.ID, Name: dbDiscoveredTeam.Name, TeamIndustry: dbDiscoveredTeam.TeamIndustry, TeamSize: dbDiscoveredTeam.TeamSize, MemberCount: dbDiscoveredTeam.MemberCount, IsMember: dbDiscoveredTeam.IsMember, HasPendingRequest: dbDiscoveredTeam.HasPendingRequest})
+	}
+	return discoveredTeams
+}
+
+func databaseTeamJoinRequestsToTeamJoinRequests(dbTeamJoinRequests []database.GetTeamJoinRequestsRow) []TeamJoinRequest {
+	teamJoinRequests := []TeamJoinRequest{}
+	for _, dbTeamJoinRequest := range dbTeamJoinRequests {
+		teamJoinRequests = append(teamJoinRequests, TeamJoinRequest{ID: dbTeamJoinRequest.ID, UserID: dbTeamJoinRequest.UserID, Username: dbTeamJoinRequest.Username, Message: dbTeamJoinRequest.Message, CreatedAt: dbTeamJoinRequest.CreatedAt})
+	}
+	return teamJoinRequests
+}
+
+func databaseUserTeamJoinRequestsToUserTeamJoinRequests(dbUserTeamJoinRequests []database.GetUserTeamJoinRequestsRow) []UserTeamJoinRequest {
+	userTeamJoinRequests := []UserTeamJoinRequest{}
+	for _, dbUserTeamJoinRequest := range dbUserTeamJoinRequests {
+		userTeamJoinRequest := UserTeamJoinRequest{ID: dbUserTeamJoinRequest.ID, TeamID: dbUserTeamJoinRequest.TeamID, TeamName: dbUserTeamJoinRequest.TeamName, Status: dbUserTeamJoinRequest.Status, CreatedAt: dbUserTeamJoinRequest.CreatedAt}
+		if dbUserTeamJoinRequest.RespondedAt.Valid {
+			userTeamJoinRequest.RespondedAt = &dbUserTeamJoinRequest.RespondedAt.Time
+		}
+		userTeamJoinRequests = append(userTeamJoinRequests, userTeamJoinRequest)
+	}
+	return userTeamJoinRequests
+}
+
+func databaseTeamInviteLinkToTeamInviteLink(dbTeamInviteLink database.TeamInviteLink) TeamInviteLink {
+	teamInviteLink := TeamInviteLink{ID: dbTeamInviteLink.ID, Uses: dbTeamInviteLink.Uses, Revoked: dbTeamInviteLink.RevokedAt.Valid, CreatedAt: dbTeamInviteLink.CreatedAt}
+	if dbTeamInviteLink.MaxUses.Valid {
+		teamInviteLink.MaxUses = &dbTeamInviteLink.MaxUses.Int32
+	}
+	if dbTeamInviteLink.ExpiresAt.Valid {
+		teamInviteLink.ExpiresAt = &dbTeamInviteLink.ExpiresAt.Time
+	}
+	return teamInviteLink
+}
+
+func databaseTeamInviteLinksToTeamInviteLinks(dbTeamInviteLinks []database.TeamInviteLink) []TeamInviteLink {
+	teamInviteLinks := []TeamInviteLink{}
+	for _, dbTeamInviteLink := range dbTeamInviteLinks {
+		teamInviteLinks = append(teamInviteLinks, databaseTeamInviteLinkToTeamInviteLink(dbTeamInviteLink))
+	}
+	return teamInviteLinks
+}
+
 func databaseMembersToMembers(dbMembers []database.GetTeamMembersRow) []Member {
 	members := []Member{}
 	for _, dbMember := range dbMembers {
@@ -258,6 +601,15 @@ func databaseNotAssignedRolesToNotAssignedRoles(dbTeamRoles []database.GetNotAss
 	return teamroles
 }
 
+func databaseMemberRolesToTeamRoles(dbTeamRoles []database.GetMemberRolesRow) []TeamRole {
+	teamroles := []TeamRole{}
+	for _, dbteamrole := range dbTeamRoles {
+		teamrole := TeamRole{ID: dbteamrole.ID, RoleName: dbteamrole.RoleName}
+		teamroles = append(teamroles, teamrole)
+	}
+	return teamroles
+}
+
 func databaseUsersToUsers(dbUsers []database.GetUsersRow) []SearchedUser {
 	searchedUsers := []SearchedUser{}
 	for _, dbuser := range dbUsers {
@@ -287,10 +639,20 @@ func databaseUserTeamsToUserTeams(dbuserteams []database.GetUserTeamsRow) []User
 	return userteams
 }
 
-func databaseTeamActivityToTeamActivity(dbteamactivities []database.GetTeamActivitiesRow) []TeamActivity {
+func databaseTeamActivityToTeamActivity(dbteamactivities []database.GetTeamActivitiesRow, dbteamactivityroles []database.GetTeamActivityRolesRow) []TeamActivity {
 	teamactivities := []TeamActivity{}
 	for _, dbteamactivity := range dbteamactivities {
-		teamactivity := TeamActivity{ActivityName: dbteamactivity.ActivityName, Points: dbteamactivity.Points, ActivityRoles: dbteamactivity.ActivityRoles}
+		teamactivity := TeamActivity{ID: dbteamactivity.ID, ActivityName: dbteamactivity.ActivityName, Points: dbteamactivity.Points, Archived: dbteamactivity.ArchivedAt.Valid, ActivityRoles: []string{}, Roles: []TeamActivityRole{}}
+		for _, dbteamactivityrole := range dbteamactivityroles {
+			if dbteamactivityrole.TeamActivityID == dbteamactivity.ID {
+				teamactivityrole := TeamActivityRole{ID: dbteamactivityrole.RoleID, RoleName: dbteamactivityrole.RoleName}
+				if dbteamactivityrole.Points.Valid {
+					teamactivityrole.Points = &dbteamactivityrole.Points.Int32
+				}
+				teamactivity.ActivityRoles = append(teamactivity.ActivityRoles, dbteamactivityrole.RoleName)
+				teamactivity.Roles = append(teamactivity.Roles, teamactivityrole)
+			}
+		}
 		teamactivities = append(teamactivities, teamactivity)
 	}
 	return teamactivities
@@ -360,45 +722,256 @@ func databaseProductivityStatsToProductivityStats(productivityStats DatabaseProd
 		ProductiveTime:   productivityStats.ProductiveUnProductiveTime.ProductiveTime,
 		UnproductiveTime: productivityStats.ProductiveUnProductiveTime.UnproductiveTime,
 	}
+	categories := []CategoryBreakdown{}
+	for _, category := range productivityStats.Categories {
+		categories = append(categories, CategoryBreakdown{Category: category.Category, TotalMinutes: category.TotalMinutes, TotalPoints: category.TotalPoints})
+	}
+	tags := []TagBreakdown{}
+	for _, tag := range productivityStats.Tags {
+		tags = append(tags, TagBreakdown{Tag: tag.Tag, TotalMinutes: tag.TotalMinutes, TotalPoints: tag.TotalPoints})
+	}
 	return ProductivityStats{
 		ProductivvityPoints:        totalAveragePoints,
 		BestProductivityDay:        bestProductivityDay,
 		ProductivityDays:           productivityDays,
 		ProductiveUnProductiveTime: productiveUnproductiveTime,
+		Categories:                 categories,
+		Tags:                       tags,
 	}
 }
 
 func databaseActivitiesToActivities(dbAccs []database.GetActivitiesRow) []Activity {
 	activities := []Activity{}
 	for _, dbAcc := range dbAccs {
-		activity := Activity{ActivityID: dbAcc.ID, Name: dbAcc.Name, Points: dbAcc.Points, Type: dbAcc.ActivityType}
-		activities = append(activities, activity)
+		activities = append(activities, databaseActivityToActivity(GetActivitiesRowWrapper{dbAcc}))
 	}
 	return activities
 }
 func databaseActivityToActivity(dbAcc ActivityRow) Activity {
-	return Activity{ActivityID: dbAcc.GetID(), Name: dbAcc.GetName(), Points: dbAcc.GetPoints(), Type: dbAcc.GetActivityType()}
+	tags := dbAcc.GetTags()
+	if tags == nil {
+		tags = []string{}
+	}
+	return Activity{ActivityID: dbAcc.GetID(), Name: dbAcc.GetName(), Points: dbAcc.GetPoints(), Type: dbAcc.GetActivityType(), Scoring: dbAcc.GetScoring(), Category: dbAcc.GetCategory(), Tags: tags, Icon: dbAcc.GetIcon(), Colour: dbAcc.GetColour(), Archived: dbAcc.GetArchivedAt().Valid}
+}
+
+func databaseUserActivityToActivity(dbAcc database.UserActivity) Activity {
+	tags := dbAcc.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return Activity{ActivityID: dbAcc.ID, Name: dbAcc.Name, Points: dbAcc.Points, Type: dbAcc.ActivityType, Scoring: databaseScoringToActivityScoring(dbAcc.ScoringModel, dbAcc.ScoringDailyCap, dbAcc.ScoringThresholdMinutes), Category: dbAcc.Category, Tags: tags, Icon: dbAcc.Icon, Colour: dbAcc.Colour, Archived: dbAcc.ArchivedAt.Valid}
+}
+
+func databaseActivityTemplatesToActivityTemplates(dbTemplates []database.GetActivityTemplatesRow, dbItems []database.ActivityTemplateItem) []ActivityTemplate {
+	templates := []ActivityTemplate{}
+	for _, dbTemplate := range dbTemplates {
+		template := ActivityTemplate{ID: dbTemplate.ID, Name: dbTemplate.Name, Description: dbTemplate.Description, Curated: !dbTemplate.CreatedBy.Valid, Published: dbTemplate.PublishedAt.Valid, CreatedAt: dbTemplate.CreatedAt, UpdatedAt: dbTemplate.UpdatedAt, Activities: []ActivityTemplateItem{}}
+		if dbTemplate.Slug.Valid {
+			template.Slug = &dbTemplate.Slug.String
+		}
+		if dbTemplate.CreatedBy.Valid {
+			template.CreatedBy = &dbTemplate.CreatedBy.UUID
+		}
+		if dbTemplate.CreatorUsername.Valid {
+			template.CreatorUsername = &dbTemplate.CreatorUsername.String
+		}
+		for _, dbItem := range dbItems {
+			if dbItem.TemplateID == dbTemplate.ID {
+				template.Activities = append(template.Activities, databaseActivityTemplateItemToActivityTemplateItem(dbItem))
+			}
+		}
+		templates = append(templates, template)
+	}
+	return templates
+}
+
+func databaseActivityTemplateItemToActivityTemplateItem(dbItem database.ActivityTemplateItem) ActivityTemplateItem {
+	tags := dbItem.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return ActivityTemplateItem{Name: dbItem.Name, Points: dbItem.Points, Scoring: databaseScoringToActivityScoring(dbItem.ScoringModel, dbItem.ScoringDailyCap, dbItem.ScoringThresholdMinutes), Category: dbItem.Category, Tags: tags, Icon: dbItem.Icon, Colour: dbItem.Colour}
+}
+
+func databaseScoringToActivityScoring(model string, dailyCap sql.NullInt32, thresholdMinutes sql.NullInt32) ActivityScoring {
+	scoring := ActivityScoring{Model: model}
+	if dailyCap.Valid {
+		scoring.DailyCap = &dailyCap.Int32
+	}
+	if thresholdMinutes.Valid {
+		scoring.ThresholdMinutes = &thresholdMinutes.Int32
+	}
+	return scoring
 }
 
 func databaseUserToUser(dbuser database.User) User {
+	user := User{
+		ID:            dbuser.ID,
+		CreatedAt:     dbuser.CreatedAt,
+		UpdatedAt:     dbuser.UpdatedAt,
+		Username:      dbuser.Username,
+		DisplayName:   dbuser.DisplayName,
+		AvatarURL:     dbuser.AvatarUrl,
+		Timezone:      dbuser.Timezone,
+		Locale:        dbuser.Locale,
+		Email:         dbuser.Email,
+		EmailVerified: dbuser.EmailVerified,
+		HasPassword:   dbuser.PasswordHash.Valid,
+	}
+	if dbuser.DeletionScheduledAt.Valid {
+		user.DeletionScheduledAt = &dbuser.DeletionScheduledAt.Time
+	}
+	return user
+}
+
+func databaseIdentitiesToIdentities(dbuser database.User, dbIdentities []database.UserIdentity) []Identity {
+	identities := []Identity{}
 	if dbuser.PasswordHash.Valid {
-		return User{
-			ID:           dbuser.ID,
-			CreatedAt:    dbuser.CreatedAt,
-			UpdatedAt:    dbuser.UpdatedAt,
-			Username:     dbuser.Username,
-			Email:        dbuser.Email,
-			PasswordHash: dbuser.PasswordHash.String,
-			GoogleID:     nil,
+		identities = append(identities, Identity{Provider: IdentityPassword})
+	}
+	for _, dbIdentity := range dbIdentities {
+		identities = append(identities, Identity{Provider: dbIdentity.Provider, Email: dbIdentity.Email, LinkedAt: &dbIdentity.CreatedAt})
+	}
+	return identities
+}
+
+func databaseNotificationsToNotifications(dbNotifications []database.Notification) []Notification {
+	notifications := []Notification{}
+	for _, dbNotification := range dbNotifications {
+		notification := Notification{ID: dbNotification.ID, Type: dbNotification.Type, Payload: dbNotification.Payload, Read: dbNotification.ReadAt.Valid, CreatedAt: dbNotification.CreatedAt}
+		if dbNotification.ReadAt.Valid {
+			notification.ReadAt = &dbNotification.ReadAt.Time
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications
+}
+
+func databaseWebhookToWebhook(dbWebhook database.Webhook) Webhook {
+	webhook := Webhook{ID: dbWebhook.ID, URL: dbWebhook.Url, EventTypes: dbWebhook.EventTypes, CreatedAt: dbWebhook.CreatedAt}
+	if dbWebhook.UserID.Valid {
+		webhook.UserID = &dbWebhook.UserID.UUID
+	}
+	if dbWebhook.TeamID.Valid {
+		webhook.TeamID = &dbWebhook.TeamID.UUID
+	}
+	return webhook
+}
+
+func databaseWebhooksToWebhooks(dbWebhooks []database.Webhook) []Webhook {
+	webhooks := []Webhook{}
+	for _, dbWebhook := range dbWebhooks {
+		webhooks = append(webhooks, databaseWebhookToWebhook(dbWebhook))
+	}
+	return webhooks
+}
+
+func databaseWebhookDeliveryToWebhookDelivery(dbDelivery database.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{ID: dbDelivery.ID, EventType: dbDelivery.EventType, Payload: dbDelivery.Payload, Status: dbDelivery.Status, Attempts: dbDelivery.Attempts, CreatedAt: dbDelivery.CreatedAt}
+	if dbDelivery.Status == "pending" {
+		delivery.NextAttemptAt = &dbDelivery.NextAttemptAt
+	}
+	if dbDelivery.LastAttemptAt.Valid {
+		delivery.LastAttemptAt = &dbDelivery.LastAttemptAt.Time
+	}
+	if dbDelivery.ResponseStatus.Valid {
+		delivery.ResponseStatus = &dbDelivery.ResponseStatus.Int32
+	}
+	if dbDelivery.LastError.Valid {
+		delivery.LastError = &dbDelivery.LastError.String
+	}
+	return delivery
+}
+
+func databaseWebhookDeliveriesToWebhookDeliveries(dbDeliveries []database.WebhookDelivery) []WebhookDelivery {
+	deliveries := []WebhookDelivery{}
+	for _, dbDelivery := range dbDeliveries {
+		deliveries = append(deliveries, databaseWebhookDeliveryToWebhookDelivery(dbDelivery))
+	}
+	return deliveries
+}
+
+func databaseSessionsToSessions(dbSessions []database.Session, currentSessionID uuid.UUID) []Session {
+	sessions := []Session{}
+	for _, dbSession := range dbSessions {
+		sessions = append(sessions, Session{ID: dbSession.ID, UserAgent: dbSession.UserAgent, IpAddress: dbSession.IpAddress, CreatedAt: dbSession.CreatedAt, LastUsedAt: dbSession.LastUsedAt, ExpiresAt: dbSession.ExpiresAt, Current: dbSession.ID == currentSessionID})
+	}
+	return sessions
+}
+
+func databaseApiTokenToApiToken(dbApiToken database.ApiToken) ApiToken {
+	apiToken := ApiToken{ID: dbApiToken.ID, Name: dbApiToken.Name, TokenPrefix: dbApiToken.TokenPrefix, Scopes: dbApiToken.Scopes, CreatedAt: dbApiToken.CreatedAt}
+	if dbApiToken.LastUsedAt.Valid {
+		apiToken.LastUsedAt = &dbApiToken.LastUsedAt.Time
+	}
+	if dbApiToken.ExpiresAt.Valid {
+		apiToken.ExpiresAt = &dbApiToken.ExpiresAt.Time
+	}
+	return apiToken
+}
+
+func databaseApiTokensToApiTokens(dbApiTokens []database.ApiToken) []ApiToken {
+	apiTokens := []ApiToken{}
+	for _, dbApiToken := range dbApiTokens {
+		apiTokens = append(apiTokens, databaseApiTokenToApiToken(dbApiToken))
+	}
+	return apiTokens
+}
+
+func databaseUserToExportedProfile(dbuser database.User) ExportedProfile {
+	profile := ExportedProfile{ID: dbuser.ID, Username: dbuser.Username, DisplayName: dbuser.DisplayName, AvatarURL: dbuser.AvatarUrl, Timezone: dbuser.Timezone, Locale: dbuser.Locale, Email: dbuser.Email, EmailVerified: dbuser.EmailVerified, CreatedAt: dbuser.CreatedAt, UpdatedAt: dbuser.UpdatedAt}
+	if dbuser.DeletionScheduledAt.Valid {
+		profile.DeletionScheduledAt = &dbuser.DeletionScheduledAt.Time
+	}
+	return profile
+}
+
+func databaseUserActivitiesToExportedActivities(dbActivities []database.UserActivity) []ExportedActivity {
+	activities := []ExportedActivity{}
+	for _, dbActivity := range dbActivities {
+		activity := ExportedActivity{ID: dbActivity.ID, Name: dbActivity.Name, Points: dbActivity.Points, Type: dbActivity.ActivityType, Scoring: databaseScoringToActivityScoring(dbActivity.ScoringModel, dbActivity.ScoringDailyCap, dbActivity.ScoringThresholdMinutes), Category: dbActivity.Category, Tags: dbActivity.Tags, Icon: dbActivity.Icon, Colour: dbActivity.Colour, CreatedAt: dbActivity.CreatedAt, UpdatedAt: dbActivity.UpdatedAt}
+		if activity.Tags == nil {
+			activity.Tags = []string{}
+		}
+		if dbActivity.ArchivedAt.Valid {
+			activity.ArchivedAt = &dbActivity.ArchivedAt.Time
 		}
+		if dbActivity.DeletedAt.Valid {
+			activity.DeletedAt = &dbActivity.DeletedAt.Time
+		}
+		activities = append(activities, activity)
 	}
-	return User{
-		ID:           dbuser.ID,
-		CreatedAt:    dbuser.CreatedAt,
-		UpdatedAt:    dbuser.UpdatedAt,
-		Username:     dbuser.Username,
-		Email:        dbuser.Email,
-		PasswordHash: nil,
-		GoogleID:     dbuser.GoogleID.String,
+	return activities
+}
+
+func databaseActivityLogsToExportedActivityLogs(dbActivityLogs []database.GetUserActivityLogsForExportRow) []ExportedActivityLog {
+	activityLogs := []ExportedActivityLog{}
+	for _, dbActivityLog := range dbActivityLogs {
+		activityLog := ExportedActivityLog{ID: dbActivityLog.ID, Duration: dbActivityLog.Duration, Points: dbActivityLog.Points, ActivityDescription: dbActivityLog.ActivityDescription, LoggedAt: dbActivityLog.LoggedAt}
+		if dbActivityLog.ActivityID.Valid {
+			activityLog.ActivityID = &dbActivityLog.ActivityID.UUID
+		}
+		if dbActivityLog.Name.Valid {
+			activityLog.ActivityName = &dbActivityLog.Name.String
+		}
+		activityLogs = append(activityLogs, activityLog)
+	}
+	return activityLogs
+}
+
+func databaseUserGoalsToExportedGoals(dbGoals []database.UserGoal) []ExportedGoal {
+	goals := []ExportedGoal{}
+	for _, dbGoal := range dbGoals {
+		goals = append(goals, ExportedGoal{GoalDate: dbGoal.GoalDate, GoalPoints: dbGoal.GoalPoints, Status: dbGoal.Status, CreatedAt: dbGoal.CreatedAt})
+	}
+	return goals
+}
+
+func databaseTeamMembershipsToExportedTeamMemberships(dbMemberships []database.GetUserTeamMembershipsRow) []ExportedTeamMembership {
+	memberships := []ExportedTeamMembership{}
+	for _, dbMembership := range dbMemberships {
+		memberships = append(memberships, ExportedTeamMembership{TeamID: dbMembership.TeamID, TeamName: dbMembership.Name, Roles: dbMembership.Roles, JoinedAt: dbMembership.JoinedAt})
 	}
+	return memberships
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"log"
+	"net/http"
+)
+
+const notificationsPageSize = 20
+
+// notify stores the event in the user's notification inbox and pushes it to
+// their open websocket connections. Failures are logged, never returned, so a
+// notification problem does not fail the request that triggered it.
+func (apiCfg *apiConfig) notify(ctx context.Context, userID uuid.UUID, eventType string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Error encoding %s notification: %s", eventType, err)
+		return
+	}
+	notification, err := apiCfg.DB.CreateNotification(ctx, database.CreateNotificationParams{
+		ID:      uuid.New(),
+		UserID:  userID,
+		Type:    eventType,
+		Payload: payload,
+	})
+	if err != nil {
+		log.Printf("Error creating %s notification: %s", eventType, err)
+		return
+	}
+	hub.Publish(userID, databaseNotificationToEvent(notification))
+}
+
+func (apiCfg *apiConfig) GetNotifications(w http.ResponseWriter, r *http.Request, user database.User) {
+	page, err := parseQueryInt32(r, "page")
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing page: %s", err))
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	notifications, err := apiCfg.DB.GetNotifications(r.Context(), database.GetNotificationsParams{
+		UserID:     user.ID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		RowLimit:   notificationsPageSize,
+		RowOffset:  (page - 1) * notificationsPageSize,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting notifications: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseNotificationsToNotifications(notifications))
+}
+
+func (apiCfg *apiConfig) GetUnreadNotificationsCount(w http.ResponseWriter, r *http.Request, user database.User) {
+	unreadCount, err := apiCfg.DB.GetUnreadNotificationsCount(r.Context(), database.GetUnreadNotificationsCountParams{
+		UserID: user.ID,
+		Type:   r.URL.Query().Get("type"),
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting unread notifications count: %s", err))
+		return
+	}
+	respondWithJson(w, 200, unreadCount)
+}
+
+func (apiCfg *apiConfig) MarkNotificationRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	notificationID, err := uuid.Parse(r.PathValue("notificationid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing notification uuid: %s", err))
+		return
+	}
+	marked, err := apiCfg.DB.MarkNotificationRead(r.Context(), database.MarkNotificationReadParams{
+		ID:     notificationID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error marking notification as read: %s", err))
+		return
+	}
+	if marked == 0 {
+		respondWithError(w, 404, "Notification not found")
+		return
+	}
+	respondWithJson(w, 200, "Notification marked as read")
+}
+
+func (apiCfg *apiConfig) MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request, user database.User) {
+	err := apiCfg.DB.MarkAllNotificationsRead(r.Context(), database.MarkAllNotificationsReadParams{
+		UserID: user.ID,
+		Type:   r.URL.Query().Get("type"),
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error marking notifications as read: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Notifications marked as read")
+}
+
+// queueUndeliveredNotifications sends a new connection up to wsBacklogSize of
+// the notifications the user missed while offline, the rest is left for the
+// next connection. The client must already be registered, so a notification
+// stored meanwhile may arrive twice, clients tell them apart by event id.
+func (apiCfg *apiConfig) queueUndeliveredNotifications(ctx context.Context, client *wsClient) {
+	notifications, err := apiCfg.DB.GetUndeliveredNotifications(ctx, database.GetUndeliveredNotificationsParams{
+		UserID: client.userID,
+		Limit:  wsBacklogSize,
+	})
+	if err != nil {
+		log.Printf("Error getting undelivered notifications: %s", err)
+		return
+	}
+	delivered := []uuid.UUID{}
+	for _, notification := range notifications {
+		message, err := json.Marshal(databaseNotificationToEvent(notification))
+		if err != nil {
+			log.Printf("Error encoding notification %s: %s", notification.ID, err)
+			continue
+		}
+		if !hub.queue(client, message) {
+			break
+		}
+		delivered = append(delivered, notification.ID)
+	}
+	apiCfg.markNotificationsDelivered(delivered...)
+}
+
+func (apiCfg *apiConfig) markNotificationsDelivered(ids ...uuid.UUID) {
+	if len(ids) == 0 {
+		return
+	}
+	err := apiCfg.DB.MarkNotificationsDelivered(context.Background(), ids)
+	if err != nil {
+		log.Printf("Error marking notifications as delivered: %s", err)
+	}
+}
+
+// loadNotificationEvent encodes a stored notification as the event sent over
+// the websocket.
+func (apiCfg *apiConfig) loadNotificationEvent(ctx context.Context, notificationID uuid.UUID) ([]byte, error) {
+	notification, err := apiCfg.DB.GetNotification(ctx, notificationID)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(databaseNotificationToEvent(notification))
+}
+
+func databaseNotificationToEvent(notification database.Notification) Event {
+	return Event{ID: notification.ID, Type: notification.Type, Data: notification.Payload, SentAt: notification.CreatedAt.UTC()}
+}
@@ -0,0 +1,534 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"golang.org/x/oauth2"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// How long a login started with POST /auth/{provider}/authorize can be
+	// completed.
+	oidcStateTTL = 10 * time.Minute
+	// Unknown key ids trigger a JWKS refresh at most this often.
+	oidcJWKSRefreshInterval = time.Minute
+	oidcHTTPTimeout         = 10 * time.Second
+)
+
+var oidcHTTPClient = &http.Client{Timeout: oidcHTTPTimeout}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JwksURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider is an OpenID Connect identity provider. Its discovery document
+// and signing keys are fetched on first use and cached.
+type oidcProvider struct {
+	Name        string
+	DisplayName string
+	Issuer      string
+	config      oauth2.Config
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]any
+	keysFetchedAt time.Time
+}
+
+type oidcIDTokenClaims struct {
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	jwt.RegisteredClaims
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// loadOidcProviders reads the providers listed in OIDC_PROVIDERS, "google" by
+// default. A provider NAME is configured with OIDC_NAME_ISSUER,
+// OIDC_NAME_CLIENT_ID and OIDC_NAME_CLIENT_SECRET, and optionally
+// OIDC_NAME_DISPLAY_NAME, OIDC_NAME_SCOPES and OIDC_NAME_REDIRECT_URL. Google
+// still accepts the OAUTH_GOOGLE_* variables.
+func loadOidcProviders() (map[string]*oidcProvider, error) {
+	names := os.Getenv("OIDC_PROVIDERS")
+	if names == "" {
+		names = "google"
+	}
+	providers := map[string]*oidcProvider{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == IdentityPassword {
+			return nil, fmt.Errorf("%s cannot be used as an identity provider name", name)
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		issuer := os.Getenv(prefix + "ISSUER")
+		clientID := os.Getenv(prefix + "CLIENT_ID")
+		clientSecret := os.Getenv(prefix + "CLIENT_SECRET")
+		displayName := os.Getenv(prefix + "DISPLAY_NAME")
+		redirectURL := os.Getenv(prefix + "REDIRECT_URL")
+		scopes := strings.Fields(os.Getenv(prefix + "SCOPES"))
+		if name == "google" {
+			if issuer == "" {
+				issuer = "https://accounts.google.com"
+			}
+			if clientID == "" {
+				clientID = os.Getenv("OAUTH_GOOGLE_CLIENT_ID")
+			}
+			if clientSecret == "" {
+				clientSecret = os.Getenv("OAUTH_GOOGLE_CLIENT_SECRET")
+			}
+			if displayName == "" {
+				displayName = "Google"
+			}
+			if redirectURL == "" {
+				redirectURL = frontendURL + "/google/auth/callback"
+			}
+		}
+		if issuer == "" || clientID == "" {
+			return nil, fmt.Errorf("%sISSUER and %sCLIENT_ID environment variables are not set", prefix, prefix)
+		}
+		if displayName == "" {
+			displayName = name
+		}
+		if redirectURL == "" {
+			redirectURL = fmt.Sprintf("%s/auth/%s/callback", frontendURL, name)
+		}
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		} else if !slices.Contains(scopes, "openid") {
+			scopes = append([]string{"openid"}, scopes...)
+		}
+		providers[name] = &oidcProvider{
+			Name:        name,
+			DisplayName: displayName,
+			Issuer:      issuer,
+			config: oauth2.Config{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+				Scopes:       scopes,
+			},
+		}
+	}
+	return providers, nil
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	discovery := &oidcDiscovery{}
+	err := oidcGetJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", discovery)
+	if err != nil {
+		return nil, fmt.Errorf("Error discovering %s: %s", p.Name, err)
+	}
+	if discovery.Issuer != p.Issuer {
+		return nil, fmt.Errorf("Issuer of %s is %q , expected %q", p.Name, discovery.Issuer, p.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JwksURI == "" {
+		return nil, fmt.Errorf("Discovery document of %s is incomplete", p.Name)
+	}
+	p.discovery = discovery
+	return discovery, nil
+}
+
+// oauth2Config returns the client configuration with the endpoints from the
+// discovery document.
+func (p *oidcProvider) oauth2Config(ctx context.Context) (*oauth2.Config, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	config := p.config
+	config.Endpoint = oauth2.Endpoint{
+		AuthURL:  discovery.AuthorizationEndpoint,
+		TokenURL: discovery.TokenEndpoint,
+	}
+	return &config, nil
+}
+
+// signingKey returns the provider's public key with the given id, refreshing
+// the JWKS when the key is unknown, for example after a key rotation.
+func (p *oidcProvider) signingKey(ctx context.Context, kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if p.discovery == nil {
+		return nil, errors.New("Provider has not been discovered")
+	}
+	if time.Since(p.keysFetchedAt) < oidcJWKSRefreshInterval {
+		return nil, fmt.Errorf("Unknown signing key %q", kid)
+	}
+	p.keysFetchedAt = time.Now()
+	keys, err := fetchJWKS(ctx, p.discovery.JwksURI)
+	if err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("Unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token.
+func (p *oidcProvider) verifyIDToken(ctx context.Context, rawIDToken string, nonce string) (*oidcIDTokenClaims, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims := &oidcIDTokenClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, errors.New("Nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("Missing subject")
+	}
+	return claims, nil
+}
+
+func fetchJWKS(ctx context.Context, jwksURI string) (map[string]any, error) {
+	jwks := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	err := oidcGetJSON(ctx, jwksURI, &jwks)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching signing keys: %s", err)
+	}
+	keys := map[string]any{}
+	for _, jwk := range jwks.Keys {
+		if jwk.Use == "enc" {
+			continue
+		}
+		key, err := parseJSONWebKey(jwk)
+		if err != nil {
+			log.Printf("Skipping signing key %q: %s", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func parseJSONWebKey(jwk jsonWebKey) (any, error) {
+	decode := func(value string) (*big.Int, error) {
+		bytes, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(bytes), nil
+	}
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", jwk.Kty)
+}
+
+func oidcGetJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := oidcHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (apiCfg *apiConfig) GetAuthProviders(w http.ResponseWriter, r *http.Request) {
+	names := []string{}
+	for name := range oidcProviders {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	providers := []AuthProvider{}
+	for _, name := range names {
+		providers = append(providers, AuthProvider{Name: name, DisplayName: oidcProviders[name].DisplayName})
+	}
+	respondWithJson(w, 200, providers)
+}
+
+func (apiCfg *apiConfig) StartOidcLogin(w http.ResponseWriter, r *http.Request) {
+	apiCfg.startOidcFlow(w, r, uuid.NullUUID{})
+}
+
+// startOidcFlow stores a new state with its PKCE verifier and nonce and
+// returns the provider's authorization URL. userID is set when the flow links
+// a new identity to a signed in user.
+func (apiCfg *apiConfig) startOidcFlow(w http.ResponseWriter, r *http.Request, userID uuid.NullUUID) {
+	provider, ok := oidcProviders[r.PathValue("provider")]
+	if !ok {
+		respondWithError(w, 404, "Unknown identity provider")
+		return
+	}
+	config, err := provider.oauth2Config(r.Context())
+	if err != nil {
+		respondWithError(w, 502, fmt.Sprintf("Error contacting identity provider: %s", err))
+		return
+	}
+	state, err := generateResetToken()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error generating state: %s", err))
+		return
+	}
+	nonce, err := generateResetToken()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error generating nonce: %s", err))
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+	err = apiCfg.DB.DeleteExpiredOidcLoginStates(r.Context())
+	if err != nil {
+		log.Printf("Error deleting expired login states: %s", err)
+	}
+	err = apiCfg.DB.CreateOidcLoginState(r.Context(), database.CreateOidcLoginStateParams{
+		StateHash:    hashToken(state),
+		Provider:     provider.Name,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       userID,
+		ExpiresAt:    time.Now().Add(oidcStateTTL),
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error creating login state: %s", err))
+		return
+	}
+	respondWithJson(w, 200, struct {
+		AuthorizationURL string `json:"authorization_url"`
+		State            string `json:"state"`
+	}{
+		AuthorizationURL: config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce)),
+		State:            state,
+	})
+}
+
+// finishOidcFlow consumes the state of the callback, exchanges the code with
+// the PKCE verifier and returns the verified ID token claims.
+func (apiCfg *apiConfig) finishOidcFlow(w http.ResponseWriter, r *http.Request, userID uuid.NullUUID) (*oidcProvider, *oidcIDTokenClaims, bool) {
+	type parameters struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt decode request body: %s", err))
+		return nil, nil, false
+	}
+	provider, ok := oidcProviders[r.PathValue("provider")]
+	if !ok {
+		respondWithError(w, 404, "Unknown identity provider")
+		return nil, nil, false
+	}
+	loginState, err := apiCfg.DB.ConsumeOidcLoginState(r.Context(), hashToken(params.State))
+	if err == sql.ErrNoRows {
+		respondWithError(w, 400, "Invalid or expired login state")
+		return nil, nil, false
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting login state: %s", err))
+		return nil, nil, false
+	}
+	if loginState.Provider != provider.Name || loginState.ExpiresAt.Before(time.Now()) || loginState.UserID != userID {
+		respondWithError(w, 400, "Invalid or expired login state")
+		return nil, nil, false
+	}
+	config, err := provider.oauth2Config(r.Context())
+	if err != nil {
+		respondWithError(w, 502, fmt.Sprintf("Error contacting identity provider: %s", err))
+		return nil, nil, false
+	}
+	ctx := context.WithValue(r.Context(), oauth2.HTTPClient, oidcHTTPClient)
+	token, err := config.Exchange(ctx, params.Code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		respondWithError(w, 400, "Couldnt exchange code for token")
+		return nil, nil, false
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		respondWithError(w, 502, "Identity provider did not return an ID token")
+		return nil, nil, false
+	}
+	claims, err := provider.verifyIDToken(r.Context(), rawIDToken, loginState.Nonce)
+	if err != nil {
+		respondWithError(w, 401, fmt.Sprintf("Invalid ID token: %s", err))
+		return nil, nil, false
+	}
+	return provider, claims, true
+}
+
+// OidcLoginCallback signs in the user the identity belongs to. Unknown
+// identities create a new account, unless their email belongs to an existing
+// one, which has to confirm the link first.
+func (apiCfg *apiConfig) OidcLoginCallback(w http.ResponseWriter, r *http.Request) {
+	provider, claims, ok := apiCfg.finishOidcFlow(w, r, uuid.NullUUID{})
+	if !ok {
+		return
+	}
+	existingUser, err := apiCfg.DB.GetUserByIdentity(r.Context(), database.GetUserByIdentityParams{
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+	})
+	if err == nil {
+		tokens, err := apiCfg.createSession(r, existingUser)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error in generating JWT token for user: %s", err))
+			return
+		}
+		respondWithJson(w, 200, tokens)
+		return
+	} else if err != sql.ErrNoRows {
+		respondWithError(w, 500, fmt.Sprintf("DB Error: Couldnt get user by identity: %s", err))
+		return
+	}
+	if claims.Email == "" {
+		respondWithError(w, 400, "Identity provider did not share an email address")
+		return
+	}
+	emailUser, err := apiCfg.DB.GetUserByEmail(r.Context(), claims.Email)
+	if err == nil {
+		respondWithIdentityLinkRequired(w, emailUser, provider.Name, claims)
+		return
+	} else if err != sql.ErrNoRows {
+		respondWithError(w, 500, fmt.Sprintf("DB Error: Couldnt get user by email: %s", err))
+		return
+	}
+	userName := claims.Name
+	if userName == "" {
+		userName = claims.PreferredUsername
+	}
+	if userName == "" {
+		userName, _, _ = strings.Cut(claims.Email, "@")
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	user, err := qtx.CreateUser(r.Context(), database.CreateUserParams{
+		ID:            uuid.New(),
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+		Username:      userName,
+		Email:         claims.Email,
+		PasswordHash:  sql.NullString{String: "", Valid: false},
+		EmailVerified: claims.EmailVerified,
+	})
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt create %s user: %s", provider.Name, err))
+		return
+	}
+	_, err = qtx.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		ID:       uuid.New(),
+		UserID:   user.ID,
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Couldnt create identity: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	tokens, err := apiCfg.createSession(r, user)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in generating JWT token for user: %s", err))
+		return
+	}
+	respondWithJson(w, 200, tokens)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testOidcClientID = "test-client"
+	testOidcKeyID    = "test-key"
+)
+
+// mockOidcServer is a minimal OpenID Connect provider. Its token endpoint
+// checks the PKCE verifier against the challenge of the authorization request
+// and returns the ID token set in idToken.
+type mockOidcServer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu        sync.Mutex
+	challenge string
+	idToken   string
+}
+
+func newMockOidcServer(t *testing.T) *mockOidcServer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOidcServer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                m.URL,
+			AuthorizationEndpoint: m.URL + "/authorize",
+			TokenEndpoint:         m.URL + "/token",
+			JwksURI:               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(struct {
+			Keys []jsonWebKey `json:"keys"`
+		}{Keys: []jsonWebKey{{
+			Kty: "RSA",
+			Kid: testOidcKeyID,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		verifierHash := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if r.FormValue("code") != "test-code" || base64.RawURLEncoding.EncodeToString(verifierHash[:]) != m.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     m.idToken,
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockOidcServer) provider() *oidcProvider {
+	return &oidcProvider{
+		Name:   "mock",
+		Issuer: m.URL,
+		config: oauth2.Config{ClientID: testOidcClientID, ClientSecret: "test-secret", RedirectURL: "http://localhost/callback", Scopes: []string{"openid", "email"}},
+	}
+}
+
+// claims returns valid ID token claims for the nonce, tests change them to
+// make the token invalid.
+func (m *mockOidcServer) claims(nonce string) oidcIDTokenClaims {
+	return oidcIDTokenClaims{
+		Nonce:         nonce,
+		Email:         "user@example.com",
+		EmailVerified: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.URL,
+			Subject:   "user-123",
+			Audience:  jwt.ClaimStrings{testOidcClientID},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+func (m *mockOidcServer) sign(t *testing.T, claims oidcIDTokenClaims, key *rsa.PrivateKey) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testOidcKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestOidcLoginFlow(t *testing.T) {
+	m := newMockOidcServer(t)
+	provider := m.provider()
+	ctx := context.Background()
+
+	config, err := provider.oauth2Config(ctx)
+	if err != nil {
+		t.Fatalf("oauth2Config() error = %s", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+	authURL, err := url.Parse(config.AuthCodeURL("test-state", oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", "test-nonce")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authURL.Query().Get("code_challenge_method") != "S256" || authURL.Query().Get("nonce") != "test-nonce" {
+		t.Fatalf("authorization url %s lacks the PKCE challenge or nonce", authURL)
+	}
+	m.mu.Lock()
+	m.challenge = authURL.Query().Get("code_challenge")
+	m.idToken = m.sign(t, m.claims("test-nonce"), m.key)
+	m.mu.Unlock()
+
+	exchangeCtx := context.WithValue(ctx, oauth2.HTTPClient, oidcHTTPClient)
+	if _, err := config.Exchange(exchangeCtx, "test-code", oauth2.VerifierOption("wrong-verifier")); err == nil {
+		t.Error("code exchanged with the wrong PKCE verifier")
+	}
+	token, err := config.Exchange(exchangeCtx, "test-code", oauth2.VerifierOption(verifier))
+	if err != nil {
+		t.Fatalf("Exchange() error = %s", err)
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	claims, err := provider.verifyIDToken(ctx, rawIDToken, "test-nonce")
+	if err != nil {
+		t.Fatalf("verifyIDToken() error = %s", err)
+	}
+	if claims.Subject != "user-123" || claims.Email != "user@example.com" || !claims.EmailVerified {
+		t.Errorf("verifyIDToken() = %+v", claims)
+	}
+}
+
+func TestVerifyIDTokenRejectsInvalidTokens(t *testing.T) {
+	m := newMockOidcServer(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		modify func(claims *oidcIDTokenClaims)
+		key    *rsa.PrivateKey
+		nonce  string
+	}{
+		{name: "wrong issuer", modify: func(claims *oidcIDTokenClaims) { claims.Issuer = "https://evil.example.com" }},
+		{name: "wrong audience", modify: func(claims *oidcIDTokenClaims) { claims.Audience = jwt.ClaimStrings{"other-client"} }},
+		{name: "bad nonce", nonce: "other-nonce"},
+		{name: "missing nonce", modify: func(claims *oidcIDTokenClaims) { claims.Nonce = "" }},
+		{name: "expired", modify: func(claims *oidcIDTokenClaims) { claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) }},
+		{name: "no expiry", modify: func(claims *oidcIDTokenClaims) { claims.ExpiresAt = nil }},
+		{name: "missing subject", modify: func(claims *oidcIDTokenClaims) { claims.Subject = "" }},
+		{name: "signed with another key", key: otherKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := m.claims("test-nonce")
+			if tt.modify != nil {
+				tt.modify(&claims)
+			}
+			key := m.key
+			if tt.key != nil {
+				key = tt.key
+			}
+			nonce := "test-nonce"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			if _, err := m.provider().verifyIDToken(context.Background(), m.sign(t, claims, key), nonce); err == nil {
+				t.Error("verifyIDToken() accepted the token")
+			}
+		})
+	}
+}
+
+func TestOidcDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	m := newMockOidcServer(t)
+	provider := m.provider()
+	provider.Issuer = m.URL + "/"
+	if _, err := provider.discover(context.Background()); err == nil {
+		t.Error("discover() accepted a document for another issuer")
+	}
+}
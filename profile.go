@@ -0,0 +1,258 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	_ "time/tzdata"
+	"unicode/utf8"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxAvatarURLLength   = 2048
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,30}$`)
+	localePattern   = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+)
+
+// UpdateUser changes the profile fields present in the body, fields left out
+// keep their value.
+func (apiCfg *apiConfig) UpdateUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Username    *string `json:"username"`
+		DisplayName *string `json:"display_name"`
+		AvatarURL   *string `json:"avatar_url"`
+		Timezone    *string `json:"timezone"`
+		Locale      *string `json:"locale"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
+		return
+	}
+	profile := database.UpdateUserProfileParams{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		AvatarUrl:   user.AvatarUrl,
+		Timezone:    user.Timezone,
+		Locale:      user.Locale,
+	}
+	if params.Username != nil {
+		if !usernamePattern.MatchString(*params.Username) {
+			respondWithError(w, 400, "Username must be 3 to 30 letters , digits , dots , dashes or underscores")
+			return
+		}
+		profile.Username = *params.Username
+	}
+	if params.DisplayName != nil {
+		displayName := strings.TrimSpace(*params.DisplayName)
+		if utf8.RuneCountInString(displayName) > maxDisplayNameLength {
+			respondWithError(w, 400, fmt.Sprintf("Display name must be at most %d characters long", maxDisplayNameLength))
+			return
+		}
+		profile.DisplayName = displayName
+	}
+	if params.AvatarURL != nil {
+		if *params.AvatarURL != "" {
+			avatarURL, err := url.Parse(*params.AvatarURL)
+			if err != nil || avatarURL.Scheme != "https" && avatarURL.Scheme != "http" || avatarURL.Host == "" || len(*params.AvatarURL) > maxAvatarURLLength {
+				respondWithError(w, 400, "Avatar URL must be a valid http or https URL")
+				return
+			}
+		}
+		profile.AvatarUrl = *params.AvatarURL
+	}
+	if params.Timezone != nil {
+		_, err := time.LoadLocation(*params.Timezone)
+		if err != nil || *params.Timezone == "" || *params.Timezone == "Local" {
+			respondWithError(w, 400, fmt.Sprintf("Unknown timezone: %s", *params.Timezone))
+			return
+		}
+		profile.Timezone = *params.Timezone
+	}
+	if params.Locale != nil {
+		if !localePattern.MatchString(*params.Locale) {
+			respondWithError(w, 400, fmt.Sprintf("Invalid locale: %s", *params.Locale))
+			return
+		}
+		profile.Locale = *params.Locale
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	updatedUser, err := qtx.UpdateUserProfile(r.Context(), profile)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && pqErr.Constraint == "unique_username" {
+		respondWithError(w, 409, "Username already exists")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error updating profile: %s", err))
+		return
+	}
+	// Suggestions show the username they were made with.
+	if updatedUser.Username != user.Username {
+		err = qtx.RenameUserSuggestFeatures(r.Context(), database.RenameUserSuggestFeaturesParams{
+			UserID:   uuid.NullUUID{UUID: user.ID, Valid: true},
+			Username: updatedUser.Username,
+		})
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error updating suggestions: %s", err))
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseUserToUser(updatedUser))
+}
+
+// ChangeEmail sends a verification link to the new address. The email of the
+// account only changes once that link is opened.
+func (apiCfg *apiConfig) ChangeEmail(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
+		return
+	}
+	if user.PasswordHash.Valid {
+		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(params.Password))
+		if err != nil {
+			respondWithError(w, 401, "Invalid password")
+			return
+		}
+	}
+	address, err := mail.ParseAddress(params.Email)
+	if err != nil || address.Address != params.Email {
+		respondWithError(w, 400, "Invalid email address")
+		return
+	}
+	if strings.EqualFold(params.Email, user.Email) {
+		respondWithError(w, 400, "This is already your email address")
+		return
+	}
+	_, err = apiCfg.DB.GetUserByEmail(r.Context(), params.Email)
+	if err == nil {
+		respondWithError(w, 409, "This email is already used by another account")
+		return
+	} else if err != sql.ErrNoRows {
+		respondWithError(w, 500, fmt.Sprintf("Error getting user: %s", err))
+		return
+	}
+	if !apiCfg.checkEmailVerificationLimits(w, r, user) {
+		return
+	}
+	err = apiCfg.sendEmailChangeVerification(r.Context(), user, params.Email)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error sending verification email: %s", err))
+		return
+	}
+	err = sendEmailChangeNotice(user.Email, params.Email)
+	if err != nil {
+		log.Printf("Error sending email change notice to user %s: %s", user.ID, err)
+	}
+	respondWithJson(w, 200, "A verification link has been sent to your new email address")
+}
+
+// ChangePassword replaces the password after checking the current one. Every
+// other session is signed out, the caller gets a fresh session.
+func (apiCfg *apiConfig) ChangePassword(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
+		return
+	}
+	if !user.PasswordHash.Valid {
+		respondWithError(w, 409, "No password is set , add one from your login methods instead")
+		return
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(params.CurrentPassword))
+	if err != nil {
+		respondWithError(w, 401, "Invalid password")
+		return
+	}
+	err = validatePassword(params.NewPassword)
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		respondWithError(w, 500, "Couldnt hash password")
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	err = qtx.SetNewPassword(r.Context(), database.SetNewPasswordParams{
+		PasswordHash: sql.NullString{String: string(hashedPassword), Valid: true},
+		ID:           user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error setting password: %s", err))
+		return
+	}
+	err = qtx.DeleteUserPasswordResets(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error deleting password resets: %s", err))
+		return
+	}
+	err = qtx.RevokeUserSessions(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in revoking sessions: %s", err))
+		return
+	}
+	err = qtx.SetUserTokensValidAfter(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in revoking sessions: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	tokens, err := apiCfg.createSession(r, user)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in generating JWT token for user: %s", err))
+		return
+	}
+	respondWithJson(w, 200, tokens)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Failed logins an account tolerates before it is locked.
+	loginLockoutThreshold = 5
+	// The lockout doubles with every failure past the threshold.
+	loginLockoutBase = 30 * time.Second
+	loginLockoutMax  = time.Hour
+)
+
+// llmDailyQuota is how many inputs a user can have parsed by OpenAI per UTC
+// day, overridden with LLM_DAILY_QUOTA.
+var llmDailyQuota int32 = 50
+
+// rateLimiter hands out a token bucket per key. Buckets hold up to requests
+// tokens and refill at requests per period.
+type rateLimiter struct {
+	requests  float64
+	period    time.Duration
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+func newRateLimiter(requests int, period time.Duration) *rateLimiter {
+	return &rateLimiter{
+		requests:  float64(requests),
+		period:    period,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// allow takes a token from the key's bucket. When the bucket is empty it
+// returns how long until the next token.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	rate := l.requests / l.period.Seconds()
+	// A bucket that was left alone for a whole period is full again, so it
+	// can be dropped.
+	if now.Sub(l.lastSweep) > l.period {
+		for k, bucket := range l.buckets {
+			if now.Sub(bucket.updatedAt) > l.period {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.requests, updatedAt: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(l.requests, bucket.tokens+now.Sub(bucket.updatedAt).Seconds()*rate)
+	bucket.updatedAt = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// byIP limits a public route per client address.
+func (l *rateLimiter) byIP(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := l.allow(clientIP(r))
+		if !allowed {
+			respondWithTooManyRequests(w, retryAfter, "Too many requests , please try again later")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// byUser limits an authenticated route per user, so it goes inside
+// middlewareAuth.
+func (l *rateLimiter) byUser(handler authHandler) authHandler {
+	return func(w http.ResponseWriter, r *http.Request, user database.User) {
+		allowed, retryAfter := l.allow(user.ID.String())
+		if !allowed {
+			respondWithTooManyRequests(w, retryAfter, "Too many requests , please try again later")
+			return
+		}
+		handler(w, r, user)
+	}
+}
+
+// clientIP is the address the request came from. X-Forwarded-For is only
+// trusted when TRUST_PROXY is set, as clients can send it themselves; the
+// last entry is the one added by our proxy.
+func clientIP(r *http.Request) string {
+	if os.Getenv("TRUST_PROXY") == "true" {
+		forwardedFor := r.Header.Get("X-Forwarded-For")
+		if forwardedFor != "" {
+			addresses := strings.Split(forwardedFor, ",")
+			return strings.TrimSpace(addresses[len(addresses)-1])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func respondWithTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	respondWithError(w, 429, msg)
+}
+
+// checkLoginLockout answers with a 429 and returns false while the account is
+// locked after too many failed logins.
+func (apiCfg *apiConfig) checkLoginLockout(w http.ResponseWriter, r *http.Request, user database.User) bool {
+	failure, err := apiCfg.DB.GetLoginFailure(r.Context(), user.ID)
+	if err == sql.ErrNoRows {
+		return true
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting failed logins: %s", err))
+		return false
+	}
+	if failure.LockedUntil.Valid && failure.LockedUntil.Time.After(time.Now()) {
+		respondWithTooManyRequests(w, time.Until(failure.LockedUntil.Time), "Too many failed login attempts , please try again later")
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a wrong password or second factor, locking the
+// account once the threshold is reached.
+func (apiCfg *apiConfig) recordLoginFailure(r *http.Request, user database.User) error {
+	failure, err := apiCfg.DB.RecordLoginFailure(r.Context(), user.ID)
+	if err != nil {
+		return err
+	}
+	if failure.FailedCount < loginLockoutThreshold {
+		return nil
+	}
+	lockout := loginLockoutMax
+	if exponent := failure.FailedCount - loginLockoutThreshold; exponent < 16 {
+		lockout = min(loginLockoutBase<<exponent, loginLockoutMax)
+	}
+	return apiCfg.DB.SetLoginLockedUntil(r.Context(), database.SetLoginLockedUntilParams{
+		UserID:      user.ID,
+		LockedUntil: sql.NullTime{Time: time.Now().Add(lockout), Valid: true},
+	})
+}
+
+// consumeLlmQuota counts an OpenAI backed request against the user's daily
+// quota, answering with a 429 and returning false once it is used up.
+func (apiCfg *apiConfig) consumeLlmQuota(w http.ResponseWriter, r *http.Request, user database.User) bool {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	_, err := apiCfg.DB.ConsumeLlmQuota(r.Context(), database.ConsumeLlmQuotaParams{
+		UserID:     user.ID,
+		Day:        today,
+		DailyQuota: llmDailyQuota,
+	})
+	if err == sql.ErrNoRows {
+		respondWithTooManyRequests(w, today.AddDate(0, 0, 1).Sub(now), fmt.Sprintf("You have reached the daily limit of %d activity inputs", llmDailyQuota))
+		return false
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error checking daily quota: %s", err))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"math"
+)
+
+// Scoring models an activity can use, points is always the activity's points.
+const (
+	// points per hour logged.
+	ScoringPerHour = "per_hour"
+	// points for every log no matter how long.
+	ScoringPerSession = "per_session"
+	// points per hour until the day's points for the activity reach the cap.
+	ScoringPerHourCapped = "per_hour_capped"
+	// points per hour up to the threshold minutes of the day, then at
+	// diminishingReturnsRate.
+	ScoringDiminishing = "diminishing"
+)
+
+const diminishingReturnsRate = 0.5
+
+// normalize defaults an empty model to per hour and checks that the model has
+// the settings it needs. Settings another model uses are dropped.
+func (s *ActivityScoring) normalize() error {
+	switch s.Model {
+	case "", ScoringPerHour, ScoringPerSession:
+		if s.Model == "" {
+			s.Model = ScoringPerHour
+		}
+		s.DailyCap = nil
+		s.ThresholdMinutes = nil
+	case ScoringPerHourCapped:
+		if s.DailyCap == nil || *s.DailyCap <= 0 {
+			return errors.New("Scoring model per_hour_capped needs a daily_cap greater than 0")
+		}
+		s.ThresholdMinutes = nil
+	case ScoringDiminishing:
+		if s.ThresholdMinutes == nil || *s.ThresholdMinutes <= 0 {
+			return errors.New("Scoring model diminishing needs a threshold_minutes greater than 0")
+		}
+		s.DailyCap = nil
+	default:
+		return fmt.Errorf("Unknown scoring model: %s", s.Model)
+	}
+	return nil
+}
+
+func (s ActivityScoring) dailyCap() sql.NullInt32 {
+	if s.DailyCap == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: *s.DailyCap, Valid: true}
+}
+
+func (s ActivityScoring) thresholdMinutes() sql.NullInt32 {
+	if s.ThresholdMinutes == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: *s.ThresholdMinutes, Valid: true}
+}
+
+// calculatePoints scores a log of duration minutes for an activity worth
+// points. minutesToday and pointsToday are what was already logged for the
+// activity today, the capped and diminishing models depend on them.
+func calculatePoints(points int32, scoring ActivityScoring, duration int32, minutesToday int32, pointsToday int32) int32 {
+	perHour := func(minutes float64) float64 {
+		return minutes * float64(points) / 60
+	}
+	switch scoring.Model {
+	case ScoringPerSession:
+		return points
+	case ScoringPerHourCapped:
+		earned := int32(math.Round(perHour(float64(duration))))
+		if scoring.DailyCap == nil {
+			return earned
+		}
+		// The cap limits how far the day's total can move either way, so it
+		// works for activities with negative points too.
+		dailyCap := *scoring.DailyCap
+		if earned >= 0 {
+			return max(0, min(earned, dailyCap-pointsToday))
+		}
+		return min(0, max(earned, -dailyCap-pointsToday))
+	case ScoringDiminishing:
+		if scoring.ThresholdMinutes == nil {
+			return int32(math.Round(perHour(float64(duration))))
+		}
+		fullRateMinutes := min(duration, max(0, *scoring.ThresholdMinutes-minutesToday))
+		reducedMinutes := duration - fullRateMinutes
+		return int32(math.Round(perHour(float64(fullRateMinutes)) + perHour(float64(reducedMinutes))*diminishingReturnsRate))
+	default:
+		return int32(math.Round(perHour(float64(duration))))
+	}
+}
+
+// scoreActivityLog calculates the points of a new log for one of the user's
+// activities, taking into account what was logged for it today.
+func (apiCfg *apiConfig) scoreActivityLog(ctx context.Context, userID uuid.UUID, activity Activity, duration int32) (int32, error) {
+	usage := database.GetDailyActivityUsageRow{}
+	if activity.Scoring.Model == ScoringPerHourCapped || activity.Scoring.Model == ScoringDiminishing {
+		var err error
+		usage, err = apiCfg.DB.GetDailyActivityUsage(ctx, database.GetDailyActivityUsageParams{
+			UserID:     userID,
+			ActivityID: uuid.NullUUID{UUID: activity.ActivityID, Valid: true},
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return calculatePoints(activity.Points, activity.Scoring, duration, usage.Minutes, usage.Points), nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestCalculatePoints(t *testing.T) {
+	cap30 := int32(30)
+	threshold120 := int32(120)
+	tests := []struct {
+		name         string
+		points       int32
+		scoring      ActivityScoring
+		duration     int32
+		minutesToday int32
+		pointsToday  int32
+		want         int32
+	}{
+		{name: "per hour", points: 10, scoring: ActivityScoring{Model: ScoringPerHour}, duration: 600, want: 100},
+		{name: "per hour rounds half up", points: 10, scoring: ActivityScoring{Model: ScoringPerHour}, duration: 45, want: 8},
+		{name: "per hour rounds down", points: 10, scoring: ActivityScoring{Model: ScoringPerHour}, duration: 40, want: 7},
+		{name: "per hour one minute", points: 10, scoring: ActivityScoring{Model: ScoringPerHour}, duration: 1, want: 0},
+		{name: "per hour one minute at 60 points", points: 60, scoring: ActivityScoring{Model: ScoringPerHour}, duration: 1, want: 1},
+		{name: "per hour negative points", points: -10, scoring: ActivityScoring{Model: ScoringPerHour}, duration: 90, want: -15},
+		{name: "empty model is per hour", points: 10, scoring: ActivityScoring{}, duration: 120, want: 20},
+		{name: "per session", points: 5, scoring: ActivityScoring{Model: ScoringPerSession}, duration: 600, want: 5},
+		{name: "per session ignores the day", points: 5, scoring: ActivityScoring{Model: ScoringPerSession}, duration: 1, minutesToday: 600, pointsToday: 50, want: 5},
+		{name: "capped below cap", points: 10, scoring: ActivityScoring{Model: ScoringPerHourCapped, DailyCap: &cap30}, duration: 60, want: 10},
+		{name: "capped at cap", points: 10, scoring: ActivityScoring{Model: ScoringPerHourCapped, DailyCap: &cap30}, duration: 600, want: 30},
+		{name: "capped partial remainder", points: 10, scoring: ActivityScoring{Model: ScoringPerHourCapped, DailyCap: &cap30}, duration: 120, pointsToday: 25, want: 5},
+		{name: "capped cap already reached", points: 10, scoring: ActivityScoring{Model: ScoringPerHourCapped, DailyCap: &cap30}, duration: 120, pointsToday: 30, want: 0},
+		{name: "capped cap already exceeded", points: 10, scoring: ActivityScoring{Model: ScoringPerHourCapped, DailyCap: &cap30}, duration: 120, pointsToday: 40, want: 0},
+		{name: "capped negative points", points: -10, scoring: ActivityScoring{Model: ScoringPerHourCapped, DailyCap: &cap30}, duration: 60, want: -10},
+		{name: "capped negative points against cap", points: -10, scoring: ActivityScoring{Model: ScoringPerHourCapped, DailyCap: &cap30}, duration: 600, pointsToday: -20, want: -10},
+		{name: "capped negative cap already reached", points: -10, scoring: ActivityScoring{Model: ScoringPerHourCapped, DailyCap: &cap30}, duration: 600, pointsToday: -30, want: 0},
+		{name: "capped without cap is per hour", points: 10, scoring: ActivityScoring{Model: ScoringPerHourCapped}, duration: 600, pointsToday: 40, want: 100},
+		{name: "diminishing below threshold", points: 10, scoring: ActivityScoring{Model: ScoringDiminishing, ThresholdMinutes: &threshold120}, duration: 60, want: 10},
+		{name: "diminishing up to threshold", points: 10, scoring: ActivityScoring{Model: ScoringDiminishing, ThresholdMinutes: &threshold120}, duration: 60, minutesToday: 60, want: 10},
+		{name: "diminishing straddling threshold", points: 10, scoring: ActivityScoring{Model: ScoringDiminishing, ThresholdMinutes: &threshold120}, duration: 60, minutesToday: 90, want: 8},
+		{name: "diminishing long log", points: 10, scoring: ActivityScoring{Model: ScoringDiminishing, ThresholdMinutes: &threshold120}, duration: 600, want: 60},
+		{name: "diminishing past threshold", points: 10, scoring: ActivityScoring{Model: ScoringDiminishing, ThresholdMinutes: &threshold120}, duration: 60, minutesToday: 120, want: 5},
+		{name: "diminishing well past threshold", points: 10, scoring: ActivityScoring{Model: ScoringDiminishing, ThresholdMinutes: &threshold120}, duration: 60, minutesToday: 300, want: 5},
+		{name: "diminishing without threshold is per hour", points: 10, scoring: ActivityScoring{Model: ScoringDiminishing}, duration: 600, minutesToday: 300, want: 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculatePoints(tt.points, tt.scoring, tt.duration, tt.minutesToday, tt.pointsToday)
+			if got != tt.want {
+				t.Errorf("calculatePoints(%d, %+v, %d, %d, %d) = %d, want %d", tt.points, tt.scoring, tt.duration, tt.minutesToday, tt.pointsToday, got, tt.want)
+			}
+		})
+	}
+}
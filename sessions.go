@@ -0,0 +1,179 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"net/http"
+	"time"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// createSession starts a new login session for the user and returns a fresh
+// access token together with the session's first refresh token.
+func (apiCfg *apiConfig) createSession(r *http.Request, user database.User) (jwtTokenResponse, error) {
+	// Logging in during the grace period keeps the account.
+	if user.DeletionScheduledAt.Valid {
+		err := apiCfg.DB.CancelUserDeletion(r.Context(), user.ID)
+		if err != nil {
+			return jwtTokenResponse{}, err
+		}
+	}
+	refreshToken, err := generateResetToken()
+	if err != nil {
+		return jwtTokenResponse{}, err
+	}
+	session, err := apiCfg.DB.CreateSession(r.Context(), database.CreateSessionParams{
+		ID:               uuid.New(),
+		UserID:           user.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        r.UserAgent(),
+		IpAddress:        r.RemoteAddr,
+		ExpiresAt:        time.Now().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return jwtTokenResponse{}, err
+	}
+	return generateTokenResponse(user, session.ID, refreshToken)
+}
+
+func generateTokenResponse(user database.User, sessionID uuid.UUID, refreshToken string) (jwtTokenResponse, error) {
+	var jwtToken string
+	var err error
+	// Accounts without a password can only sign in through an identity provider.
+	if !user.PasswordHash.Valid {
+		jwtToken, err = generateJWTForGoogleUser(user.ID, user.Email, user.Username, sessionID)
+	} else {
+		jwtToken, err = generateJWTForRegularUser(user.ID, user.Email, user.Username, sessionID)
+	}
+	if err != nil {
+		return jwtTokenResponse{}, err
+	}
+	return jwtTokenResponse{Token: jwtToken, RefreshToken: refreshToken, ExpiresAt: time.Now().Add(accessTokenTTL)}, nil
+}
+
+// RefreshSession trades a refresh token for a new token pair. Every refresh
+// token works once: presenting one that was already rotated means it leaked,
+// so the whole session is revoked.
+func (apiCfg *apiConfig) RefreshSession(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
+		return
+	}
+	tokenHash := hashToken(params.RefreshToken)
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in starting transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	session, err := qtx.GetSessionByRefreshTokenHash(r.Context(), tokenHash)
+	if err == sql.ErrNoRows {
+		reusedSession, err := apiCfg.DB.GetSessionByPreviousTokenHash(r.Context(), sql.NullString{String: tokenHash, Valid: true})
+		if err == nil {
+			err = apiCfg.DB.RevokeSession(r.Context(), reusedSession.ID)
+			if err != nil {
+				respondWithError(w, 500, fmt.Sprintf("Error revoking session: %s", err))
+				return
+			}
+			respondWithError(w, 401, "Refresh token was already used , the session has been revoked")
+			return
+		}
+		respondWithError(w, 401, "Invalid refresh token")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting session: %s", err))
+		return
+	}
+	if session.RevokedAt.Valid || session.ExpiresAt.Before(time.Now()) {
+		respondWithError(w, 401, "Session expired , please log in again")
+		return
+	}
+	user, err := qtx.GetUserById(r.Context(), session.UserID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting user: %s", err))
+		return
+	}
+	refreshToken, err := generateResetToken()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error generating refresh token: %s", err))
+		return
+	}
+	err = qtx.RotateSessionRefreshToken(r.Context(), database.RotateSessionRefreshTokenParams{
+		ID:               session.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		ExpiresAt:        time.Now().Add(refreshTokenTTL),
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error rotating refresh token: %s", err))
+		return
+	}
+	tokens, err := generateTokenResponse(user, session.ID, refreshToken)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in generating JWT token for user: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, tokens)
+}
+
+func (apiCfg *apiConfig) LogOutUser(w http.ResponseWriter, r *http.Request, user database.User) {
+	claims, _ := claimsFromContext(r.Context())
+	_, err := apiCfg.DB.RevokeUserSession(r.Context(), database.RevokeUserSessionParams{
+		ID:     claims.SessionID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error revoking session: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Logged out successfully")
+}
+
+func (apiCfg *apiConfig) GetUserSessions(w http.ResponseWriter, r *http.Request, user database.User) {
+	claims, _ := claimsFromContext(r.Context())
+	sessions, err := apiCfg.DB.GetUserSessions(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting sessions: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseSessionsToSessions(sessions, claims.SessionID))
+}
+
+func (apiCfg *apiConfig) RevokeUserSession(w http.ResponseWriter, r *http.Request, user database.User) {
+	sessionID, err := uuid.Parse(r.PathValue("sessionid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing session uuid: %s", err))
+		return
+	}
+	revoked, err := apiCfg.DB.RevokeUserSession(r.Context(), database.RevokeUserSessionParams{
+		ID:     sessionID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error revoking session: %s", err))
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, 404, "Session not found")
+		return
+	}
+	respondWithJson(w, 200, "Session revoked successfully")
+}
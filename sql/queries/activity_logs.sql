@@ -1,21 +1,14 @@
--- name: SetDefaultActivities :exec
-INSERT INTO user_activities (id , user_id , name , points , activity_type ) 
-VALUES
-(uuid_generate_v4(), $1, 'Learning', 10, 'default'),
-(uuid_generate_v4(), $1, 'Exercise', 8, 'default'),
-(uuid_generate_v4(), $1, 'Meditation', 6, 'default'),
-(uuid_generate_v4(), $1, 'Reading', 4, 'default'),
-(uuid_generate_v4(), $1, 'Household Chores', 2, 'default'),
-(uuid_generate_v4(), $1, 'Watching Series', -2, 'default'),
-(uuid_generate_v4(), $1, 'Watching TV', -4, 'default'),
-(uuid_generate_v4(), $1, 'Gaming', -6, 'default'),
-(uuid_generate_v4(), $1, 'Social Media Scrolling', -8, 'default'),
-(uuid_generate_v4(), $1, 'Watching adult websites', -10, 'default');
 -- name: GetActivities :many
-SELECT id , name , points , activity_type FROM user_activities WHERE user_id = $1 ORDER BY points DESC;
+SELECT id , name , points , activity_type , scoring_model , scoring_daily_cap , scoring_threshold_minutes , category , tags , icon , colour , archived_at FROM user_activities
+WHERE user_id = sqlc.arg(user_id)
+  AND deleted_at IS NULL
+  AND (sqlc.arg(category)::text = '' OR category = sqlc.arg(category)::text)
+  AND (sqlc.arg(tag)::text = '' OR sqlc.arg(tag)::text = ANY(tags))
+  AND (CASE sqlc.arg(archived)::text WHEN 'all' THEN TRUE WHEN 'true' THEN archived_at IS NOT NULL ELSE archived_at IS NULL END)
+ORDER BY points DESC;
 
 -- name: SetActivity :one
-INSERT INTO user_activities (id , user_id , name , points , activity_type ) VALUES ($1 , $2 , $3 , $4 , $5 ) RETURNING id , name , points , activity_type;
+INSERT INTO user_activities (id , user_id , name , points , activity_type , scoring_model , scoring_daily_cap , scoring_threshold_minutes , category , tags , icon , colour ) VALUES ($1 , $2 , $3 , $4 , $5 , $6 , $7 , $8 , $9 , $10 , $11 , $12 ) RETURNING id , name , points , activity_type , scoring_model , scoring_daily_cap , scoring_threshold_minutes , category , tags , icon , colour , archived_at;
 
 -- name: SetActivityLog :exec
 INSERT INTO user_activity_logs (id , user_id , activity_id , duration , points , logged_at , activity_description  ) VALUES ($1 , $2 , $3 , $4 , $5 , $6 , $7  ); 
@@ -58,9 +51,9 @@ FROM user_activity_logs
 WHERE user_id = $1 
 AND DATE(logged_at) = CURRENT_DATE;
 
--- name: EditActivity :exec
+-- name: EditActivity :execrows
 --
-UPDATE user_activities SET name = $1 , points = $2, activity_type = 'custom' WHERE id = $3;
+UPDATE user_activities SET name = $1 , points = $2, activity_type = 'custom', updated_at = NOW() WHERE id = $3 AND user_id = $4 AND deleted_at IS NULL;
 
 -- name: CheckIfActivityLogExists :one
 
@@ -68,8 +61,8 @@ SELECT EXISTS (
   SELECT 1 FROM user_activity_logs WHERE user_id = $1 AND activity_id = $2
 );
 
--- name: DeleteActivity :exec
-DELETE FROM user_activities WHERE id = $1;
+-- name: DeleteActivity :execrows
+UPDATE user_activities SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL;
 
 -- name: GetDailyMinutes :one
 
@@ -77,3 +70,34 @@ SELECT COALESCE(SUM(duration), 0)::BIGINT AS total_hours
 FROM user_activity_logs
 WHERE user_id = $1
 AND DATE(logged_at) = CURRENT_DATE;
+
+-- name: GetUserActivitiesForExport :many
+SELECT * FROM user_activities WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetUserActivityLogsForExport :many
+SELECT user_activity_logs.id , activity_id , ua.name , duration , user_activity_logs.points , activity_description , logged_at FROM user_activity_logs LEFT JOIN user_activities ua ON ua.id = user_activity_logs.activity_id WHERE user_activity_logs.user_id = $1 ORDER BY logged_at;
+
+-- name: GetUserActivity :one
+SELECT * FROM user_activities WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL;
+
+-- name: SetActivityScoring :exec
+UPDATE user_activities SET scoring_model = $2 , scoring_daily_cap = $3 , scoring_threshold_minutes = $4 , updated_at = NOW() WHERE id = $1;
+
+-- name: GetDailyActivityUsage :one
+SELECT CAST(COALESCE(SUM(duration), 0) AS INT) AS minutes , CAST(COALESCE(SUM(points), 0) AS INT) AS points
+FROM user_activity_logs
+WHERE user_id = $1
+AND activity_id = $2
+AND DATE(logged_at) = CURRENT_DATE;
+
+-- name: SetActivityDetails :exec
+UPDATE user_activities
+SET category = COALESCE(sqlc.narg(category), category) ,
+    tags = COALESCE(sqlc.narg(tags)::text[], tags) ,
+    icon = COALESCE(sqlc.narg(icon), icon) ,
+    colour = COALESCE(sqlc.narg(colour), colour) ,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: SetActivityArchivedAt :exec
+UPDATE user_activities SET archived_at = $1, updated_at = NOW() WHERE id = $2 AND user_id = $3;
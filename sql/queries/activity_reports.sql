@@ -38,3 +38,24 @@ FROM
 WHERE 
     logged_at >= $1 AND logged_at < $2 
     AND user_id = $3;
+
+-- name: GetCategoryBreakdown :many
+SELECT COALESCE(ua.category, '') AS category ,
+    CAST(COALESCE(SUM(ual.duration), 0) AS INTEGER) AS total_minutes ,
+    CAST(COALESCE(SUM(ual.points), 0) AS INTEGER) AS total_points
+FROM user_activity_logs ual
+LEFT JOIN user_activities ua ON ua.id = ual.activity_id
+WHERE ual.user_id = $1 AND ual.logged_at >= $2 AND ual.logged_at < $3
+GROUP BY COALESCE(ua.category, '')
+ORDER BY total_minutes DESC;
+
+-- name: GetTagBreakdown :many
+SELECT CAST(t.tag AS TEXT) AS tag ,
+    CAST(COALESCE(SUM(ual.duration), 0) AS INTEGER) AS total_minutes ,
+    CAST(COALESCE(SUM(ual.points), 0) AS INTEGER) AS total_points
+FROM user_activity_logs ual
+JOIN user_activities ua ON ua.id = ual.activity_id
+CROSS JOIN LATERAL UNNEST(ua.tags) AS t(tag)
+WHERE ual.user_id = $1 AND ual.logged_at >= $2 AND ual.logged_at < $3
+GROUP BY t.tag
+ORDER BY total_minutes DESC;
@@ -0,0 +1,31 @@
+-- name: GetActivityTemplates :many
+SELECT t.id, t.slug, t.name, t.description, t.created_by, u.username AS creator_username, t.published_at, t.created_at, t.updated_at
+FROM activity_templates t
+LEFT JOIN users u ON u.id = t.created_by
+WHERE (t.created_by = sqlc.arg(user_id) OR (NOT sqlc.arg(mine)::boolean AND t.published_at IS NOT NULL))
+ORDER BY t.created_by IS NOT NULL, t.name;
+
+-- name: GetActivityTemplate :one
+SELECT t.id, t.slug, t.name, t.description, t.created_by, u.username AS creator_username, t.published_at, t.created_at, t.updated_at
+FROM activity_templates t
+LEFT JOIN users u ON u.id = t.created_by
+WHERE t.id = sqlc.arg(id) AND (t.created_by = sqlc.arg(user_id) OR t.published_at IS NOT NULL);
+
+-- name: GetCuratedActivityTemplate :one
+SELECT * FROM activity_templates WHERE slug = $1 AND created_by IS NULL;
+
+-- name: GetActivityTemplateItems :many
+SELECT * FROM activity_template_items WHERE template_id = ANY(sqlc.arg(template_ids)::uuid[]) ORDER BY points DESC, name;
+
+-- name: CreateActivityTemplate :one
+INSERT INTO activity_templates (id, name, description, created_by) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: CreateActivityTemplateItem :exec
+INSERT INTO activity_template_items (id, template_id, name, points, scoring_model, scoring_daily_cap, scoring_threshold_minutes, category, tags, icon, colour)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11);
+
+-- name: SetActivityTemplatePublishedAt :execrows
+UPDATE activity_templates SET published_at = $1, updated_at = NOW() WHERE id = $2 AND created_by = $3;
+
+-- name: DeleteActivityTemplate :execrows
+DELETE FROM activity_templates WHERE id = $1 AND created_by = $2;
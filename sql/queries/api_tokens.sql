@@ -0,0 +1,16 @@
+-- name: CreateApiToken :one
+INSERT INTO api_tokens (id, user_id, name, token_hash, token_prefix, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetApiTokenByHash :one
+SELECT * FROM api_tokens WHERE token_hash = $1;
+
+-- name: GetUserApiTokens :many
+SELECT * FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC;
+
+-- name: TouchApiToken :exec
+UPDATE api_tokens SET last_used_at = NOW()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
+
+-- name: DeleteUserApiToken :execrows
+DELETE FROM api_tokens WHERE id = $1 AND user_id = $2;
@@ -0,0 +1,11 @@
+-- name: CreateEmailVerification :exec
+INSERT INTO email_verifications (id, user_id, token_hash, expires_at, new_email) VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetEmailVerification :one
+SELECT * FROM email_verifications WHERE token_hash = $1;
+
+-- name: CountEmailVerificationsSince :one
+SELECT COUNT(*) FROM email_verifications WHERE user_id = $1 AND created_at > $2;
+
+-- name: DeleteUserEmailVerifications :exec
+DELETE FROM email_verifications WHERE user_id = $1;
@@ -0,0 +1,29 @@
+-- name: CreateUserIdentity :one
+INSERT INTO user_identities (id, user_id, provider, subject, email) VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetUserByIdentity :one
+SELECT users.* FROM users
+JOIN user_identities ON user_identities.user_id = users.id
+WHERE user_identities.provider = $1 AND user_identities.subject = $2;
+
+-- name: GetUserIdentities :many
+SELECT * FROM user_identities WHERE user_id = $1 ORDER BY created_at;
+
+-- name: DeleteUserIdentity :execrows
+DELETE FROM user_identities
+WHERE user_identities.user_id = $1 AND user_identities.provider = $2
+AND (
+    EXISTS (SELECT 1 FROM users WHERE users.id = $1 AND users.password_hash IS NOT NULL)
+    OR (SELECT COUNT(*) FROM user_identities other WHERE other.user_id = $1) > 1
+);
+
+-- name: CreateOidcLoginState :exec
+INSERT INTO oidc_login_states (state_hash, provider, code_verifier, nonce, user_id, expires_at) VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: ConsumeOidcLoginState :one
+DELETE FROM oidc_login_states WHERE state_hash = $1
+RETURNING *;
+
+-- name: DeleteExpiredOidcLoginStates :exec
+DELETE FROM oidc_login_states WHERE expires_at < NOW();
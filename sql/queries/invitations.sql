@@ -1,6 +1,15 @@
--- name: CreateTeamInvitation :exec
-INSERT INTO team_invitations (id, team_id, sender_id, recipient_id, created_at, updated_at)
-VALUES ($1, $2, $3, $4, $5, $6);
+-- name: CreateTeamInvitation :one
+INSERT INTO team_invitations (id, team_id, sender_id, recipient_id, status, created_at, updated_at, expires_at)
+VALUES ($1, $2, $3, $4, 'pending', $5, $6, $7)
+ON CONFLICT (team_id, recipient_id) DO UPDATE
+SET sender_id = EXCLUDED.sender_id,
+    status = 'pending',
+    responded_at = NULL,
+    created_at = EXCLUDED.created_at,
+    updated_at = EXCLUDED.updated_at,
+    expires_at = EXCLUDED.expires_at
+WHERE team_invitations.status <> 'pending' OR team_invitations.expires_at <= NOW()
+RETURNING *;
 
 -- name: GetTeamInvitations :many
 SELECT 
@@ -8,28 +17,96 @@ SELECT
     t.id AS team_id,
     t.name AS team_name,
     t.team_industry AS team_industry,
-    t.team_size AS team_size
+    t.team_size AS team_size,
+    u.username AS sender_username,
+    ti.expires_at
 FROM 
     team_invitations ti
 JOIN 
     teams t 
 ON 
     ti.team_id = t.id
+JOIN 
+    users u
+ON 
+    ti.sender_id = u.id
 WHERE 
     ti.recipient_id = $1
+    AND ti.status = 'pending'
+    AND ti.expires_at > NOW()
 ORDER BY 
     ti.created_at DESC; 
 
--- name: GetInvitationsCount :one
+-- name: GetSentTeamInvitations :many
+SELECT 
+    ti.id AS invitation_id,
+    t.id AS team_id,
+    t.name AS team_name,
+    u.username AS recipient_username,
+    CAST(CASE 
+        WHEN ti.status = 'pending' AND ti.expires_at <= NOW() THEN 'expired'
+        ELSE ti.status
+    END AS TEXT) AS status,
+    ti.created_at,
+    ti.expires_at,
+    ti.responded_at
+FROM 
+    team_invitations ti
+JOIN 
+    teams t ON ti.team_id = t.id
+JOIN 
+    users u ON ti.recipient_id = u.id
+WHERE 
+    ti.sender_id = $1
+ORDER BY 
+    ti.created_at DESC;
+
+-- name: GetTeamInvitation :one
+SELECT * FROM team_invitations
+WHERE id = $1 AND team_id = $2;
 
-SELECT COUNT(recipient_id) AS invite_count FROM team_invitations ti
-WHERE ti.recipient_id = $1 AND ti.seen = false;
+-- name: GetPendingTeamInvitation :one
+SELECT * FROM team_invitations
+WHERE id = $1 AND recipient_id = $2 AND status = 'pending' AND expires_at > NOW()
+FOR UPDATE;
+
+-- name: SetTeamInvitationStatus :exec
+UPDATE team_invitations
+SET status = $1, responded_at = NOW(), updated_at = NOW()
+WHERE id = $2;
 
--- name: SetInvitationAsSeen :exec
+-- name: RevokeSentTeamInvitations :exec
 UPDATE team_invitations
-SET seen = true
-WHERE recipient_id = $1 AND seen = false;
+SET status = 'revoked', responded_at = NOW(), updated_at = NOW()
+WHERE team_id = $1 AND sender_id = $2 AND status = 'pending';
 
--- name: DeleteTeamInvitation :exec
-DELETE FROM team_invitations
+-- name: CreateTeamInviteLink :one
+INSERT INTO team_invite_links (id, team_id, created_by, token_hash, max_uses, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetTeamInviteLinks :many
+SELECT * FROM team_invite_links
+WHERE team_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetTeamInviteLinkByTokenHash :one
+SELECT * FROM team_invite_links
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: IncrementTeamInviteLinkUses :exec
+UPDATE team_invite_links
+SET uses = uses + 1
 WHERE id = $1;
+
+-- name: RevokeTeamInviteLink :execrows
+UPDATE team_invite_links
+SET revoked_at = NOW()
+WHERE id = $1 AND team_id = $2 AND revoked_at IS NULL;
+
+-- name: GetUnseenTeamInvitationsCount :one
+SELECT COUNT(DISTINCT ti.id) FROM team_invitations ti
+JOIN notifications n ON n.user_id = ti.recipient_id AND n.type = 'invite.received'
+  AND n.payload->>'invitation_id' = ti.id::text
+WHERE ti.recipient_id = $1 AND ti.status = 'pending' AND ti.expires_at > NOW() AND n.read_at IS NULL;
@@ -0,0 +1,37 @@
+-- name: CreateTeamJoinRequest :one
+INSERT INTO team_join_requests (id, team_id, user_id, message) VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetTeamJoinRequests :many
+SELECT tjr.id, tjr.user_id, u.username, tjr.message, tjr.created_at
+FROM team_join_requests tjr
+JOIN users u ON u.id = tjr.user_id
+WHERE tjr.team_id = $1 AND tjr.status = 'pending'
+ORDER BY tjr.created_at;
+
+-- name: GetPendingTeamJoinRequest :one
+SELECT * FROM team_join_requests
+WHERE id = $1 AND team_id = $2 AND status = 'pending'
+FOR UPDATE;
+
+-- name: SetTeamJoinRequestStatus :exec
+UPDATE team_join_requests
+SET status = $1, responded_at = NOW()
+WHERE id = $2;
+
+-- name: GetUserTeamJoinRequests :many
+SELECT tjr.id, tjr.team_id, t.name AS team_name, tjr.status, tjr.created_at, tjr.responded_at
+FROM team_join_requests tjr
+JOIN teams t ON t.id = tjr.team_id
+WHERE tjr.user_id = $1
+ORDER BY tjr.created_at DESC;
+
+-- name: CancelTeamJoinRequest :execrows
+UPDATE team_join_requests
+SET status = 'cancelled', responded_at = NOW()
+WHERE id = $1 AND user_id = $2 AND status = 'pending';
+
+-- name: CancelPendingTeamJoinRequest :exec
+UPDATE team_join_requests
+SET status = 'cancelled', responded_at = NOW()
+WHERE team_id = $1 AND user_id = $2 AND status = 'pending';
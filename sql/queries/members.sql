@@ -21,7 +21,8 @@ ORDER BY
     u.username;
 
 -- name: SetMemberRoles :exec
-INSERT INTO team_user_roles (id, team_membership_id, role_id) VALUES ($1, $2, $3);
+INSERT INTO team_user_roles (id, team_membership_id, role_id) VALUES ($1, $2, $3)
+ON CONFLICT (team_membership_id, role_id) DO NOTHING;
 
 -- name: GetNotAssignedRoles :many
 SELECT 
@@ -33,7 +34,51 @@ LEFT JOIN
     team_user_roles tur ON tr.id = tur.role_id 
     AND tur.team_membership_id = $1
 WHERE 
-    tur.role_id IS NULL
+    tr.team_id = $2
+    AND tr.role_name <> 'owner'
+    AND tur.role_id IS NULL
 ORDER BY 
     tr.role_name;
 
+-- name: GetTeamMembership :one
+SELECT * FROM team_memberships WHERE id = $1 AND team_id = $2;
+
+-- name: GetTeamMembershipByUser :one
+SELECT * FROM team_memberships WHERE team_id = $1 AND user_id = $2;
+
+-- name: DeleteTeamMembership :exec
+DELETE FROM team_memberships WHERE id = $1;
+
+-- name: DeleteMemberRole :exec
+DELETE FROM team_user_roles WHERE team_membership_id = $1 AND role_id = $2;
+
+-- name: GetMemberRoles :many
+SELECT tr.id, tr.role_name
+FROM team_user_roles tur
+JOIN team_roles tr ON tr.id = tur.role_id
+WHERE tur.team_membership_id = $1
+ORDER BY tr.role_name;
+
+-- name: GetOldestOtherTeamMembership :one
+SELECT * FROM team_memberships WHERE team_id = $1 AND user_id <> $2 ORDER BY created_at LIMIT 1;
+
+-- name: GetUserTeamMemberships :many
+SELECT
+    t.id AS team_id,
+    t.name,
+    tm.created_at AS joined_at,
+    COALESCE(CAST(STRING_AGG(tr.role_name, ', ') AS TEXT), '') AS roles
+FROM
+    team_memberships tm
+JOIN
+    teams t ON tm.team_id = t.id
+LEFT JOIN
+    team_user_roles tur ON tm.id = tur.team_membership_id
+LEFT JOIN
+    team_roles tr ON tur.role_id = tr.id
+WHERE
+    tm.user_id = $1
+GROUP BY
+    t.id, t.name, tm.created_at
+ORDER BY
+    tm.created_at;
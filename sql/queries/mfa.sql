@@ -0,0 +1,29 @@
+-- name: UpsertUserTotp :exec
+INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, created_at = NOW()
+WHERE user_totp.confirmed_at IS NULL;
+
+-- name: GetUserTotp :one
+SELECT * FROM user_totp WHERE user_id = $1;
+
+-- name: ConfirmUserTotp :exec
+UPDATE user_totp SET confirmed_at = NOW(), last_used_step = $2 WHERE user_id = $1;
+
+-- name: UseTotpStep :execrows
+UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DeleteUserTotp :exec
+DELETE FROM user_totp WHERE user_id = $1;
+
+-- name: CreateRecoveryCodes :exec
+INSERT INTO mfa_recovery_codes (id, user_id, code_hash)
+SELECT gen_random_uuid(), sqlc.arg(user_id), unnest(sqlc.arg(code_hashes)::text[]);
+
+-- name: UseRecoveryCode :execrows
+UPDATE mfa_recovery_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: DeleteUserRecoveryCodes :exec
+DELETE FROM mfa_recovery_codes WHERE user_id = $1;
@@ -0,0 +1,39 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, user_id, type, payload) VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetNotification :one
+SELECT * FROM notifications WHERE id = $1;
+
+-- name: GetNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg(user_id) AND (read_at IS NULL OR NOT sqlc.arg(unread_only)::boolean)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
+
+-- name: GetUnreadNotificationsCount :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = sqlc.arg(user_id) AND read_at IS NULL
+  AND (sqlc.arg(type)::text = '' OR type = sqlc.arg(type)::text);
+
+-- name: MarkNotificationRead :execrows
+UPDATE notifications
+SET read_at = COALESCE(read_at, NOW())
+WHERE id = $1 AND user_id = $2;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id) AND read_at IS NULL
+  AND (sqlc.arg(type)::text = '' OR type = sqlc.arg(type)::text);
+
+-- name: GetUndeliveredNotifications :many
+SELECT * FROM notifications
+WHERE user_id = $1 AND delivered_at IS NULL
+ORDER BY created_at
+LIMIT $2;
+
+-- name: MarkNotificationsDelivered :exec
+UPDATE notifications
+SET delivered_at = NOW()
+WHERE id = ANY(sqlc.arg(ids)::uuid[]) AND delivered_at IS NULL;
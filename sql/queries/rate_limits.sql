@@ -0,0 +1,21 @@
+-- name: GetLoginFailure :one
+SELECT * FROM login_failures WHERE user_id = $1;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_failures (user_id, failed_count) VALUES ($1, 1)
+ON CONFLICT (user_id) DO UPDATE SET
+  failed_count = CASE WHEN login_failures.last_failed_at < NOW() - INTERVAL '1 day' THEN 1 ELSE login_failures.failed_count + 1 END,
+  last_failed_at = NOW()
+RETURNING *;
+
+-- name: SetLoginLockedUntil :exec
+UPDATE login_failures SET locked_until = $2 WHERE user_id = $1;
+
+-- name: ClearLoginFailures :exec
+DELETE FROM login_failures WHERE user_id = $1;
+
+-- name: ConsumeLlmQuota :one
+INSERT INTO llm_usage (user_id, day, calls) VALUES ($1, $2, 1)
+ON CONFLICT (user_id, day) DO UPDATE SET calls = llm_usage.calls + 1
+WHERE llm_usage.calls < sqlc.arg(daily_quota)::int
+RETURNING calls;
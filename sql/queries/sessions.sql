@@ -0,0 +1,35 @@
+-- name: CreateSession :one
+INSERT INTO sessions (id, user_id, refresh_token_hash, user_agent, ip_address, expires_at) VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetSessionByRefreshTokenHash :one
+SELECT * FROM sessions WHERE refresh_token_hash = $1
+FOR UPDATE;
+
+-- name: GetSessionByPreviousTokenHash :one
+SELECT * FROM sessions WHERE previous_token_hash = $1;
+
+-- name: RotateSessionRefreshToken :exec
+UPDATE sessions
+SET previous_token_hash = refresh_token_hash, refresh_token_hash = $2, last_used_at = NOW(), expires_at = $3
+WHERE id = $1;
+
+-- name: IsSessionActive :one
+SELECT EXISTS (
+    SELECT 1 FROM sessions
+    WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > NOW()
+);
+
+-- name: GetUserSessions :many
+SELECT * FROM sessions
+WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSession :exec
+UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeUserSession :execrows
+UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserSessions :exec
+UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL;
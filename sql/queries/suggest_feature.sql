@@ -1,5 +1,5 @@
 -- name: CreateSuggestFeature :exec
-INSERT INTO suggest_feature (id , title, description , username) VALUES ($1, $2 , $3 , $4) RETURNING *;
+INSERT INTO suggest_feature (id , title, description , username , user_id) VALUES ($1, $2 , $3 , $4 , $5) RETURNING *;
 
 -- name: GetSuggestFeature :many
 SELECT * FROM suggest_feature ORDER BY upvote DESC;
@@ -9,4 +9,11 @@ UPDATE suggest_feature SET upvote = upvote + 1 WHERE id = $1 RETURNING *;
 -- name: SuggestFeatureDownvote :exec
 UPDATE suggest_feature SET upvote = upvote - 1 WHERE id = $1 RETURNING *;
 
+-- name: GetUserSuggestFeatures :many
+SELECT * FROM suggest_feature WHERE user_id = $1;
 
+-- name: AnonymizeUserSuggestFeatures :exec
+UPDATE suggest_feature SET username = 'Deleted user', user_id = NULL WHERE user_id = $1;
+
+-- name: RenameUserSuggestFeatures :exec
+UPDATE suggest_feature SET username = $2 WHERE user_id = $1;
@@ -36,7 +36,20 @@ FROM teams t
 WHERE t.id = $1;
 
 -- name: GetTeamActivities :many
-SELECT activity_name, points , activity_roles FROM team_activities WHERE team_id = $1;
+SELECT id, activity_name, points, archived_at FROM team_activities
+WHERE team_id = sqlc.arg(team_id) AND (archived_at IS NULL OR sqlc.arg(include_archived)::boolean)
+ORDER BY created_at;
+
+-- name: GetTeamActivityRoles :many
+SELECT tar.team_activity_id, tr.id AS role_id, tr.role_name, tar.points
+FROM team_activity_roles tar
+JOIN team_roles tr ON tr.id = tar.role_id
+WHERE tr.team_id = $1
+ORDER BY tr.role_name;
+
+-- name: SetTeamActivityRole :exec
+INSERT INTO team_activity_roles (team_activity_id, role_id, points) VALUES ($1, $2, $3)
+ON CONFLICT (team_activity_id, role_id) DO UPDATE SET points = EXCLUDED.points;
 
 -- name: SetTeamRole :one
 INSERT INTO team_roles (id, role_name, team_id) VALUES ($1, $2, $3) RETURNING *;
@@ -49,7 +62,7 @@ SELECT id, role_name FROM team_roles
 WHERE team_id = $1;
 
 -- name: SetTeamActivity :exec
-INSERT INTO team_activities (id,team_id ,  activity_name, points, created_at , updated_at , activity_roles) VALUES ($1, $2, $3, $4 , $5 , $6 , $7);
+INSERT INTO team_activities (id,team_id ,  activity_name, points, created_at , updated_at) VALUES ($1, $2, $3, $4 , $5 , $6);
 
 -- name: IsUserTeamOwner :one
 SELECT 
@@ -66,23 +79,37 @@ AND
     tm.team_id = $2
 LIMIT 1;
 -- name: GetUserTeamActivities :many
-WITH user_roles AS (
-    SELECT array_agg(tr.role_name) AS roles
+WITH membership AS (
+    SELECT tm.id
     FROM team_memberships tm
-    JOIN team_user_roles tur ON tur.team_membership_id = tm.id
-    JOIN team_roles tr ON tr.id = tur.role_id
     WHERE tm.user_id = $1
-      AND tm.team_id =  $2
+      AND tm.team_id = $2
 ),
 filtered_activities AS (
-    SELECT ta.id, ta.team_id, ta.activity_name, ta.points, ta.created_at, ta.updated_at
-    FROM team_activities ta, user_roles ur
-    WHERE ta.team_id =  $2   AND (
-        'owner' = ANY(ur.roles)  
+    SELECT ta.id, ta.team_id, ta.activity_name,
+        CAST(COALESCE((
+            SELECT MAX(tar.points)
+            FROM team_activity_roles tar
+            JOIN team_user_roles tur ON tur.role_id = tar.role_id
+            WHERE tar.team_activity_id = ta.id
+              AND tur.team_membership_id = m.id
+        ), ta.points) AS INTEGER) AS points,
+        ta.created_at, ta.updated_at
+    FROM team_activities ta, membership m
+    WHERE ta.team_id = $2 AND ta.archived_at IS NULL AND (
+        EXISTS (
+            SELECT 1
+            FROM team_user_roles tur
+            JOIN team_roles tr ON tr.id = tur.role_id
+            WHERE tur.team_membership_id = m.id
+              AND tr.role_name = 'owner'
+        )
         OR EXISTS (
             SELECT 1
-            FROM unnest(ta.activity_roles) ar
-            WHERE ar = ANY(ur.roles)
+            FROM team_activity_roles tar
+            JOIN team_user_roles tur ON tur.role_id = tar.role_id
+            WHERE tar.team_activity_id = ta.id
+              AND tur.team_membership_id = m.id
         )
     )
 )
@@ -90,4 +117,66 @@ SELECT *
 FROM filtered_activities
 ORDER BY created_at DESC;
 
+-- name: IsTeamOwner :one
+SELECT EXISTS (
+    SELECT 1
+    FROM team_memberships tm
+    JOIN team_user_roles tur ON tur.team_membership_id = tm.id
+    JOIN team_roles tr ON tr.id = tur.role_id
+    WHERE tm.team_id = $1
+      AND tm.user_id = $2
+      AND tr.role_name = 'owner'
+);
+
+-- name: GetTeamOwnerRole :one
+SELECT * FROM team_roles WHERE team_id = $1 AND role_name = 'owner';
+
+-- name: SetTeamCreatedBy :exec
+UPDATE teams SET created_by = $1, updated_at = NOW() WHERE id = $2;
+
+-- name: DeleteTeam :exec
+DELETE FROM teams WHERE id = $1;
+
+-- name: DeleteUserTeamActivityRequests :exec
+DELETE FROM team_activity_requests WHERE team_id = $1 AND user_id = $2;
+
+-- name: GetTeamRole :one
+SELECT * FROM team_roles WHERE id = $1 AND team_id = $2;
+
+-- name: RenameTeamRole :exec
+UPDATE team_roles SET role_name = $1 WHERE id = $2 AND team_id = $3;
+
+-- name: DeleteTeamRole :exec
+DELETE FROM team_roles WHERE id = $1 AND team_id = $2;
+
+-- name: GetTeamActivity :one
+SELECT * FROM team_activities WHERE id = $1 AND team_id = $2;
+
+-- name: UpdateTeamActivity :exec
+UPDATE team_activities SET activity_name = $1, points = $2, updated_at = NOW() WHERE id = $3 AND team_id = $4;
+
+-- name: SetTeamActivityArchivedAt :exec
+UPDATE team_activities SET archived_at = $1, updated_at = NOW() WHERE id = $2 AND team_id = $3;
+
+-- name: DeleteTeamActivityRoles :exec
+DELETE FROM team_activity_roles WHERE team_activity_id = $1;
+
+-- name: DeleteTeamActivity :exec
+DELETE FROM team_activities WHERE id = $1 AND team_id = $2;
+
+-- name: DiscoverTeams :many
+SELECT t.id, t.name, t.team_industry, t.team_size,
+    (SELECT COUNT(*) FROM team_memberships tm WHERE tm.team_id = t.id) AS member_count,
+    EXISTS (SELECT 1 FROM team_memberships tm WHERE tm.team_id = t.id AND tm.user_id = sqlc.arg(user_id)) AS is_member,
+    EXISTS (SELECT 1 FROM team_join_requests tjr WHERE tjr.team_id = t.id AND tjr.user_id = sqlc.arg(user_id) AND tjr.status = 'pending') AS has_pending_request
+FROM teams t
+WHERE t.is_private = FALSE
+  AND (sqlc.arg(search)::text = '' OR t.name ILIKE '%' || sqlc.arg(search)::text || '%')
+  AND (sqlc.arg(industry)::text = '' OR t.team_industry ILIKE sqlc.arg(industry)::text)
+  AND (sqlc.arg(min_size)::integer = 0 OR t.team_size >= sqlc.arg(min_size)::integer)
+  AND (sqlc.arg(max_size)::integer = 0 OR t.team_size <= sqlc.arg(max_size)::integer)
+ORDER BY t.name
+LIMIT sqlc.arg(row_limit) OFFSET sqlc.arg(row_offset);
 
+-- name: GetTeamsCreatedByUser :many
+SELECT * FROM teams WHERE created_by = $1;
@@ -12,3 +12,6 @@ RETURNING *;
 UPDATE user_goals SET status = 'not completed' WHERE user_id = $1 AND DATE(created_at) = CURRENT_DATE
 RETURNING *;
 
+
+-- name: GetUserGoals :many
+SELECT * FROM user_goals WHERE user_id = $1 ORDER BY goal_date;
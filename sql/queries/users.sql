@@ -1,13 +1,13 @@
 -- name: CreateUser :one
-INSERT INTO users (id , created_at , updated_at , username , email , password_hash , google_id) VALUES ($1, $2, $3, $4 , $5 , $6 , $7) RETURNING *;
+INSERT INTO users (id , created_at , updated_at , username , email , password_hash , email_verified) VALUES ($1, $2, $3, $4 , $5 , $6 , $7) RETURNING *;
 
 
--- name: GetUserByGoogleId :one
-SELECT * FROM users WHERE google_id = $1;
-
 -- name: GetUserByEmail :one
 SELECT * FROM users WHERE email = $1;
 
+-- name: GetUserById :one
+SELECT * FROM users WHERE id = $1;
+
 -- name: GetUserByUsername :one
 SELECT * FROM users WHERE username = $1;
 
@@ -26,6 +26,8 @@ LEFT JOIN
 ON 
     u.id = ti.recipient_id 
     AND ti.team_id = $1
+    AND ti.status = 'pending'
+    AND ti.expires_at > NOW()
 WHERE 
     u.id != $2
     AND u.username ILIKE $3
@@ -34,18 +36,44 @@ ORDER BY
 LIMIT 10;  
 
 -- name: CreatePasswordReset :exec
+INSERT INTO password_reset (id , user_id , token_hash , expires_at) VALUES ($1 , $2 , $3  ,$4);
 
-INSERT INTO password_reset (id , user_id , token , expires_at) VALUES ($1 , $2 , $3  ,$4);
+-- name: ConsumePasswordReset :one
+DELETE FROM password_reset WHERE token_hash = $1 RETURNING *;
 
--- name: GetPasswordReset :one
+-- name: DeleteUserPasswordResets :exec
+DELETE FROM password_reset WHERE user_id = $1;
 
-SELECT u.email , pr.expires_at FROM password_reset pr  JOIN users u  ON u.id = pr.user_id  WHERE token = $1;
+-- name: SetNewPassword :exec
+UPDATE users SET password_hash = $1, updated_at = NOW() WHERE id = $2;
 
--- name: DeletePasswordReset :exec
-DELETE FROM password_reset WHERE token = $1;
 
--- name: SetNewPassword :exec
+-- name: SetUserTokensValidAfter :exec
+UPDATE users SET tokens_valid_after = NOW() WHERE id = $1;
+
+-- name: SetUserEmailVerified :exec
+UPDATE users SET email_verified = TRUE, updated_at = NOW() WHERE id = $1;
+
+-- name: RemoveUserPassword :execrows
+UPDATE users SET password_hash = NULL, updated_at = NOW()
+WHERE id = $1 AND EXISTS (SELECT 1 FROM user_identities WHERE user_id = $1);
+
+-- name: ScheduleUserDeletion :exec
+UPDATE users SET deletion_scheduled_at = $2, updated_at = NOW() WHERE id = $1;
+
+-- name: CancelUserDeletion :exec
+UPDATE users SET deletion_scheduled_at = NULL, updated_at = NOW() WHERE id = $1;
+
+-- name: GetUsersDueForDeletion :many
+SELECT id FROM users WHERE deletion_scheduled_at <= $1;
 
-UPDATE users SET password_hash = $1 WHERE email = $2;
+-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1;
 
+-- name: UpdateUserProfile :one
+UPDATE users SET username = $2, display_name = $3, avatar_url = $4, timezone = $5, locale = $6, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
 
+-- name: SetUserEmail :exec
+UPDATE users SET email = $2, email_verified = TRUE, updated_at = NOW() WHERE id = $1;
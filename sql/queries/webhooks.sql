@@ -0,0 +1,48 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, user_id, team_id, created_by, url, secret, event_types) VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks WHERE id = $1;
+
+-- name: GetUserWebhooks :many
+SELECT * FROM webhooks WHERE user_id = $1 ORDER BY created_at;
+
+-- name: GetTeamWebhooks :many
+SELECT * FROM webhooks WHERE team_id = $1 ORDER BY created_at;
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhooks WHERE id = $1;
+
+-- name: GetUserWebhooksForEvent :many
+SELECT * FROM webhooks WHERE user_id = sqlc.arg(user_id) AND sqlc.arg(event_type)::text = ANY(event_types);
+
+-- name: GetTeamWebhooksForEvent :many
+SELECT * FROM webhooks WHERE team_id = sqlc.arg(team_id) AND sqlc.arg(event_type)::text = ANY(event_types);
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, webhook_id, event_type, payload) VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: ClaimDueWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg(lease_until)::timestamptz
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: SetWebhookDeliveryResult :exec
+UPDATE webhook_deliveries
+SET status = $2, attempts = $3, next_attempt_at = $4, last_attempt_at = NOW(), response_status = $5, last_error = $6
+WHERE id = $1;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
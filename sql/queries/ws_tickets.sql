@@ -0,0 +1,6 @@
+-- name: UseWsTicket :execrows
+INSERT INTO used_ws_tickets (jti, expires_at) VALUES ($1, $2)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: DeleteExpiredWsTickets :exec
+DELETE FROM used_ws_tickets WHERE expires_at < NOW();
@@ -0,0 +1,31 @@
+-- +goose Up
+ALTER TABLE team_roles DROP CONSTRAINT IF EXISTS team_roles_team_id_fkey;
+ALTER TABLE team_roles
+ADD CONSTRAINT team_roles_team_id_fkey
+FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE;
+
+ALTER TABLE team_activity_logs DROP CONSTRAINT IF EXISTS team_activity_logs_team_id_fkey;
+ALTER TABLE team_activity_logs
+ADD CONSTRAINT team_activity_logs_team_id_fkey
+FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE;
+
+ALTER TABLE team_activity_requests DROP CONSTRAINT IF EXISTS team_activity_requests_team_id_fkey;
+ALTER TABLE team_activity_requests
+ADD CONSTRAINT team_activity_requests_team_id_fkey
+FOREIGN KEY (team_id) REFERENCES teams(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE team_activity_requests DROP CONSTRAINT team_activity_requests_team_id_fkey;
+ALTER TABLE team_activity_requests
+ADD CONSTRAINT team_activity_requests_team_id_fkey
+FOREIGN KEY (team_id) REFERENCES teams(id);
+
+ALTER TABLE team_activity_logs DROP CONSTRAINT team_activity_logs_team_id_fkey;
+ALTER TABLE team_activity_logs
+ADD CONSTRAINT team_activity_logs_team_id_fkey
+FOREIGN KEY (team_id) REFERENCES teams(id);
+
+ALTER TABLE team_roles DROP CONSTRAINT team_roles_team_id_fkey;
+ALTER TABLE team_roles
+ADD CONSTRAINT team_roles_team_id_fkey
+FOREIGN KEY (team_id) REFERENCES teams(id);
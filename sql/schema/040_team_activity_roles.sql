@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE team_activity_roles (
+  team_activity_id UUID NOT NULL REFERENCES team_activities(id) ON DELETE CASCADE,
+  role_id UUID NOT NULL REFERENCES team_roles(id) ON DELETE CASCADE,
+  PRIMARY KEY (team_activity_id, role_id)
+);
+
+INSERT INTO team_activity_roles (team_activity_id, role_id)
+SELECT ta.id, tr.id
+FROM team_activities ta
+JOIN team_roles tr ON tr.team_id = ta.team_id AND tr.role_name = ANY(ta.activity_roles);
+
+ALTER TABLE team_activities DROP COLUMN activity_roles;
+
+-- +goose Down
+ALTER TABLE team_activities ADD COLUMN activity_roles TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE team_activities ta
+SET activity_roles = COALESCE((
+  SELECT array_agg(tr.role_name)
+  FROM team_activity_roles tar
+  JOIN team_roles tr ON tr.id = tar.role_id
+  WHERE tar.team_activity_id = ta.id
+), '{}');
+
+DROP TABLE team_activity_roles;
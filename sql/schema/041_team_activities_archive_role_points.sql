@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE team_activities ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE team_activity_roles ADD COLUMN points INTEGER;
+
+-- +goose Down
+ALTER TABLE team_activity_roles DROP COLUMN points;
+ALTER TABLE team_activities DROP COLUMN archived_at;
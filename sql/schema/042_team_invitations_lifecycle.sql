@@ -0,0 +1,35 @@
+-- +goose Up
+DELETE FROM team_invitations ti
+USING team_invitations newer
+WHERE ti.team_id = newer.team_id
+  AND ti.recipient_id = newer.recipient_id
+  AND (ti.created_at, ti.id) < (newer.created_at, newer.id);
+
+ALTER TABLE team_invitations
+ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW() + INTERVAL '7 days',
+ADD COLUMN responded_at TIMESTAMP WITH TIME ZONE,
+ADD CONSTRAINT team_invitations_status_check CHECK (status IN ('pending', 'accepted', 'declined', 'revoked', 'expired')),
+ADD CONSTRAINT team_invitations_team_recipient_key UNIQUE (team_id, recipient_id);
+
+CREATE INDEX team_invitations_sender_id_idx ON team_invitations (sender_id);
+
+CREATE TABLE team_invite_links (
+  id UUID PRIMARY KEY NOT NULL,
+  team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+  created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  token_hash TEXT NOT NULL UNIQUE,
+  max_uses INTEGER,
+  uses INTEGER NOT NULL DEFAULT 0,
+  expires_at TIMESTAMP WITH TIME ZONE,
+  revoked_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE team_invite_links;
+DROP INDEX team_invitations_sender_id_idx;
+ALTER TABLE team_invitations
+DROP CONSTRAINT team_invitations_team_recipient_key,
+DROP CONSTRAINT team_invitations_status_check,
+DROP COLUMN responded_at,
+DROP COLUMN expires_at;
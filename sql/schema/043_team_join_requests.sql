@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE team_join_requests (
+  id UUID PRIMARY KEY NOT NULL,
+  team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  message TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'denied', 'cancelled')),
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  responded_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX team_join_requests_pending_idx ON team_join_requests (team_id, user_id) WHERE status = 'pending';
+CREATE INDEX team_join_requests_user_id_idx ON team_join_requests (user_id);
+CREATE INDEX teams_public_name_idx ON teams (name) WHERE is_private = FALSE;
+
+-- +goose Down
+DROP INDEX teams_public_name_idx;
+DROP TABLE team_join_requests;
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE notifications (
+  id UUID PRIMARY KEY NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  type TEXT NOT NULL,
+  payload JSONB NOT NULL DEFAULT '{}',
+  read_at TIMESTAMP WITH TIME ZONE,
+  delivered_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX notifications_user_id_created_at_idx ON notifications (user_id, created_at DESC);
+CREATE INDEX notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
+
+INSERT INTO notifications (id, user_id, type, payload, read_at, delivered_at, created_at)
+SELECT gen_random_uuid(), ti.recipient_id, 'invite.received',
+    json_build_object('invitation_id', ti.id, 'team_id', ti.team_id, 'sender_username', u.username),
+    CASE WHEN ti.seen THEN NOW() END, NOW(), ti.created_at
+FROM team_invitations ti
+JOIN users u ON u.id = ti.sender_id
+WHERE ti.status = 'pending' AND ti.expires_at > NOW();
+
+ALTER TABLE team_invitations DROP COLUMN seen;
+
+-- +goose Down
+ALTER TABLE team_invitations ADD COLUMN seen BOOLEAN NOT NULL DEFAULT false;
+DROP TABLE notifications;
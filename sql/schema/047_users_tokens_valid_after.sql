@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN tokens_valid_after TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN tokens_valid_after;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Outstanding tokens were stored in plaintext and cannot be converted.
+DELETE FROM password_reset;
+ALTER TABLE password_reset RENAME COLUMN token TO token_hash;
+ALTER TABLE password_reset ALTER COLUMN token_hash TYPE TEXT;
+ALTER TABLE password_reset ADD CONSTRAINT password_reset_token_hash_key UNIQUE (token_hash);
+ALTER TABLE password_reset DROP CONSTRAINT password_reset_user_id_fkey;
+ALTER TABLE password_reset ADD CONSTRAINT password_reset_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE password_reset DROP CONSTRAINT password_reset_user_id_fkey;
+ALTER TABLE password_reset ADD CONSTRAINT password_reset_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id);
+ALTER TABLE password_reset DROP CONSTRAINT password_reset_token_hash_key;
+ALTER TABLE password_reset ALTER COLUMN token_hash TYPE VARCHAR(255);
+ALTER TABLE password_reset RENAME COLUMN token_hash TO token;
@@ -0,0 +1,39 @@
+-- +goose Up
+CREATE TABLE user_identities (
+  id UUID PRIMARY KEY NOT NULL,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  provider TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  email TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  UNIQUE (provider, subject),
+  UNIQUE (user_id, provider)
+);
+
+INSERT INTO user_identities (id, user_id, provider, subject, email)
+SELECT gen_random_uuid(), id, 'google', google_id, email FROM users WHERE google_id IS NOT NULL;
+
+ALTER TABLE users DROP COLUMN google_id;
+
+-- Pending OpenID Connect logins, keyed by the hash of the state parameter.
+-- user_id is set when an authenticated user is linking a new identity.
+CREATE TABLE oidc_login_states (
+  state_hash TEXT PRIMARY KEY NOT NULL,
+  provider TEXT NOT NULL,
+  code_verifier TEXT NOT NULL,
+  nonce TEXT NOT NULL,
+  user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE oidc_login_states;
+
+ALTER TABLE users ADD COLUMN google_id TEXT UNIQUE;
+
+UPDATE users SET google_id = ui.subject
+FROM user_identities ui
+WHERE ui.user_id = users.id AND ui.provider = 'google';
+
+DROP TABLE user_identities;
@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE login_failures (
+  user_id UUID PRIMARY KEY NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  failed_count INT NOT NULL,
+  last_failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  locked_until TIMESTAMP WITH TIME ZONE
+);
+
+-- Calls to the OpenAI backed activity parser, counted per user and UTC day.
+CREATE TABLE llm_usage (
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  day DATE NOT NULL,
+  calls INT NOT NULL,
+  PRIMARY KEY (user_id, day)
+);
+
+-- +goose Down
+DROP TABLE llm_usage;
+DROP TABLE login_failures;
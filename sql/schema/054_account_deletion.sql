@@ -0,0 +1,40 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN deletion_scheduled_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE suggest_feature ADD COLUMN user_id UUID REFERENCES users(id) ON DELETE SET NULL;
+UPDATE suggest_feature SET user_id = users.id FROM users WHERE users.username = suggest_feature.username;
+
+ALTER TABLE user_activities DROP CONSTRAINT IF EXISTS user_activities_user_id_fkey;
+ALTER TABLE user_activities
+ADD CONSTRAINT user_activities_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE team_activity_logs DROP CONSTRAINT IF EXISTS team_activity_logs_user_id_fkey;
+ALTER TABLE team_activity_logs
+ADD CONSTRAINT team_activity_logs_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+ALTER TABLE team_activity_requests DROP CONSTRAINT IF EXISTS team_activity_requests_user_id_fkey;
+ALTER TABLE team_activity_requests
+ADD CONSTRAINT team_activity_requests_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+-- +goose Down
+ALTER TABLE team_activity_requests DROP CONSTRAINT team_activity_requests_user_id_fkey;
+ALTER TABLE team_activity_requests
+ADD CONSTRAINT team_activity_requests_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE team_activity_logs DROP CONSTRAINT team_activity_logs_user_id_fkey;
+ALTER TABLE team_activity_logs
+ADD CONSTRAINT team_activity_logs_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE user_activities DROP CONSTRAINT user_activities_user_id_fkey;
+ALTER TABLE user_activities
+ADD CONSTRAINT user_activities_user_id_fkey
+FOREIGN KEY (user_id) REFERENCES users(id);
+
+ALTER TABLE suggest_feature DROP COLUMN user_id;
+
+ALTER TABLE users DROP COLUMN deletion_scheduled_at;
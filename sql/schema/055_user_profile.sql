@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT 'en';
+
+-- A verification with a new_email confirms an email change instead of the
+-- current address.
+ALTER TABLE email_verifications ADD COLUMN new_email TEXT;
+
+-- +goose Down
+ALTER TABLE email_verifications DROP COLUMN new_email;
+
+ALTER TABLE users DROP COLUMN locale;
+ALTER TABLE users DROP COLUMN timezone;
+ALTER TABLE users DROP COLUMN avatar_url;
+ALTER TABLE users DROP COLUMN display_name;
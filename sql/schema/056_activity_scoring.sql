@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE user_activities ADD COLUMN scoring_model TEXT NOT NULL DEFAULT 'per_hour'
+  CHECK (scoring_model IN ('per_hour', 'per_session', 'per_hour_capped', 'diminishing'));
+-- Most points a per_hour_capped activity can earn per day.
+ALTER TABLE user_activities ADD COLUMN scoring_daily_cap INT CHECK (scoring_daily_cap > 0);
+-- Minutes per day a diminishing activity earns its full rate for.
+ALTER TABLE user_activities ADD COLUMN scoring_threshold_minutes INT CHECK (scoring_threshold_minutes > 0);
+ALTER TABLE user_activities ADD CONSTRAINT user_activities_scoring_check CHECK (
+  (scoring_model <> 'per_hour_capped' OR scoring_daily_cap IS NOT NULL)
+  AND (scoring_model <> 'diminishing' OR scoring_threshold_minutes IS NOT NULL)
+);
+
+-- +goose Down
+ALTER TABLE user_activities DROP CONSTRAINT user_activities_scoring_check;
+ALTER TABLE user_activities DROP COLUMN scoring_threshold_minutes;
+ALTER TABLE user_activities DROP COLUMN scoring_daily_cap;
+ALTER TABLE user_activities DROP COLUMN scoring_model;
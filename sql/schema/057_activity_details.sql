@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE user_activities ADD COLUMN category TEXT NOT NULL DEFAULT '';
+ALTER TABLE user_activities ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE user_activities ADD COLUMN icon TEXT NOT NULL DEFAULT '';
+ALTER TABLE user_activities ADD COLUMN colour TEXT NOT NULL DEFAULT '';
+ALTER TABLE user_activities ADD COLUMN archived_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX user_activities_tags_idx ON user_activities USING GIN (tags);
+
+UPDATE user_activities SET category = 'Learning' WHERE activity_type = 'default' AND name IN ('Learning', 'Reading');
+UPDATE user_activities SET category = 'Health' WHERE activity_type = 'default' AND name IN ('Exercise', 'Meditation');
+UPDATE user_activities SET category = 'Chores' WHERE activity_type = 'default' AND name = 'Household Chores';
+UPDATE user_activities SET category = 'Leisure' WHERE activity_type = 'default' AND points < 0;
+
+-- +goose Down
+DROP INDEX user_activities_tags_idx;
+ALTER TABLE user_activities DROP COLUMN archived_at;
+ALTER TABLE user_activities DROP COLUMN colour;
+ALTER TABLE user_activities DROP COLUMN icon;
+ALTER TABLE user_activities DROP COLUMN tags;
+ALTER TABLE user_activities DROP COLUMN category;
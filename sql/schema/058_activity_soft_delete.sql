@@ -0,0 +1,7 @@
+-- +goose Up
+-- Deleted activities are kept so their logs still show the activity name.
+ALTER TABLE user_activities ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+-- +goose Down
+DELETE FROM user_activities WHERE deleted_at IS NOT NULL;
+ALTER TABLE user_activities DROP COLUMN deleted_at;
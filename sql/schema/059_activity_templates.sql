@@ -0,0 +1,127 @@
+-- +goose Up
+-- Curated packs have a slug and no creator, user templates are visible to
+-- others once published.
+CREATE TABLE activity_templates (
+  id UUID PRIMARY KEY NOT NULL,
+  slug TEXT UNIQUE,
+  name VARCHAR(255) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  created_by UUID REFERENCES users(id) ON DELETE CASCADE,
+  published_at TIMESTAMP WITH TIME ZONE,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE activity_template_items (
+  id UUID PRIMARY KEY NOT NULL,
+  template_id UUID NOT NULL REFERENCES activity_templates(id) ON DELETE CASCADE,
+  name VARCHAR(255) NOT NULL,
+  points INT NOT NULL,
+  scoring_model TEXT NOT NULL DEFAULT 'per_hour'
+    CHECK (scoring_model IN ('per_hour', 'per_session', 'per_hour_capped', 'diminishing')),
+  scoring_daily_cap INT CHECK (scoring_daily_cap > 0),
+  scoring_threshold_minutes INT CHECK (scoring_threshold_minutes > 0),
+  category TEXT NOT NULL DEFAULT '',
+  tags TEXT[] NOT NULL DEFAULT '{}',
+  icon TEXT NOT NULL DEFAULT '',
+  colour TEXT NOT NULL DEFAULT '',
+  CONSTRAINT activity_template_items_scoring_check CHECK (
+    (scoring_model <> 'per_hour_capped' OR scoring_daily_cap IS NOT NULL)
+    AND (scoring_model <> 'diminishing' OR scoring_threshold_minutes IS NOT NULL)
+  ),
+  CONSTRAINT unique_template_item_name UNIQUE (template_id, name)
+);
+
+CREATE INDEX activity_templates_created_by_idx ON activity_templates (created_by);
+
+WITH template AS (
+  INSERT INTO activity_templates (id, slug, name, description, published_at)
+  VALUES (gen_random_uuid(), 'starter', 'Starter', 'A mix of everyday activities to get going with', NOW())
+  RETURNING id
+)
+INSERT INTO activity_template_items (id, template_id, name, points, category, scoring_model, scoring_daily_cap, scoring_threshold_minutes, tags)
+SELECT gen_random_uuid(), template.id, item.name, item.points, item.category, item.scoring_model, item.scoring_daily_cap, item.scoring_threshold_minutes, item.tags
+FROM template, (VALUES
+  ('Learning', 10, 'Learning', 'per_hour', NULL::INT, NULL::INT, '{}'::TEXT[]),
+  ('Exercise', 8, 'Health', 'per_hour', NULL, NULL, '{}'),
+  ('Meditation', 6, 'Health', 'per_hour', NULL, NULL, '{}'),
+  ('Reading', 4, 'Learning', 'per_hour', NULL, NULL, '{}'),
+  ('Household Chores', 2, 'Chores', 'per_hour', NULL, NULL, '{}'),
+  ('Watching Series', -2, 'Leisure', 'per_hour', NULL, NULL, '{}'),
+  ('Watching TV', -4, 'Leisure', 'per_hour', NULL, NULL, '{}'),
+  ('Gaming', -6, 'Leisure', 'per_hour', NULL, NULL, '{}'),
+  ('Social Media Scrolling', -8, 'Leisure', 'per_hour', NULL, NULL, '{}'),
+  ('Watching adult websites', -10, 'Leisure', 'per_hour', NULL, NULL, '{}')
+) AS item(name, points, category, scoring_model, scoring_daily_cap, scoring_threshold_minutes, tags);
+
+WITH template AS (
+  INSERT INTO activity_templates (id, slug, name, description, published_at)
+  VALUES (gen_random_uuid(), 'student', 'Student', 'Lectures, studying and keeping distractions in check', NOW())
+  RETURNING id
+)
+INSERT INTO activity_template_items (id, template_id, name, points, category, scoring_model, scoring_daily_cap, scoring_threshold_minutes, tags)
+SELECT gen_random_uuid(), template.id, item.name, item.points, item.category, item.scoring_model, item.scoring_daily_cap, item.scoring_threshold_minutes, item.tags
+FROM template, (VALUES
+  ('Attending Lectures', 8, 'Learning', 'per_hour', NULL::INT, NULL::INT, '{school}'::TEXT[]),
+  ('Studying', 10, 'Learning', 'diminishing', NULL, 240, '{school,focus}'),
+  ('Homework', 8, 'Learning', 'per_hour', NULL, NULL, '{school}'),
+  ('Reading', 4, 'Learning', 'per_hour', NULL, NULL, '{}'),
+  ('Exercise', 6, 'Health', 'per_hour', NULL, NULL, '{}'),
+  ('Gaming', -4, 'Leisure', 'per_hour', NULL, NULL, '{screen}'),
+  ('Social Media Scrolling', -6, 'Leisure', 'per_hour', NULL, NULL, '{screen}')
+) AS item(name, points, category, scoring_model, scoring_daily_cap, scoring_threshold_minutes, tags);
+
+WITH template AS (
+  INSERT INTO activity_templates (id, slug, name, description, published_at)
+  VALUES (gen_random_uuid(), 'developer', 'Developer', 'Deep work, learning and the odd meeting', NOW())
+  RETURNING id
+)
+INSERT INTO activity_template_items (id, template_id, name, points, category, scoring_model, scoring_daily_cap, scoring_threshold_minutes, tags)
+SELECT gen_random_uuid(), template.id, item.name, item.points, item.category, item.scoring_model, item.scoring_daily_cap, item.scoring_threshold_minutes, item.tags
+FROM template, (VALUES
+  ('Deep Work', 10, 'Work', 'diminishing', NULL::INT, 240::INT, '{coding,focus}'::TEXT[]),
+  ('Code Review', 6, 'Work', 'per_hour', NULL, NULL, '{coding}'),
+  ('Side Project', 8, 'Work', 'per_hour', NULL, NULL, '{coding}'),
+  ('Learning New Tech', 8, 'Learning', 'per_hour', NULL, NULL, '{}'),
+  ('Meetings', 3, 'Work', 'per_hour', NULL, NULL, '{}'),
+  ('Exercise', 6, 'Health', 'per_hour', NULL, NULL, '{}'),
+  ('Doomscrolling', -6, 'Leisure', 'per_hour', NULL, NULL, '{screen}')
+) AS item(name, points, category, scoring_model, scoring_daily_cap, scoring_threshold_minutes, tags);
+
+WITH template AS (
+  INSERT INTO activity_templates (id, slug, name, description, published_at)
+  VALUES (gen_random_uuid(), 'fitness', 'Fitness', 'Training, recovery and eating well', NOW())
+  RETURNING id
+)
+INSERT INTO activity_template_items (id, template_id, name, points, category, scoring_model, scoring_daily_cap, scoring_threshold_minutes, tags)
+SELECT gen_random_uuid(), template.id, item.name, item.points, item.category, item.scoring_model, item.scoring_daily_cap, item.scoring_threshold_minutes, item.tags
+FROM template, (VALUES
+  ('Running', 10, 'Health', 'per_hour_capped', 30::INT, NULL::INT, '{cardio}'::TEXT[]),
+  ('Strength Training', 10, 'Health', 'per_hour_capped', 30, NULL, '{strength}'),
+  ('Yoga', 6, 'Health', 'per_hour', NULL, NULL, '{mobility}'),
+  ('Stretching', 3, 'Health', 'per_session', NULL, NULL, '{mobility}'),
+  ('Walking', 4, 'Health', 'per_hour', NULL, NULL, '{cardio}'),
+  ('Meal Prep', 3, 'Health', 'per_session', NULL, NULL, '{nutrition}'),
+  ('Fast Food', -4, 'Health', 'per_session', NULL, NULL, '{nutrition}')
+) AS item(name, points, category, scoring_model, scoring_daily_cap, scoring_threshold_minutes, tags);
+
+WITH template AS (
+  INSERT INTO activity_templates (id, slug, name, description, published_at)
+  VALUES (gen_random_uuid(), 'digital-detox', 'Digital Detox', 'Less screen time and more time offline', NOW())
+  RETURNING id
+)
+INSERT INTO activity_template_items (id, template_id, name, points, category, scoring_model, scoring_daily_cap, scoring_threshold_minutes, tags)
+SELECT gen_random_uuid(), template.id, item.name, item.points, item.category, item.scoring_model, item.scoring_daily_cap, item.scoring_threshold_minutes, item.tags
+FROM template, (VALUES
+  ('Reading a Book', 6, 'Learning', 'per_hour', NULL::INT, NULL::INT, '{offline}'::TEXT[]),
+  ('Walking Outside', 6, 'Health', 'per_hour', NULL, NULL, '{offline}'),
+  ('Meditation', 5, 'Health', 'per_session', NULL, NULL, '{offline}'),
+  ('Time With Friends', 6, 'Social', 'per_hour', NULL, NULL, '{offline}'),
+  ('Social Media Scrolling', -10, 'Leisure', 'per_hour_capped', 40, NULL, '{screen}'),
+  ('Watching TV', -6, 'Leisure', 'per_hour', NULL, NULL, '{screen}'),
+  ('Gaming', -6, 'Leisure', 'per_hour', NULL, NULL, '{screen}')
+) AS item(name, points, category, scoring_model, scoring_daily_cap, scoring_threshold_minutes, tags);
+
+-- +goose Down
+DROP TABLE activity_template_items;
+DROP TABLE activity_templates;
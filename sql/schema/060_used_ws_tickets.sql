@@ -0,0 +1,10 @@
+-- +goose Up
+-- Websocket tickets that already opened a connection, kept until they expire
+-- so a ticket cannot be used twice.
+CREATE TABLE used_ws_tickets (
+  jti UUID PRIMARY KEY NOT NULL,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE used_ws_tickets;
@@ -29,6 +29,7 @@ func (apiCfg *apiConfig) createSuggestFeature(w http.ResponseWriter, r *http.Req
 		Title:       params.Title,
 		Description: params.Description,
 		Username:    user.Username,
+		UserID:      uuid.NullUUID{UUID: user.ID, Valid: true},
 	})
 
 	if err != nil {
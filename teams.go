@@ -1,14 +1,18 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/mrdkvcs/go-base-backend/internal/database"
 	"net/http"
+	"strings"
 	"time"
 )
 
+const teamOwnerRole = "owner"
+
 func (apiCfg *apiConfig) CreateTeam(w http.ResponseWriter, r *http.Request, user database.User) {
 	type parameters struct {
 		Name         string `json:"team_name"`
@@ -37,7 +41,7 @@ func (apiCfg *apiConfig) CreateTeam(w http.ResponseWriter, r *http.Request, user
 	}
 	teamrole, err := apiCfg.DB.SetTeamRole(r.Context(), database.SetTeamRoleParams{
 		ID:       uuid.New(),
-		RoleName: "owner",
+		RoleName: teamOwnerRole,
 		TeamID:   team.ID,
 	})
 	if err != nil {
@@ -92,18 +96,25 @@ func (apiCfg *apiConfig) GetTeamActivities(w http.ResponseWriter, r *http.Reques
 		respondWithError(w, 400, fmt.Sprintf("Error in parsing uuid: %s", err))
 		return
 	}
-	teamactivities, err := apiCfg.DB.GetTeamActivities(r.Context(), parsedTeamUUID)
+	teamactivities, err := apiCfg.DB.GetTeamActivities(r.Context(), database.GetTeamActivitiesParams{
+		TeamID:          parsedTeamUUID,
+		IncludeArchived: r.URL.Query().Get("archived") == "true",
+	})
 	if err != nil {
 		respondWithError(w, 500, fmt.Sprintf("Error in getting  team activites: %s", err))
 		return
 	}
-	respondWithJson(w, 200, databaseTeamActivityToTeamActivity(teamactivities))
+	teamActivityRoles, err := apiCfg.DB.GetTeamActivityRoles(r.Context(), parsedTeamUUID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team activity roles: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseTeamActivityToTeamActivity(teamactivities, teamActivityRoles))
 }
 
-func (apiCfg *apiConfig) SetTeamRole(w http.ResponseWriter, r *http.Request) {
+func (apiCfg *apiConfig) SetTeamRole(w http.ResponseWriter, r *http.Request, user database.User) {
 	type parameters struct {
 		RoleName string `json:"role_name"`
-		TeamID   string `json:"team_id"`
 	}
 	params := parameters{}
 	decoder := json.NewDecoder(r.Body)
@@ -112,20 +123,132 @@ func (apiCfg *apiConfig) SetTeamRole(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
 		return
 	}
-	parsedTeamUUID, err := uuid.Parse(params.TeamID)
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error in parsing uuid: %s", err))
 		return
 	}
-	_, err = apiCfg.DB.SetTeamRole(r.Context(), database.SetTeamRoleParams{
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	roleName := strings.TrimSpace(params.RoleName)
+	if roleName == "" || roleName == teamOwnerRole {
+		respondWithError(w, 400, "Invalid role name")
+		return
+	}
+	teamRole, err := apiCfg.DB.SetTeamRole(r.Context(), database.SetTeamRoleParams{
 		ID:       uuid.New(),
-		RoleName: params.RoleName,
+		RoleName: roleName,
 		TeamID:   parsedTeamUUID,
 	})
 	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, 409, "A role with this name already exists in the team")
+			return
+		}
 		respondWithError(w, 500, fmt.Sprintf("Error in creating team roles: %s", err))
 		return
 	}
+	respondWithJson(w, 200, TeamRole{ID: teamRole.ID, RoleName: teamRole.RoleName})
+}
+
+func (apiCfg *apiConfig) RenameTeamRole(w http.ResponseWriter, r *http.Request, user database.User) {
+	type parameters struct {
+		RoleName string `json:"role_name"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing uuid: %s", err))
+		return
+	}
+	parsedRoleUUID, err := uuid.Parse(r.PathValue("roleid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing role uuid: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	roleName := strings.TrimSpace(params.RoleName)
+	if roleName == "" || roleName == teamOwnerRole {
+		respondWithError(w, 400, "Invalid role name")
+		return
+	}
+	teamRole, ok := apiCfg.getEditableTeamRole(w, r, parsedRoleUUID, parsedTeamUUID)
+	if !ok {
+		return
+	}
+	err = apiCfg.DB.RenameTeamRole(r.Context(), database.RenameTeamRoleParams{
+		RoleName: roleName,
+		ID:       teamRole.ID,
+		TeamID:   parsedTeamUUID,
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			respondWithError(w, 409, "A role with this name already exists in the team")
+			return
+		}
+		respondWithError(w, 500, fmt.Sprintf("Error in renaming team role: %s", err))
+		return
+	}
+	respondWithJson(w, 200, TeamRole{ID: teamRole.ID, RoleName: roleName})
+}
+
+func (apiCfg *apiConfig) DeleteTeamRole(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing uuid: %s", err))
+		return
+	}
+	parsedRoleUUID, err := uuid.Parse(r.PathValue("roleid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing role uuid: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	teamRole, ok := apiCfg.getEditableTeamRole(w, r, parsedRoleUUID, parsedTeamUUID)
+	if !ok {
+		return
+	}
+	err = apiCfg.DB.DeleteTeamRole(r.Context(), database.DeleteTeamRoleParams{
+		ID:     teamRole.ID,
+		TeamID: parsedTeamUUID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in deleting team role: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Team role deleted successfully")
+}
+
+// getEditableTeamRole looks up a role of the team and rejects the owner role,
+// which can only change hands through an ownership transfer.
+func (apiCfg *apiConfig) getEditableTeamRole(w http.ResponseWriter, r *http.Request, roleID uuid.UUID, teamID uuid.UUID) (database.TeamRole, bool) {
+	teamRole, err := apiCfg.DB.GetTeamRole(r.Context(), database.GetTeamRoleParams{
+		ID:     roleID,
+		TeamID: teamID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Team role not found")
+		return database.TeamRole{}, false
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team role: %s", err))
+		return database.TeamRole{}, false
+	}
+	if teamRole.RoleName == teamOwnerRole {
+		respondWithError(w, 400, "The owner role cannot be modified")
+		return database.TeamRole{}, false
+	}
+	return teamRole, true
 }
 
 func (apiCfg *apiConfig) GetTeamRoles(w http.ResponseWriter, r *http.Request) {
@@ -152,40 +275,254 @@ func (apiCfg *apiConfig) GetTeamRoles(w http.ResponseWriter, r *http.Request) {
 	respondWithJson(w, 200, databaseTeamRolesToTeamRoles(teamRoles))
 }
 
-func (apiCfg *apiConfig) SetTeamActivity(w http.ResponseWriter, r *http.Request) {
+type teamActivityParameters struct {
+	ActivityName    string           `json:"activity_name"`
+	ActivityPoints  int32            `json:"activity_points"`
+	ActivityRoleIDs []string         `json:"activity_role_ids"`
+	RolePoints      map[string]int32 `json:"role_points"`
+}
+
+func (apiCfg *apiConfig) SetTeamActivity(w http.ResponseWriter, r *http.Request, user database.User) {
 	teamId := r.PathValue("teamid")
 	parsedTeamUUID, err := uuid.Parse(teamId)
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error in parsing uuid: %s", err))
 		return
 	}
-	type parameters struct {
-		ActivityName   string   `json:"activity_name"`
-		ActivityPoints int32    `json:"activity_points"`
-		ActivityRoles  []string `json:"activity_roles"`
-	}
-	params := parameters{}
+	params := teamActivityParameters{}
 	decoder := json.NewDecoder(r.Body)
 	err = decoder.Decode(&params)
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
 		return
 	}
-	err = apiCfg.DB.SetTeamActivity(r.Context(), database.SetTeamActivityParams{
-		ID:            uuid.New(),
-		TeamID:        parsedTeamUUID,
-		ActivityName:  params.ActivityName,
-		Points:        params.ActivityPoints,
-		CreatedAt:     time.Now().UTC(),
-		UpdatedAt:     time.Now().UTC(),
-		ActivityRoles: params.ActivityRoles,
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	activityRoles, ok := apiCfg.parseTeamActivityRoles(w, r, parsedTeamUUID, params)
+	if !ok {
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in starting transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	teamActivityID := uuid.New()
+	err = qtx.SetTeamActivity(r.Context(), database.SetTeamActivityParams{
+		ID:           teamActivityID,
+		TeamID:       parsedTeamUUID,
+		ActivityName: params.ActivityName,
+		Points:       params.ActivityPoints,
+		CreatedAt:    time.Now().UTC(),
+		UpdatedAt:    time.Now().UTC(),
 	})
 	if err != nil {
-		respondWithError(w, 500, fmt.Sprintf("Error in creating team roles: %s", err))
+		respondWithError(w, 500, fmt.Sprintf("Error in creating team activity: %s", err))
+		return
+	}
+	for _, activityRole := range activityRoles {
+		activityRole.TeamActivityID = teamActivityID
+		err = qtx.SetTeamActivityRole(r.Context(), activityRole)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error in setting team activity roles: %s", err))
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
 		return
 	}
 }
 
+func (apiCfg *apiConfig) UpdateTeamActivity(w http.ResponseWriter, r *http.Request, user database.User) {
+	teamActivity, ok := apiCfg.getManagedTeamActivity(w, r, user)
+	if !ok {
+		return
+	}
+	params := teamActivityParameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
+	activityRoles, ok := apiCfg.parseTeamActivityRoles(w, r, teamActivity.TeamID, params)
+	if !ok {
+		return
+	}
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in starting transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	err = qtx.UpdateTeamActivity(r.Context(), database.UpdateTeamActivityParams{
+		ActivityName: params.ActivityName,
+		Points:       params.ActivityPoints,
+		ID:           teamActivity.ID,
+		TeamID:       teamActivity.TeamID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in updating team activity: %s", err))
+		return
+	}
+	err = qtx.DeleteTeamActivityRoles(r.Context(), teamActivity.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in updating team activity roles: %s", err))
+		return
+	}
+	for _, activityRole := range activityRoles {
+		activityRole.TeamActivityID = teamActivity.ID
+		err = qtx.SetTeamActivityRole(r.Context(), activityRole)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error in setting team activity roles: %s", err))
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Team activity updated successfully")
+}
+
+// DeleteTeamActivity removes the activity for good. Team activity logs store
+// the activity name and points themselves, so history is not affected.
+func (apiCfg *apiConfig) DeleteTeamActivity(w http.ResponseWriter, r *http.Request, user database.User) {
+	teamActivity, ok := apiCfg.getManagedTeamActivity(w, r, user)
+	if !ok {
+		return
+	}
+	err := apiCfg.DB.DeleteTeamActivity(r.Context(), database.DeleteTeamActivityParams{
+		ID:     teamActivity.ID,
+		TeamID: teamActivity.TeamID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in deleting team activity: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Team activity deleted successfully")
+}
+
+// ArchiveTeamActivity hides the activity from members without deleting it.
+func (apiCfg *apiConfig) ArchiveTeamActivity(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.setTeamActivityArchived(w, r, user, true)
+}
+
+func (apiCfg *apiConfig) UnarchiveTeamActivity(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.setTeamActivityArchived(w, r, user, false)
+}
+
+func (apiCfg *apiConfig) setTeamActivityArchived(w http.ResponseWriter, r *http.Request, user database.User, archived bool) {
+	teamActivity, ok := apiCfg.getManagedTeamActivity(w, r, user)
+	if !ok {
+		return
+	}
+	err := apiCfg.DB.SetTeamActivityArchivedAt(r.Context(), database.SetTeamActivityArchivedAtParams{
+		ArchivedAt: sql.NullTime{Time: time.Now().UTC(), Valid: archived},
+		ID:         teamActivity.ID,
+		TeamID:     teamActivity.TeamID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in archiving team activity: %s", err))
+		return
+	}
+	if archived {
+		respondWithJson(w, 200, "Team activity archived successfully")
+		return
+	}
+	respondWithJson(w, 200, "Team activity restored successfully")
+}
+
+// getManagedTeamActivity resolves the {teamid} and {activityid} path values to
+// an activity of that team, provided the user owns the team.
+func (apiCfg *apiConfig) getManagedTeamActivity(w http.ResponseWriter, r *http.Request, user database.User) (database.TeamActivity, bool) {
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing uuid: %s", err))
+		return database.TeamActivity{}, false
+	}
+	parsedActivityUUID, err := uuid.Parse(r.PathValue("activityid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing activity uuid: %s", err))
+		return database.TeamActivity{}, false
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return database.TeamActivity{}, false
+	}
+	teamActivity, err := apiCfg.DB.GetTeamActivity(r.Context(), database.GetTeamActivityParams{
+		ID:     parsedActivityUUID,
+		TeamID: parsedTeamUUID,
+	})
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Team activity not found")
+		return database.TeamActivity{}, false
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team activity: %s", err))
+		return database.TeamActivity{}, false
+	}
+	return teamActivity, true
+}
+
+// parseTeamRoleIDs parses the given role ids and checks that each of them is
+// an assignable (non-owner) role of the team.
+func (apiCfg *apiConfig) parseTeamRoleIDs(w http.ResponseWriter, r *http.Request, teamID uuid.UUID, ids []string) ([]uuid.UUID, bool) {
+	roleIDs := []uuid.UUID{}
+	for _, id := range ids {
+		roleUUID, err := uuid.Parse(id)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Error in parsing role uuid: %s", err))
+			return nil, false
+		}
+		_, ok := apiCfg.getEditableTeamRole(w, r, roleUUID, teamID)
+		if !ok {
+			return nil, false
+		}
+		roleIDs = append(roleIDs, roleUUID)
+	}
+	return roleIDs, true
+}
+
+// parseTeamActivityRoles validates the roles an activity is available to and
+// their optional point overrides. A member holding several roles with
+// overrides earns the highest of them; members without an override earn the
+// activity's base points.
+func (apiCfg *apiConfig) parseTeamActivityRoles(w http.ResponseWriter, r *http.Request, teamID uuid.UUID, params teamActivityParameters) ([]database.SetTeamActivityRoleParams, bool) {
+	roleIDs, ok := apiCfg.parseTeamRoleIDs(w, r, teamID, params.ActivityRoleIDs)
+	if !ok {
+		return nil, false
+	}
+	activityRoles := []database.SetTeamActivityRoleParams{}
+	for _, roleID := range roleIDs {
+		activityRoles = append(activityRoles, database.SetTeamActivityRoleParams{RoleID: roleID})
+	}
+	for roleId, points := range params.RolePoints {
+		roleUUID, err := uuid.Parse(roleId)
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Error in parsing role uuid: %s", err))
+			return nil, false
+		}
+		found := false
+		for i := range activityRoles {
+			if activityRoles[i].RoleID == roleUUID {
+				activityRoles[i].Points = sql.NullInt32{Int32: points, Valid: true}
+				found = true
+			}
+		}
+		if !found {
+			respondWithError(w, 400, "Point overrides can only be set for the roles of the activity")
+			return nil, false
+		}
+	}
+	return activityRoles, true
+}
+
 func (apiCfg *apiConfig) IsUserTeamOwner(w http.ResponseWriter, r *http.Request, user database.User) {
 	teamid := r.PathValue("teamid")
 	parsedTeamUUID, err := uuid.Parse(teamid)
@@ -221,3 +558,39 @@ func (apiCfg *apiConfig) GetUserTeamActivities(w http.ResponseWriter, r *http.Re
 	}
 	respondWithJson(w, 200, databaseUserTeamActivityToUserTeamActivity(userTeamActivities))
 }
+
+func (apiCfg *apiConfig) DeleteTeam(w http.ResponseWriter, r *http.Request, user database.User) {
+	teamid := r.PathValue("teamid")
+	parsedTeamUUID, err := uuid.Parse(teamid)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing uuid: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	err = apiCfg.DB.DeleteTeam(r.Context(), parsedTeamUUID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in deleting team: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Team deleted successfully")
+}
+
+// requireTeamOwner responds with 403 and reports false unless the user is the
+// owner of the team.
+func (apiCfg *apiConfig) requireTeamOwner(w http.ResponseWriter, r *http.Request, teamID uuid.UUID, user database.User) bool {
+	isOwner, err := apiCfg.DB.IsTeamOwner(r.Context(), database.IsTeamOwnerParams{
+		TeamID: teamID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in getting team ownership: %s", err))
+		return false
+	}
+	if !isOwner {
+		respondWithError(w, 403, "Only the team owner can do this")
+		return false
+	}
+	return true
+}
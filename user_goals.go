@@ -6,13 +6,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"net/smtp"
-	"os"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 	"github.com/mrdkvcs/go-base-backend/internal/database"
 )
 
@@ -88,36 +85,5 @@ func (apiCfg *apiConfig) sendUserReminder(userEmail string, userId uuid.UUID) {
 		log.Println("Error getting user ' s dailyPoints")
 		return
 	}
-	sendEmail(userEmail, dailyPoints.GoalPoints, dailyPoints.GoalPoints)
-}
-
-func sendEmail(userEmail string, goalPoints, totalPoints int32) {
-	err := godotenv.Load(".env")
-	if err != nil {
-		fmt.Println("Error loading .env file")
-		return
-	}
-	from := os.Getenv("SMTP_USER")
-	if from == "" {
-		fmt.Println("Couldnt get username from .env file")
-	}
-	password := os.Getenv("SMTP_PASSWORD")
-	if password == "" {
-		fmt.Println("Couldnt get password from .env file")
-	}
-	body := ""
-	to := userEmail
-	subject := "Productivity Goal Reminder"
-	body = fmt.Sprintf("Hello,\n\nYou are currently  below your productivity goal for the day.\nYour total daily points : %d\nYour goal points: %d\n\nKeep pushing to reach your target!\n\nBest regards,\nYour Productivity Tracker",
-		totalPoints, goalPoints)
-	message := fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\n\n%s", from, to, subject, body)
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
-	auth := smtp.PlainAuth("", from, password, smtpHost)
-	err = smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{to}, []byte(message))
-	if err != nil {
-		fmt.Printf("error sending email: %v", err)
-	} else {
-		fmt.Println("Email sent succesfully")
-	}
+	sendReminderEmail(userEmail, dailyPoints.GoalPoints, dailyPoints.GoalPoints)
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
@@ -9,120 +10,61 @@ import (
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 	"github.com/lib/pq"
 	"github.com/mrdkvcs/go-base-backend/internal/database"
 	"golang.org/x/crypto/bcrypt"
+	"log"
 	"net/http"
-	"net/smtp"
-	"os"
+	"strings"
 	"time"
+	"unicode"
 )
 
 type Claims struct {
-	UserID   uuid.UUID `json:"user_id"`
-	Email    string    `json:"email"`
-	Username string    `json:"username"`
-	IsGoogle bool      `json:"is_google"`
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Username  string    `json:"username"`
+	IsGoogle  bool      `json:"is_google"`
+	SessionID uuid.UUID `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-type jwtTokenResponse struct {
-	Token string `json:"token"`
-}
-
-func (apiCfg *apiConfig) googleCallback(w http.ResponseWriter, r *http.Request) {
-
-	type parameters struct {
-		Code string `json:"code"`
-	}
-
-	params := parameters{}
-	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, 400, fmt.Sprintf("Couldnt decode request body: %s", err))
-		return
-	}
-
-	token, err := oauthConfig.Exchange(r.Context(), params.Code)
-	if err != nil {
-		respondWithError(w, 400, "Couldnt exchange code for token")
-		return
-	}
-
-	client := oauthConfig.Client(r.Context(), token)
-	response, err := client.Get("https://www.googleapis.com/oauth2/v3/userinfo")
-	var userInfo map[string]interface{}
-	defer response.Body.Close()
-	err = json.NewDecoder(response.Body).Decode(&userInfo)
-	if err != nil {
-		respondWithError(w, 400, "Couldnt parse user info")
-		return
-	}
-
-	googleId := userInfo["sub"].(string)
-	userEmail := userInfo["email"].(string)
-	userName := userInfo["name"].(string)
-
-	existingUser, err := apiCfg.DB.GetUserByGoogleId(r.Context(), sql.NullString{String: googleId, Valid: true})
-	if err == sql.ErrNoRows {
-		user, err := apiCfg.DB.CreateUser(r.Context(), database.CreateUserParams{
-			ID:           uuid.New(),
-			CreatedAt:    time.Now(),
-			UpdatedAt:    time.Now(),
-			Username:     userName,
-			Email:        userEmail,
-			PasswordHash: sql.NullString{String: "", Valid: false},
-			GoogleID:     sql.NullString{String: googleId, Valid: true},
-		})
-		if err != nil {
-			respondWithError(w, 400, fmt.Sprintf("Couldnt create google user: %s", err))
-			return
-		}
+const (
+	passwordResetTTL  = 20 * time.Minute
+	minPasswordLength = 8
+)
 
-		jwtToken, err := generateJWTForGoogleUser(user.ID, user.Email, user.Username)
-		if err != nil {
-			respondWithError(w, 400, fmt.Sprintf("Error in generating JWT token for google user: %s", err))
-			return
-		}
-		respondWithJson(w, 200, jwtTokenResponse{Token: jwtToken})
-		return
-	} else if err != nil {
-		respondWithError(w, 500, fmt.Sprintf("DB Error: Couldnt get user by google id: %s", err))
-		return
-	}
-	jwtToken, err := generateJWTForGoogleUser(existingUser.ID, existingUser.Email, existingUser.Username)
-	if err != nil {
-		respondWithError(w, 500, fmt.Sprintf("Error in generating JWT token for google user: %s", err))
-		return
-	}
-	respondWithJson(w, 200, jwtTokenResponse{Token: jwtToken})
+type jwtTokenResponse struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
 
-func generateJWTForGoogleUser(userId uuid.UUID, userEmail string, userName string) (string, error) {
+func generateJWTForGoogleUser(userId uuid.UUID, userEmail string, userName string, sessionID uuid.UUID) (string, error) {
 	claims := Claims{
-		UserID:   userId,
-		Email:    userEmail,
-		Username: userName,
-		IsGoogle: true,
+		UserID:    userId,
+		Email:     userEmail,
+		Username:  userName,
+		IsGoogle:  true,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(jwtSecret))
 }
-func generateJWTForRegularUser(userId uuid.UUID, userEmail string, userName string) (string, error) {
+func generateJWTForRegularUser(userId uuid.UUID, userEmail string, userName string, sessionID uuid.UUID) (string, error) {
 	claims := Claims{
-		UserID:   userId,
-		Email:    userEmail,
-		Username: userName,
-		IsGoogle: false,
+		UserID:    userId,
+		Email:     userEmail,
+		Username:  userName,
+		IsGoogle:  false,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -131,10 +73,11 @@ func generateJWTForRegularUser(userId uuid.UUID, userEmail string, userName stri
 
 func (apiCfg *apiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
-		Username   string `json:"username"`
-		Password   string `json:"password"`
-		Email      string `json:"email"`
-		SetDefault string `json:"set_default"`
+		Username    string `json:"username"`
+		Password    string `json:"password"`
+		Email       string `json:"email"`
+		SetDefault  string `json:"set_default"`
+		StarterPack string `json:"starter_pack"`
 	}
 	params := parameters{}
 	decoder := json.NewDecoder(r.Body)
@@ -143,6 +86,21 @@ func (apiCfg *apiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, 400, fmt.Sprintf("Couldnt parse json: %s", err))
 		return
 	}
+	// set_default is kept for older clients and stands for the starter pack.
+	if params.StarterPack == "" && params.SetDefault == "true" {
+		params.StarterPack = starterActivityTemplate
+	}
+	var starterPack database.ActivityTemplate
+	if params.StarterPack != "" {
+		starterPack, err = apiCfg.DB.GetCuratedActivityTemplate(r.Context(), sql.NullString{String: params.StarterPack, Valid: true})
+		if err == sql.ErrNoRows {
+			respondWithError(w, 400, fmt.Sprintf("Unknown starter pack: %s", params.StarterPack))
+			return
+		} else if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error getting starter pack: %s", err))
+			return
+		}
+	}
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(params.Password), bcrypt.DefaultCost)
 	if err != nil {
 		respondWithError(w, 400, "Couldnt hash password")
@@ -155,7 +113,6 @@ func (apiCfg *apiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 		Username:     params.Username,
 		PasswordHash: sql.NullString{String: string(hashedPassword), Valid: true},
 		Email:        params.Email,
-		GoogleID:     sql.NullString{String: "", Valid: false},
 	})
 	if err != nil {
 		if pqErr, ok := err.(*pq.Error); ok {
@@ -173,19 +130,28 @@ func (apiCfg *apiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, 400, fmt.Sprintf("Unexpected error during registration"))
 		return
 	}
-	if params.SetDefault == "true" {
-		err := apiCfg.DB.SetDefaultActivities(r.Context(), user.ID)
+	err = apiCfg.sendEmailVerification(r.Context(), user)
+	if err != nil {
+		log.Printf("Error sending verification email to user %s: %s", user.ID, err)
+	}
+	if params.StarterPack != "" {
+		dbItems, err := apiCfg.DB.GetActivityTemplateItems(r.Context(), []uuid.UUID{starterPack.ID})
+		if err != nil {
+			respondWithError(w, 400, fmt.Sprintf("Couldnt set default activities: %s", err))
+			return
+		}
+		_, err = importActivityTemplate(r.Context(), apiCfg.DB, user.ID, dbItems)
 		if err != nil {
 			respondWithError(w, 400, fmt.Sprintf("Couldnt set default activities: %s", err))
 			return
 		}
 	}
-	jwtToken, err := generateJWTForRegularUser(user.ID, user.Email, user.Username)
+	tokens, err := apiCfg.createSession(r, user)
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error in generating JWT token for user: %s", err))
 		return
 	}
-	respondWithJson(w, 200, jwtTokenResponse{Token: jwtToken})
+	respondWithJson(w, 200, tokens)
 }
 
 func (apiCfg *apiConfig) LogInUser(w http.ResponseWriter, r *http.Request) {
@@ -208,17 +174,44 @@ func (apiCfg *apiConfig) LogInUser(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, 400, fmt.Sprintf("Error when logging in : %s", err))
 		return
 	}
+	if !apiCfg.checkLoginLockout(w, r, user) {
+		return
+	}
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash.String), []byte(params.Password))
 	if err != nil {
+		err = apiCfg.recordLoginFailure(r, user)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error when logging in : %s", err))
+			return
+		}
 		respondWithError(w, 401, "Error when logging in : Invalid email or password")
 		return
 	}
-	jwtToken, err := generateJWTForRegularUser(user.ID, user.Email, user.Username)
+	_, mfaEnabled, err := apiCfg.getConfirmedTotp(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error when logging in : %s", err))
+		return
+	}
+	if mfaEnabled {
+		mfaResponse, err := createMfaToken(user)
+		if err != nil {
+			respondWithError(w, 500, fmt.Sprintf("Error in generating mfa token for user: %s", err))
+			return
+		}
+		respondWithJson(w, 200, mfaResponse)
+		return
+	}
+	err = apiCfg.DB.ClearLoginFailures(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error when logging in : %s", err))
+		return
+	}
+	tokens, err := apiCfg.createSession(r, user)
 	if err != nil {
 		respondWithError(w, 400, fmt.Sprintf("Error in generating JWT token for user: %s", err))
 		return
 	}
-	respondWithJson(w, 200, jwtTokenResponse{Token: jwtToken})
+	respondWithJson(w, 200, tokens)
 }
 
 func generateResetToken() (string, error) {
@@ -230,36 +223,6 @@ func generateResetToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(token), nil
 }
 
-func sendResetEmail(email string, token string) error {
-	err := godotenv.Load(".env")
-	if err != nil {
-		fmt.Println("Error loading .env file")
-		return err
-	}
-	from := os.Getenv("SMTP_USER")
-	if from == "" {
-		fmt.Println("Couldnt get username from .env file")
-		return errors.New("Error getting smtp user from .env")
-	}
-	password := os.Getenv("SMTP_PASSWORD")
-	if password == "" {
-		return errors.New("Error getting smtp password from .env")
-	}
-	resetLink := fmt.Sprintf("http://localhost:5173/reset-password?token=%s", token)
-	to := email
-	body := fmt.Sprintf("Password Reset \n\n Here is your link to reset your password : " + resetLink)
-	subject := "Password reset"
-	message := fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\n\n%s", from, to, subject, body)
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
-	auth := smtp.PlainAuth("", from, password, smtpHost)
-	err = smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{to}, []byte(message))
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 func (apiCfg *apiConfig) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Email string `json:"email"`
@@ -271,30 +234,62 @@ func (apiCfg *apiConfig) ForgotPasswordHandler(w http.ResponseWriter, r *http.Re
 		respondWithError(w, 400, fmt.Sprintf("Error decoding request body :  %s", err))
 		return
 	}
-	user, err := apiCfg.DB.GetUserByEmail(r.Context(), params.Email)
+	// The lookup and the email happen in the background, so neither the
+	// response nor its timing reveal whether an account exists.
+	go apiCfg.requestPasswordReset(params.Email)
+	respondWithJson(w, 200, "If an account exists for this email , a password reset link has been sent")
+}
+
+// requestPasswordReset replaces any outstanding reset token of the account
+// with a new one and mails the link. Accounts without a password are skipped.
+func (apiCfg *apiConfig) requestPasswordReset(email string) {
+	ctx := context.Background()
+	user, err := apiCfg.DB.GetUserByEmail(ctx, email)
 	if err == sql.ErrNoRows {
-		respondWithError(w, 500, fmt.Sprintf("User does not exist with en email that you provided "))
 		return
 	} else if err != nil {
-		respondWithError(w, 500, fmt.Sprintf("Error getting user :  %s", err))
+		log.Printf("Error getting user for password reset: %s", err)
 		return
-	} else if user.GoogleID.Valid {
-		respondWithError(w, 500, "Your account is managed by google , we cant allow password requests for this account")
+	}
+	if !user.PasswordHash.Valid {
 		return
 	}
-
 	token, err := generateResetToken()
 	if err != nil {
-		respondWithError(w, 500, fmt.Sprintf("Error generating reset token:  %s", err))
+		log.Printf("Error generating reset token: %s", err)
+		return
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Error in beginning transaction: %s", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	err = qtx.DeleteUserPasswordResets(ctx, user.ID)
+	if err != nil {
+		log.Printf("Error deleting previous password resets of user %s: %s", user.ID, err)
 		return
 	}
-	err = apiCfg.DB.CreatePasswordReset(r.Context(), database.CreatePasswordResetParams{
+	err = qtx.CreatePasswordReset(ctx, database.CreatePasswordResetParams{
 		ID:        uuid.New(),
 		UserID:    user.ID,
-		Token:     token,
-		ExpiresAt: time.Now().Add(20 * time.Minute),
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
 	})
-	sendResetEmail(params.Email, token)
+	if err != nil {
+		log.Printf("Error creating password reset for user %s: %s", user.ID, err)
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error in committing transaction: %s", err)
+		return
+	}
+	err = sendResetEmail(user.Email, token)
+	if err != nil {
+		log.Printf("Error sending password reset email to user %s: %s", user.ID, err)
+	}
 }
 
 func (apiCfg *apiConfig) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
@@ -306,16 +301,12 @@ func (apiCfg *apiConfig) ResetPasswordHandler(w http.ResponseWriter, r *http.Req
 	decoder := json.NewDecoder(r.Body)
 	err := decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, 500, fmt.Sprintf("Error decoding request body:  %s", err))
+		respondWithError(w, 400, fmt.Sprintf("Error decoding request body:  %s", err))
 		return
 	}
-	passwordResetRow, err := apiCfg.DB.GetPasswordReset(r.Context(), params.Token)
+	err = validatePassword(params.NewPassword)
 	if err != nil {
-		respondWithError(w, 500, fmt.Sprintf("Invalid token , error processing password reset request"))
-		return
-	}
-	if passwordResetRow.ExpiresAt.Before(time.Now()) {
-		respondWithError(w, 500, fmt.Sprintf("Expired token , error processing password reset request"))
+		respondWithError(w, 400, err.Error())
 		return
 	}
 	hashedNewPassword, err := bcrypt.GenerateFromPassword([]byte(params.NewPassword), bcrypt.DefaultCost)
@@ -323,21 +314,75 @@ func (apiCfg *apiConfig) ResetPasswordHandler(w http.ResponseWriter, r *http.Req
 		respondWithError(w, 500, fmt.Sprintf("Error generating password hash"))
 		return
 	}
-	err = apiCfg.DB.SetNewPassword(r.Context(), database.SetNewPasswordParams{
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in beginning transaction: %s", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := apiCfg.DB.WithTx(tx)
+	passwordReset, err := qtx.ConsumePasswordReset(r.Context(), hashToken(params.Token))
+	if err == sql.ErrNoRows {
+		respondWithError(w, 400, "Invalid or expired token , error processing password reset request")
+		return
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting password reset: %s", err))
+		return
+	}
+	if passwordReset.ExpiresAt.Before(time.Now()) {
+		// Still commit, so the expired token is gone.
+		tx.Commit()
+		respondWithError(w, 400, "Invalid or expired token , error processing password reset request")
+		return
+	}
+	err = qtx.SetNewPassword(r.Context(), database.SetNewPasswordParams{
 		PasswordHash: sql.NullString{String: string(hashedNewPassword), Valid: true},
-		Email:        passwordResetRow.Email,
+		ID:           passwordReset.UserID,
 	})
 	if err != nil {
 		respondWithError(w, 500, fmt.Sprintf("Error setting new password for user"))
 		return
 	}
-	err = apiCfg.DB.DeletePasswordReset(r.Context(), params.Token)
+	err = qtx.DeleteUserPasswordResets(r.Context(), passwordReset.UserID)
 	if err != nil {
-		fmt.Printf("Error deleting password reset request for token : %s ", params.Token)
+		respondWithError(w, 500, fmt.Sprintf("Error deleting password resets: %s", err))
+		return
+	}
+	err = qtx.RevokeUserSessions(r.Context(), passwordReset.UserID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error revoking sessions: %s", err))
+		return
+	}
+	err = qtx.SetUserTokensValidAfter(r.Context(), passwordReset.UserID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error revoking tokens: %s", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error in committing transaction: %s", err))
+		return
 	}
 	respondWithJson(w, 200, "New password for the account set successfully!")
 }
 
+// validatePassword enforces the password policy. bcrypt ignores everything
+// after 72 bytes, so longer passwords are refused instead of truncated.
+func validatePassword(password string) error {
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("Password must be at least %d characters long", minPasswordLength)
+	}
+	if len(password) > 72 {
+		return errors.New("Password cannot be longer than 72 bytes")
+	}
+	hasLetter := strings.IndexFunc(password, unicode.IsLetter) >= 0
+	hasDigit := strings.IndexFunc(password, unicode.IsDigit) >= 0
+	if !hasLetter || !hasDigit {
+		return errors.New("Password must contain at least one letter and one digit")
+	}
+	return nil
+}
+
 func (apiCfg *apiConfig) GetUserByEmail(w http.ResponseWriter, r *http.Request, user database.User) {
 	respondWithJson(w, 200, databaseUserToUser(user))
 }
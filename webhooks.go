@@ -0,0 +1,513 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	WebhookActivityLogged   = "activity.logged"
+	WebhookGoalCompleted    = "goal.completed"
+	WebhookStreakBroken     = "streak.broken"
+	WebhookTeamMemberJoined = "team.member_joined"
+	WebhookTest             = "webhook.test"
+)
+
+const (
+	webhookMaxAttempts  = 8
+	webhookRetryBase    = 30 * time.Second
+	webhookRetryMax     = 6 * time.Hour
+	webhookBatchSize    = 20
+	webhookPollInterval = 5 * time.Second
+	webhookTimeout      = 10 * time.Second
+	// Deliveries of a batch are sent one after the other, the lease has to
+	// outlast a batch of receivers that all time out or another instance
+	// would claim and send them again.
+	webhookLease         = webhookBatchSize*webhookTimeout + time.Minute
+	webhookDeliveriesLog = 50
+)
+
+var userWebhookEvents = []string{WebhookActivityLogged, WebhookGoalCompleted, WebhookStreakBroken}
+var teamWebhookEvents = []string{WebhookTeamMemberJoined}
+
+// webhookClient is used for every outgoing webhook request. It refuses to
+// connect to addresses webhookAddressAllowed rejects, which also covers hosts
+// that resolved to a public address when the webhook was created. Proxies are
+// not used as they would hide the address from the check.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || !webhookAddressAllowed(ip) {
+					return fmt.Errorf("Webhook address %s is not a public address", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   webhookTimeout,
+		ResponseHeaderTimeout: webhookTimeout,
+	},
+}
+
+// webhookAddressAllowed decides which addresses webhooks may be sent to. Tests
+// replace it so deliveries can reach a local httptest receiver.
+var webhookAddressAllowed = isPublicIP
+
+// nonPublicNetworks are the ranges not covered by the net.IP helpers that are
+// still not reachable from the internet.
+var nonPublicNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("192.0.0.0/24"),
+	mustParseCIDR("198.18.0.0/15"),
+	mustParseCIDR("240.0.0.0/4"),
+}
+
+// webhookWake lets new deliveries skip the wait for the next poll.
+var webhookWake = make(chan struct{}, 1)
+
+type ActivityLoggedWebhook struct {
+	ActivityID   uuid.UUID `json:"activity_id"`
+	ActivityName string    `json:"activity_name"`
+	Duration     int32     `json:"duration"`
+	Points       int32     `json:"points"`
+	Description  string    `json:"description"`
+}
+
+type StreakBrokenWebhook struct {
+	PreviousStreak int32 `json:"previous_streak"`
+	LongestStreak  int32 `json:"longest_streak"`
+}
+
+type TeamMemberJoinedWebhook struct {
+	TeamID   uuid.UUID `json:"team_id"`
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+}
+
+type webhookPayload struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+func (apiCfg *apiConfig) CreateUserWebhook(w http.ResponseWriter, r *http.Request, user database.User) {
+	apiCfg.createWebhook(w, r, user, uuid.NullUUID{UUID: user.ID, Valid: true}, uuid.NullUUID{}, userWebhookEvents)
+}
+
+func (apiCfg *apiConfig) CreateTeamWebhook(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	apiCfg.createWebhook(w, r, user, uuid.NullUUID{}, uuid.NullUUID{UUID: parsedTeamUUID, Valid: true}, teamWebhookEvents)
+}
+
+func (apiCfg *apiConfig) createWebhook(w http.ResponseWriter, r *http.Request, user database.User, userID, teamID uuid.NullUUID, allowedEvents []string) {
+	if !requireVerifiedEmail(w, user) {
+		return
+	}
+	type parameters struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+	}
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing json: %s", err))
+		return
+	}
+	parsedURL, err := url.Parse(params.URL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		respondWithError(w, 400, "Webhook url must be an absolute http or https url")
+		return
+	}
+	err = checkWebhookHost(r.Context(), parsedURL.Hostname())
+	if err != nil {
+		respondWithError(w, 400, err.Error())
+		return
+	}
+	if len(params.EventTypes) == 0 {
+		respondWithError(w, 400, "At least one event type is required")
+		return
+	}
+	for _, eventType := range params.EventTypes {
+		if !slices.Contains(allowedEvents, eventType) {
+			respondWithError(w, 400, fmt.Sprintf("Unsupported event type %q, expected one of %v", eventType, allowedEvents))
+			return
+		}
+	}
+	eventTypes := slices.Clone(params.EventTypes)
+	slices.Sort(eventTypes)
+	secret, err := generateResetToken()
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error generating webhook secret: %s", err))
+		return
+	}
+	webhook, err := apiCfg.DB.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		ID:         uuid.New(),
+		UserID:     userID,
+		TeamID:     teamID,
+		CreatedBy:  user.ID,
+		Url:        parsedURL.String(),
+		Secret:     secret,
+		EventTypes: slices.Compact(eventTypes),
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error creating webhook: %s", err))
+		return
+	}
+	response := databaseWebhookToWebhook(webhook)
+	response.Secret = webhook.Secret
+	respondWithJson(w, 200, response)
+}
+
+func (apiCfg *apiConfig) GetUserWebhooks(w http.ResponseWriter, r *http.Request, user database.User) {
+	webhooks, err := apiCfg.DB.GetUserWebhooks(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting webhooks: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseWebhooksToWebhooks(webhooks))
+}
+
+func (apiCfg *apiConfig) GetTeamWebhooks(w http.ResponseWriter, r *http.Request, user database.User) {
+	parsedTeamUUID, err := uuid.Parse(r.PathValue("teamid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing team uuid: %s", err))
+		return
+	}
+	if !apiCfg.requireTeamOwner(w, r, parsedTeamUUID, user) {
+		return
+	}
+	webhooks, err := apiCfg.DB.GetTeamWebhooks(r.Context(), uuid.NullUUID{UUID: parsedTeamUUID, Valid: true})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting webhooks: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseWebhooksToWebhooks(webhooks))
+}
+
+func (apiCfg *apiConfig) DeleteWebhook(w http.ResponseWriter, r *http.Request, user database.User) {
+	webhook, ok := apiCfg.getManagedWebhook(w, r, user)
+	if !ok {
+		return
+	}
+	err := apiCfg.DB.DeleteWebhook(r.Context(), webhook.ID)
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error deleting webhook: %s", err))
+		return
+	}
+	respondWithJson(w, 200, "Webhook deleted successfully")
+}
+
+func (apiCfg *apiConfig) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request, user database.User) {
+	webhook, ok := apiCfg.getManagedWebhook(w, r, user)
+	if !ok {
+		return
+	}
+	deliveries, err := apiCfg.DB.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		WebhookID: webhook.ID,
+		Limit:     webhookDeliveriesLog,
+	})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting webhook deliveries: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseWebhookDeliveriesToWebhookDeliveries(deliveries))
+}
+
+// SendTestWebhook queues a webhook.test event for the webhook, whatever event
+// types it is subscribed to.
+func (apiCfg *apiConfig) SendTestWebhook(w http.ResponseWriter, r *http.Request, user database.User) {
+	webhook, ok := apiCfg.getManagedWebhook(w, r, user)
+	if !ok {
+		return
+	}
+	delivery, err := apiCfg.queueWebhookDelivery(r.Context(), webhook, WebhookTest, struct {
+		Message string `json:"message"`
+	}{Message: "This is a test event"})
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error queueing test event: %s", err))
+		return
+	}
+	respondWithJson(w, 200, databaseWebhookDeliveryToWebhookDelivery(delivery))
+}
+
+// getManagedWebhook loads the webhook from the path and makes sure the user
+// owns it, or owns the team it belongs to.
+func (apiCfg *apiConfig) getManagedWebhook(w http.ResponseWriter, r *http.Request, user database.User) (database.Webhook, bool) {
+	parsedWebhookUUID, err := uuid.Parse(r.PathValue("webhookid"))
+	if err != nil {
+		respondWithError(w, 400, fmt.Sprintf("Error in parsing webhook uuid: %s", err))
+		return database.Webhook{}, false
+	}
+	webhook, err := apiCfg.DB.GetWebhook(r.Context(), parsedWebhookUUID)
+	if err == sql.ErrNoRows {
+		respondWithError(w, 404, "Webhook not found")
+		return database.Webhook{}, false
+	} else if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error getting webhook: %s", err))
+		return database.Webhook{}, false
+	}
+	if webhook.UserID.Valid {
+		if webhook.UserID.UUID != user.ID {
+			respondWithError(w, 404, "Webhook not found")
+			return database.Webhook{}, false
+		}
+		return webhook, true
+	}
+	if !apiCfg.requireTeamOwner(w, r, webhook.TeamID.UUID, user) {
+		return database.Webhook{}, false
+	}
+	return webhook, true
+}
+
+// emitUserWebhookEvent queues the event for every webhook of the user that
+// subscribed to it. Failures are logged so they never fail the request.
+func (apiCfg *apiConfig) emitUserWebhookEvent(ctx context.Context, userID uuid.UUID, eventType string, data any) {
+	webhooks, err := apiCfg.DB.GetUserWebhooksForEvent(ctx, database.GetUserWebhooksForEventParams{
+		UserID:    uuid.NullUUID{UUID: userID, Valid: true},
+		EventType: eventType,
+	})
+	if err != nil {
+		log.Printf("Error getting webhooks for %s: %s", eventType, err)
+		return
+	}
+	apiCfg.queueWebhookDeliveries(ctx, webhooks, eventType, data)
+}
+
+func (apiCfg *apiConfig) emitTeamWebhookEvent(ctx context.Context, teamID uuid.UUID, eventType string, data any) {
+	webhooks, err := apiCfg.DB.GetTeamWebhooksForEvent(ctx, database.GetTeamWebhooksForEventParams{
+		TeamID:    uuid.NullUUID{UUID: teamID, Valid: true},
+		EventType: eventType,
+	})
+	if err != nil {
+		log.Printf("Error getting webhooks for %s: %s", eventType, err)
+		return
+	}
+	apiCfg.queueWebhookDeliveries(ctx, webhooks, eventType, data)
+}
+
+// emitTeamMemberJoined is used when the joining user is not the one making
+// the request, so their username has to be looked up.
+func (apiCfg *apiConfig) emitTeamMemberJoined(ctx context.Context, teamID, userID uuid.UUID) {
+	member, err := apiCfg.DB.GetUserById(ctx, userID)
+	if err != nil {
+		log.Printf("Error getting user %s: %s", userID, err)
+		return
+	}
+	apiCfg.emitTeamWebhookEvent(ctx, teamID, WebhookTeamMemberJoined, TeamMemberJoinedWebhook{TeamID: teamID, UserID: member.ID, Username: member.Username})
+}
+
+func (apiCfg *apiConfig) queueWebhookDeliveries(ctx context.Context, webhooks []database.Webhook, eventType string, data any) {
+	for _, webhook := range webhooks {
+		_, err := apiCfg.queueWebhookDelivery(ctx, webhook, eventType, data)
+		if err != nil {
+			log.Printf("Error queueing %s delivery for webhook %s: %s", eventType, webhook.ID, err)
+		}
+	}
+}
+
+func (apiCfg *apiConfig) queueWebhookDelivery(ctx context.Context, webhook database.Webhook, eventType string, data any) (database.WebhookDelivery, error) {
+	deliveryID := uuid.New()
+	payload, err := json.Marshal(webhookPayload{ID: deliveryID, Type: eventType, CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+	delivery, err := apiCfg.DB.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+		ID:        deliveryID,
+		WebhookID: webhook.ID,
+		EventType: eventType,
+		Payload:   payload,
+	})
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+	return delivery, nil
+}
+
+// startWebhookWorker sends due deliveries until the process exits. Deliveries
+// are claimed with a lease, so several instances can run the worker at once.
+func (apiCfg *apiConfig) startWebhookWorker() {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		// A full batch means more deliveries are probably due right away.
+		if apiCfg.processWebhookDeliveries() == webhookBatchSize {
+			continue
+		}
+		select {
+		case <-ticker.C:
+		case <-webhookWake:
+		}
+	}
+}
+
+func (apiCfg *apiConfig) processWebhookDeliveries() int {
+	ctx := context.Background()
+	deliveries, err := apiCfg.DB.ClaimDueWebhookDeliveries(ctx, database.ClaimDueWebhookDeliveriesParams{
+		LeaseUntil: time.Now().Add(webhookLease),
+		BatchSize:  webhookBatchSize,
+	})
+	if err != nil {
+		log.Printf("Error claiming webhook deliveries: %s", err)
+		return 0
+	}
+	for _, delivery := range deliveries {
+		apiCfg.attemptWebhookDelivery(ctx, delivery)
+	}
+	return len(deliveries)
+}
+
+func (apiCfg *apiConfig) attemptWebhookDelivery(ctx context.Context, delivery database.WebhookDelivery) {
+	webhook, err := apiCfg.DB.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		log.Printf("Error getting webhook %s: %s", delivery.WebhookID, err)
+		return
+	}
+	statusCode, err := sendWebhook(ctx, webhook, delivery)
+	err = apiCfg.DB.SetWebhookDeliveryResult(ctx, webhookDeliveryResult(delivery, statusCode, err, time.Now()))
+	if err != nil {
+		log.Printf("Error saving webhook delivery result: %s", err)
+	}
+}
+
+// webhookDeliveryResult records an attempt that got statusCode and sendErr.
+// A failed attempt is retried after webhookRetryDelay until the delivery runs
+// out of attempts.
+func webhookDeliveryResult(delivery database.WebhookDelivery, statusCode int, sendErr error, now time.Time) database.SetWebhookDeliveryResultParams {
+	attempts := delivery.Attempts + 1
+	result := database.SetWebhookDeliveryResultParams{
+		ID:            delivery.ID,
+		Status:        "succeeded",
+		Attempts:      attempts,
+		NextAttemptAt: now,
+	}
+	if statusCode != 0 {
+		result.ResponseStatus = sql.NullInt32{Int32: int32(statusCode), Valid: true}
+	}
+	if sendErr != nil {
+		result.LastError = sql.NullString{String: sendErr.Error(), Valid: true}
+		if attempts >= webhookMaxAttempts {
+			result.Status = "failed"
+		} else {
+			result.Status = "pending"
+			result.NextAttemptAt = now.Add(webhookRetryDelay(attempts))
+		}
+	}
+	return result
+}
+
+// webhookRetryDelay doubles the wait after every failed attempt.
+func webhookRetryDelay(attempts int32) time.Duration {
+	delay := webhookRetryBase << (attempts - 1)
+	if delay <= 0 || delay > webhookRetryMax {
+		return webhookRetryMax
+	}
+	return delay
+}
+
+// sendWebhook posts the payload signed with the webhook secret. Receivers
+// verify X-Webhook-Signature, the hex HMAC-SHA256 of "<timestamp>.<body>".
+func sendWebhook(ctx context.Context, webhook database.Webhook, delivery database.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", delivery.ID.String())
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("Receiver responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func signWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// checkWebhookHost makes sure every address the host resolves to is public, so
+// webhooks cannot be used to reach the server's own network.
+func checkWebhookHost(ctx context.Context, host string) error {
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addresses) == 0 {
+		return fmt.Errorf("Webhook host %s could not be resolved", host)
+	}
+	for _, address := range addresses {
+		if !webhookAddressAllowed(address.IP) {
+			return errors.New("Webhook url must point to a public address")
+		}
+	}
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
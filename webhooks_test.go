@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// allowLoopbackWebhooks lets webhooks reach httptest receivers for the rest
+// of the test.
+func allowLoopbackWebhooks(t *testing.T) {
+	t.Helper()
+	allowed := webhookAddressAllowed
+	webhookAddressAllowed = func(ip net.IP) bool {
+		return ip.IsLoopback() || allowed(ip)
+	}
+	t.Cleanup(func() { webhookAddressAllowed = allowed })
+}
+
+func testWebhookDelivery(url string) (database.Webhook, database.WebhookDelivery) {
+	webhook := database.Webhook{ID: uuid.New(), Url: url, Secret: "test-secret"}
+	delivery := database.WebhookDelivery{
+		ID:        uuid.New(),
+		WebhookID: webhook.ID,
+		EventType: WebhookTest,
+		Payload:   []byte(`{"type":"webhook.test","data":{"message":"hello"}}`),
+		Status:    "pending",
+	}
+	return webhook, delivery
+}
+
+func TestSendWebhookSignsPayload(t *testing.T) {
+	allowLoopbackWebhooks(t)
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer receiver.Close()
+	webhook, delivery := testWebhookDelivery(receiver.URL)
+
+	statusCode, err := sendWebhook(context.Background(), webhook, delivery)
+	if err != nil || statusCode != 200 {
+		t.Fatalf("sendWebhook() = %d, %v, want 200, nil", statusCode, err)
+	}
+	r := <-received
+	body := <-bodies
+	if string(body) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", body, delivery.Payload)
+	}
+	if r.Header.Get("X-Webhook-Id") != delivery.ID.String() || r.Header.Get("X-Webhook-Event") != WebhookTest {
+		t.Errorf("unexpected id or event headers: %v", r.Header)
+	}
+	timestamp := r.Header.Get("X-Webhook-Timestamp")
+	mac := hmac.New(sha256.New, []byte(webhook.Secret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if r.Header.Get("X-Webhook-Signature") != want {
+		t.Errorf("X-Webhook-Signature = %s, want %s", r.Header.Get("X-Webhook-Signature"), want)
+	}
+}
+
+func TestSendWebhookServerError(t *testing.T) {
+	allowLoopbackWebhooks(t)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+	webhook, delivery := testWebhookDelivery(receiver.URL)
+
+	statusCode, err := sendWebhook(context.Background(), webhook, delivery)
+	if err == nil || statusCode != http.StatusServiceUnavailable {
+		t.Fatalf("sendWebhook() = %d, %v, want 503 and an error", statusCode, err)
+	}
+	now := time.Now()
+	result := webhookDeliveryResult(delivery, statusCode, err, now)
+	if result.Status != "pending" || result.Attempts != 1 {
+		t.Errorf("status, attempts = %s, %d, want pending, 1", result.Status, result.Attempts)
+	}
+	if !result.ResponseStatus.Valid || result.ResponseStatus.Int32 != http.StatusServiceUnavailable {
+		t.Errorf("response status = %v, want 503", result.ResponseStatus)
+	}
+	if !result.LastError.Valid {
+		t.Error("last error not recorded")
+	}
+	if !result.NextAttemptAt.Equal(now.Add(webhookRetryBase)) {
+		t.Errorf("next attempt at %s, want %s", result.NextAttemptAt, now.Add(webhookRetryBase))
+	}
+}
+
+func TestWebhookDeliveryRetriesUntilSuccess(t *testing.T) {
+	allowLoopbackWebhooks(t)
+	var requests atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+	webhook, delivery := testWebhookDelivery(receiver.URL)
+
+	now := time.Now()
+	for delivery.Status == "pending" {
+		statusCode, err := sendWebhook(context.Background(), webhook, delivery)
+		result := webhookDeliveryResult(delivery, statusCode, err, now)
+		delivery.Status = result.Status
+		delivery.Attempts = result.Attempts
+	}
+	if delivery.Status != "succeeded" || delivery.Attempts != 3 || requests.Load() != 3 {
+		t.Errorf("status, attempts, requests = %s, %d, %d, want succeeded, 3, 3", delivery.Status, delivery.Attempts, requests.Load())
+	}
+}
+
+func TestWebhookDeliveryGivesUp(t *testing.T) {
+	allowLoopbackWebhooks(t)
+	var requests atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer receiver.Close()
+	webhook, delivery := testWebhookDelivery(receiver.URL)
+
+	now := time.Now()
+	for delivery.Status == "pending" {
+		statusCode, err := sendWebhook(context.Background(), webhook, delivery)
+		result := webhookDeliveryResult(delivery, statusCode, err, now)
+		delivery.Status = result.Status
+		delivery.Attempts = result.Attempts
+	}
+	if delivery.Status != "failed" || delivery.Attempts != webhookMaxAttempts || requests.Load() != webhookMaxAttempts {
+		t.Errorf("status, attempts, requests = %s, %d, %d, want failed, %d, %d", delivery.Status, delivery.Attempts, requests.Load(), webhookMaxAttempts, webhookMaxAttempts)
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int32
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 3, want: 2 * time.Minute},
+		{attempts: 4, want: 4 * time.Minute},
+		{attempts: 7, want: 32 * time.Minute},
+		{attempts: 10, want: 256 * time.Minute},
+		{attempts: 11, want: webhookRetryMax},
+		{attempts: 64, want: webhookRetryMax},
+	}
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("webhookRetryDelay(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookLeaseOutlastsBatch(t *testing.T) {
+	if webhookLease <= webhookBatchSize*webhookTimeout {
+		t.Errorf("webhookLease %s does not outlast a batch of %d deliveries timing out after %s", webhookLease, webhookBatchSize, webhookTimeout)
+	}
+}
+
+func TestWebhookRefusesPrivateAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback receiver")
+	}))
+	defer receiver.Close()
+	webhook, delivery := testWebhookDelivery(receiver.URL)
+
+	_, err := sendWebhook(context.Background(), webhook, delivery)
+	if err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("sendWebhook() error = %v, want a refused address", err)
+	}
+	for _, host := range []string{"127.0.0.1", "10.0.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "::1"} {
+		if err := checkWebhookHost(context.Background(), host); err == nil {
+			t.Errorf("checkWebhookHost(%s) accepted a private address", host)
+		}
+	}
+	if err := checkWebhookHost(context.Background(), "93.184.216.34"); err != nil {
+		t.Errorf("checkWebhookHost(93.184.216.34) = %v, want nil", err)
+	}
+}
@@ -1,64 +1,339 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"github.com/mrdkvcs/go-base-backend/internal/database"
 	"log"
 	"net/http"
+	"slices"
 	"sync"
+	"time"
 )
 
+const (
+	// Time allowed to write a message to the peer.
+	wsWriteWait = 10 * time.Second
+	// Time allowed to read the next pong message from the peer.
+	wsPongWait = 60 * time.Second
+	// Pings are sent with this period, it must be less than wsPongWait.
+	wsPingPeriod = (wsPongWait * 9) / 10
+	// Clients only send pongs and close frames, so keep reads small.
+	wsMaxMessageSize = 512
+	// Events queued for a connection before it is considered too slow and evicted.
+	wsSendBufferSize = 32
+	// Missed notifications sent when a connection opens, kept well below
+	// wsSendBufferSize so live events still fit in the buffer.
+	wsBacklogSize = wsSendBufferSize / 2
+	// How long a ticket from POST /ws/ticket can be used to open a connection.
+	wsTicketTTL      = 30 * time.Second
+	wsTicketAudience = "ws"
+)
+
+const (
+	EventInviteReceived = "invite.received"
+	EventGoalCompleted  = "goal.completed"
+	EventStreakRecord   = "streak.record"
+)
+
+// Event is the envelope every message sent over the websocket is wrapped in.
+type Event struct {
+	ID     uuid.UUID `json:"id"`
+	Type   string    `json:"type"`
+	Data   any       `json:"data"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+type InviteReceivedEvent struct {
+	InvitationID   uuid.UUID `json:"invitation_id"`
+	TeamID         uuid.UUID `json:"team_id"`
+	SenderUsername string    `json:"sender_username"`
+}
+
+type GoalCompletedEvent struct {
+	GoalPoints  int32 `json:"goal_points"`
+	TotalPoints int32 `json:"total_points"`
+}
+
+type StreakRecordEvent struct {
+	CurrentStreak int32 `json:"current_streak"`
+	LongestStreak int32 `json:"longest_streak"`
+}
+
+// wsTicketClaims is a short-lived token for browsers that cannot set headers
+// on the websocket handshake. It carries the expiry of the login token it was
+// issued for, so the connection does not outlive the session.
+type wsTicketClaims struct {
+	SessionExpiresAt *jwt.NumericDate `json:"session_exp"`
+	jwt.RegisteredClaims
+}
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 	CheckOrigin: func(r *http.Request) bool {
-		return true
+		origin := r.Header.Get("Origin")
+		return origin == "" || slices.Contains(allowedOrigins, origin)
 	},
 }
 
-var (
-	connections = make(map[string]*websocket.Conn)
-	broadcast   = make(chan string)
-	mux         sync.Mutex
-)
+var hub *Hub
+
+type wsClient struct {
+	userID    uuid.UUID
+	conn      *websocket.Conn
+	send      chan []byte
+	expiresAt time.Time
+}
+
+// Hub keeps every open websocket connection grouped by user, so a user with
+// several tabs or devices receives each event on all of them.
+type Hub struct {
+	mu          sync.Mutex
+	clients     map[uuid.UUID]map[*wsClient]struct{}
+	backplane   Backplane
+	onDelivered func(ids ...uuid.UUID)
+}
+
+func newHub(backplane Backplane, onDelivered func(ids ...uuid.UUID)) *Hub {
+	h := &Hub{clients: make(map[uuid.UUID]map[*wsClient]struct{}), backplane: backplane, onDelivered: onDelivered}
+	backplane.Subscribe(h.deliver)
+	return h
+}
 
-func handleConnections(w http.ResponseWriter, r *http.Request) {
+func (h *Hub) register(client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[client.userID] == nil {
+		h.clients[client.userID] = make(map[*wsClient]struct{})
+	}
+	h.clients[client.userID][client] = struct{}{}
+}
+
+func (h *Hub) unregister(client *wsClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removeLocked(client)
+}
+
+// removeLocked drops the client and closes its send channel, which makes the
+// write pump close the connection. h.mu must be held for writing.
+func (h *Hub) removeLocked(client *wsClient) {
+	userClients, ok := h.clients[client.userID]
+	if !ok {
+		return
+	}
+	if _, ok := userClients[client]; !ok {
+		return
+	}
+	delete(userClients, client)
+	close(client.send)
+	if len(userClients) == 0 {
+		delete(h.clients, client.userID)
+	}
+}
+
+// Publish sends an event to every connection of the user, on whichever
+// instance it is connected to. It never blocks the caller.
+func (h *Hub) Publish(userID uuid.UUID, event Event) {
+	message, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding %s event: %s", event.Type, err)
+		return
+	}
+	h.backplane.Publish(userID, message)
+}
+
+// queue sends the message to a single registered connection. It reports false
+// when the connection is gone or its buffer is full.
+func (h *Hub) queue(client *wsClient, message []byte) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[client.userID][client]; !ok {
+		return false
+	}
+	select {
+	case client.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliver writes the message to the user's connections on this instance.
+// Connections whose buffer is full are evicted instead of waited on. Once a
+// connection accepted the event, its notification is marked as delivered.
+func (h *Hub) deliver(userID uuid.UUID, message []byte) {
+	delivered := false
+	h.mu.Lock()
+	for client := range h.clients[userID] {
+		select {
+		case client.send <- message:
+			delivered = true
+		default:
+			log.Printf("Evicting slow websocket client of user %s", userID)
+			h.removeLocked(client)
+		}
+	}
+	h.mu.Unlock()
+	if !delivered || h.onDelivered == nil {
+		return
+	}
+	event := Event{}
+	err := json.Unmarshal(message, &event)
+	if err != nil || event.ID == uuid.Nil {
+		return
+	}
+	go h.onDelivered(event.ID)
+}
+
+func (apiCfg *apiConfig) CreateWsTicket(w http.ResponseWriter, r *http.Request, user database.User) {
+	claims, _ := claimsFromContext(r.Context())
+	expiresAt := time.Now().Add(wsTicketTTL)
+	err := apiCfg.DB.DeleteExpiredWsTickets(r.Context())
+	if err != nil {
+		log.Printf("Error deleting expired websocket tickets: %s", err)
+	}
+	ticket := jwt.NewWithClaims(jwt.SigningMethodHS256, wsTicketClaims{
+		SessionExpiresAt: claims.ExpiresAt,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   user.ID.String(),
+			Audience:  jwt.ClaimStrings{wsTicketAudience},
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	signedTicket, err := ticket.SignedString([]byte(jwtSecret))
+	if err != nil {
+		respondWithError(w, 500, fmt.Sprintf("Error creating websocket ticket: %s", err))
+		return
+	}
+	respondWithJson(w, 200, struct {
+		Ticket    string    `json:"ticket"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{Ticket: signedTicket, ExpiresAt: expiresAt})
+}
+
+func (apiCfg *apiConfig) handleConnections(w http.ResponseWriter, r *http.Request) {
+	user, expiresAt, err := apiCfg.authenticateWebsocket(r)
+	if err != nil {
+		respondWithError(w, 401, fmt.Sprintf("Unauthorized user , %s", err))
+		return
+	}
 	conn, err := upgrader.Upgrade(w, r, nil)
-	userId := r.URL.Query().Get("userid")
 	if err != nil {
-		respondWithJson(w, 500, "Error upgrading ws connection")
+		log.Printf("Error upgrading ws connection: %s", err)
 		return
 	}
-	defer conn.Close()
-	mux.Lock()
-	connections[userId] = conn
-	mux.Unlock()
-	for {
-		_, _, err := conn.ReadMessage()
+	client := &wsClient{userID: user.ID, conn: conn, send: make(chan []byte, wsSendBufferSize), expiresAt: expiresAt}
+	// Register before loading the backlog so nothing stored in between is
+	// missed, and start the pump so it drains the buffer while it fills.
+	hub.register(client)
+	go client.writePump()
+	apiCfg.queueUndeliveredNotifications(r.Context(), client)
+	client.readPump()
+}
+
+// authenticateWebsocket accepts a ticket query parameter or the same token as
+// middlewareAuth and returns the user with the time the connection must end.
+func (apiCfg *apiConfig) authenticateWebsocket(r *http.Request) (database.User, time.Time, error) {
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		claims := &wsTicketClaims{}
+		_, err := jwt.ParseWithClaims(ticket, claims, func(token *jwt.Token) (interface{}, error) {
+			return []byte(jwtSecret), nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(wsTicketAudience), jwt.WithExpirationRequired())
+		if err != nil {
+			return database.User{}, time.Time{}, fmt.Errorf("invalid ticket")
+		}
+		userID, err := uuid.Parse(claims.Subject)
 		if err != nil {
-			mux.Lock()
-			delete(connections, userId)
-			mux.Unlock()
+			return database.User{}, time.Time{}, fmt.Errorf("invalid ticket")
+		}
+		ticketID, err := uuid.Parse(claims.ID)
+		if err != nil {
+			return database.User{}, time.Time{}, fmt.Errorf("invalid ticket")
+		}
+		// A ticket opens a single connection, it is remembered until it
+		// expires so a replay is refused.
+		used, err := apiCfg.DB.UseWsTicket(r.Context(), database.UseWsTicketParams{
+			Jti:       ticketID,
+			ExpiresAt: claims.ExpiresAt.Time,
+		})
+		if err != nil {
+			return database.User{}, time.Time{}, fmt.Errorf("error checking ticket: %s", err)
+		}
+		if used == 0 {
+			return database.User{}, time.Time{}, fmt.Errorf("ticket already used")
+		}
+		user, err := apiCfg.DB.GetUserById(r.Context(), userID)
+		if err != nil {
+			return database.User{}, time.Time{}, fmt.Errorf("user not found")
+		}
+		if claims.SessionExpiresAt == nil {
+			return database.User{}, time.Time{}, fmt.Errorf("invalid ticket")
+		}
+		return user, claims.SessionExpiresAt.Time, nil
+	}
+	user, claims, err := apiCfg.authenticate(r.Context(), getRequestToken(r))
+	if err != nil {
+		return database.User{}, time.Time{}, err
+	}
+	if claims.ExpiresAt == nil {
+		return database.User{}, time.Time{}, fmt.Errorf("invalid token")
+	}
+	return user, claims.ExpiresAt.Time, nil
+}
+
+// readPump only exists to process pongs and notice when the peer goes away.
+func (c *wsClient) readPump() {
+	defer func() {
+		hub.unregister(c)
+		c.conn.Close()
+	}()
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
 			break
 		}
 	}
 }
 
-func handleMessages() {
+func (c *wsClient) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	expiry := time.NewTimer(time.Until(c.expiresAt))
+	defer func() {
+		ticker.Stop()
+		expiry.Stop()
+		c.conn.Close()
+	}()
 	for {
-		recipientId := <-broadcast
-		mux.Lock()
-		conn, ok := connections[recipientId]
-		mux.Unlock()
-		if ok {
-			err := conn.WriteMessage(websocket.TextMessage, []byte("Invite received"))
-			if err != nil {
-				log.Println("Error writing message to connection")
-				mux.Lock()
-				delete(connections, recipientId)
-				mux.Unlock()
+		select {
+		case <-expiry.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "token expired"))
+			return
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
 			}
-		} else {
-			log.Println("User not found")
 		}
 	}
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func newTestHub(t *testing.T) (*Hub, chan uuid.UUID) {
+	t.Helper()
+	delivered := make(chan uuid.UUID, 8)
+	h := newHub(newMemoryBackplane(), func(ids ...uuid.UUID) {
+		for _, id := range ids {
+			delivered <- id
+		}
+	})
+	return h, delivered
+}
+
+func newTestClient(h *Hub, userID uuid.UUID, bufferSize int) *wsClient {
+	client := &wsClient{userID: userID, send: make(chan []byte, bufferSize)}
+	h.register(client)
+	return client
+}
+
+func isRegistered(h *Hub, client *wsClient) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.clients[client.userID][client]
+	return ok
+}
+
+func TestHubFansOutToEveryConnectionOfTheUser(t *testing.T) {
+	h, delivered := newTestHub(t)
+	userID := uuid.New()
+	tab := newTestClient(h, userID, wsSendBufferSize)
+	phone := newTestClient(h, userID, wsSendBufferSize)
+	other := newTestClient(h, uuid.New(), wsSendBufferSize)
+
+	event := Event{ID: uuid.New(), Type: EventGoalCompleted, Data: GoalCompletedEvent{GoalPoints: 10, TotalPoints: 12}}
+	h.Publish(userID, event)
+
+	for _, client := range []*wsClient{tab, phone} {
+		select {
+		case message := <-client.send:
+			received := Event{}
+			if err := json.Unmarshal(message, &received); err != nil {
+				t.Fatalf("decoding event: %s", err)
+			}
+			if received.ID != event.ID || received.Type != event.Type {
+				t.Errorf("received %+v, want %+v", received, event)
+			}
+		default:
+			t.Error("event not delivered to a connection of the user")
+		}
+	}
+	if len(other.send) != 0 {
+		t.Error("event delivered to another user")
+	}
+	select {
+	case id := <-delivered:
+		if id != event.ID {
+			t.Errorf("marked %s as delivered, want %s", id, event.ID)
+		}
+	case <-time.After(time.Second):
+		t.Error("delivered event was not marked as delivered")
+	}
+}
+
+func TestHubEvictsSlowClients(t *testing.T) {
+	h, _ := newTestHub(t)
+	userID := uuid.New()
+	slow := newTestClient(h, userID, 1)
+	fast := newTestClient(h, userID, wsSendBufferSize)
+
+	h.Publish(userID, Event{ID: uuid.New(), Type: EventStreakRecord})
+	h.Publish(userID, Event{ID: uuid.New(), Type: EventStreakRecord})
+
+	if isRegistered(h, slow) {
+		t.Error("slow client is still registered")
+	}
+	if !isRegistered(h, fast) {
+		t.Error("fast client was evicted")
+	}
+	if len(fast.send) != 2 {
+		t.Errorf("fast client got %d events, want 2", len(fast.send))
+	}
+	<-slow.send
+	if _, ok := <-slow.send; ok {
+		t.Error("send channel of the evicted client is still open")
+	}
+	if h.queue(slow, []byte("{}")) {
+		t.Error("queued a message for an evicted client")
+	}
+}
+
+func TestHubQueueDoesNotBlockOnFullBuffer(t *testing.T) {
+	h, _ := newTestHub(t)
+	client := newTestClient(h, uuid.New(), 1)
+	if !h.queue(client, []byte("{}")) {
+		t.Fatal("queue() = false on an empty buffer")
+	}
+	if h.queue(client, []byte("{}")) {
+		t.Error("queue() = true on a full buffer")
+	}
+	if !isRegistered(h, client) {
+		t.Error("queue() evicted the client")
+	}
+}